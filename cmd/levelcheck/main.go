@@ -0,0 +1,72 @@
+/*
+This file provides a checker for levels, printing any problems with them without having to play them.
+
+Levels are given by name, as with --map, or as paths to level files. With none given, every level is checked. Like magsim, it can be built with the headless tag to run without cgo or a display.
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/levelcheck"
+	"github.com/thought-machine/go-flags"
+)
+
+type Options struct {
+	Data string `long:"data" description:"Directory of assets that replace or add to the game's own, laid out the same way"`
+}
+
+func main() {
+	var opts Options
+	args, err := flags.Parse(&opts)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	if err := data.OverlayAssets(opts.Data); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := data.LoadMods(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := data.LoadConfigurations(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var errs []error
+	if len(args) == 0 {
+		errs = levelcheck.CheckAll()
+	}
+	for _, arg := range args {
+		if strings.HasSuffix(arg, ".txt") {
+			b, err := os.ReadFile(arg)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			errs = append(errs, levelcheck.CheckBytes(arg, b)...)
+		} else {
+			errs = append(errs, levelcheck.Check(arg)...)
+		}
+	}
+
+	// Warnings are printed, but don't count as problems.
+	warnings := 0
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+		if _, ok := err.(data.ConfigWarning); ok {
+			warnings++
+		}
+	}
+	if problems := len(errs) - warnings; problems > 0 {
+		fmt.Fprintf(os.Stderr, "%d problem(s) and %d warning(s) found\n", problems, warnings)
+		os.Exit(1)
+	}
+	fmt.Printf("no problems found, %d warning(s)\n", warnings)
+}
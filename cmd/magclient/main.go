@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/kettek/ebijam22/pkg/net"
 )
@@ -19,7 +20,22 @@ func main() {
 
 	var c net.Connection
 
-	if os.Args[1] == "host" {
+	if os.Args[1] == "lan" {
+		name = os.Args[3]
+
+		c = net.NewConnection(name)
+
+		var err error
+		if os.Args[2] == "join" {
+			err = joinLAN(&c)
+		} else {
+			err = c.AwaitLAN("")
+		}
+		if err != nil {
+			panic(err)
+		}
+		go c.Loop()
+	} else if os.Args[1] == "host" {
 		addr = os.Args[2]
 		name = os.Args[3]
 
@@ -61,10 +77,28 @@ func main() {
 		go c.Loop()
 	}
 	for {
-		select {
-		case m := <-c.Messages:
-			fmt.Println("got message", m)
+		for _, m := range c.PeerMessages() {
+			fmt.Println("got message from", m.From, m.Message)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// joinLAN joins the first compatible LAN game it hears of.
+func joinLAN(c *net.Connection) error {
+	browser, err := net.NewLANBrowser()
+	if err != nil {
+		return err
+	}
+	defer browser.Close()
+	for {
+		for _, g := range browser.Games() {
+			fmt.Println("found", g.Name, "playing", g.Map, "at", g.Addr.String())
+			if g.Compatible() {
+				return c.JoinLAN(g)
+			}
 		}
+		time.Sleep(500 * time.Millisecond)
 	}
 }
 
@@ -72,4 +106,6 @@ func help() {
 	fmt.Printf("Syntax: %s <signaler> <address:port> <name> [<target>]\n", os.Args[0])
 	fmt.Printf("Syntax: %s join <target address:port> <name>\n", os.Args[0])
 	fmt.Printf("Syntax: %s host <address:port> <name>\n", os.Args[0])
+	fmt.Printf("Syntax: %s lan <host|join> <name>\n", os.Args[0])
+	fmt.Printf("Addresses can be IPv4 or IPv6, such as [::1]:20220 or [fe80::1%%eth0]:20220.\n")
 }
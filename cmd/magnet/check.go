@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/levelcheck"
+)
+
+// checkAssets checks every entity config and level the game would load, printing every problem found. It returns the exit code to use.
+func checkAssets(options data.Options) int {
+	// Same as the game's.
+	data.CellWidth = 16
+	data.CellHeight = 11
+
+	if err := data.OverlayAssets(options.Data); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := data.LoadMods(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	errs := data.CheckConfigurations()
+	errs = append(errs, levelcheck.CheckAll()...)
+
+	// Warnings are printed, but don't count as problems.
+	warnings := 0
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+		if _, ok := err.(data.ConfigWarning); ok {
+			warnings++
+		}
+	}
+	if problems := len(errs) - warnings; problems > 0 {
+		fmt.Fprintf(os.Stderr, "%d problem(s) and %d warning(s) found\n", problems, warnings)
+		return 1
+	}
+	fmt.Printf("no problems found, %d warning(s)\n", warnings)
+	return 0
+}
@@ -17,6 +17,10 @@ func main() {
 		return
 	}
 
+	if g.Options.CheckAssets {
+		os.Exit(checkAssets(g.Options))
+	}
+
 	if err := os.Setenv("EBITEN_GRAPHICS_LIBRARY", "opengl"); err != nil {
 		fmt.Println("WARNING: OpenGL backend could not be set, expect degraded performance if on DirectX.")
 	}
@@ -0,0 +1,98 @@
+/*
+This file provides a headless simulation runner for balancing levels and turrets.
+
+It never opens a window or plays audio. Build it with the headless tag, as in `CGO_ENABLED=0 go build -tags headless ./cmd/magsim`, to leave ebiten out so it runs without cgo or a display.
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/world"
+	"github.com/thought-machine/go-flags"
+)
+
+type Options struct {
+	Map       string  `short:"m" long:"map" description:"Map to simulate" default:"001"`
+	Script    string  `short:"s" long:"script" description:"Script file of tool placements and ready signals"`
+	Speed     float64 `short:"S" long:"speed" description:"Game speed multiplier" default:"1.0"`
+	Seed      int64   `long:"seed" description:"Seed for the world's random source. 0 picks one from the current time"`
+	Ticks     int     `short:"t" long:"ticks" description:"Maximum ticks to simulate" default:"216000"`
+	AutoReady bool    `short:"r" long:"autoready" description:"Automatically ready up whenever build mode starts"`
+	Data      string  `long:"data" description:"Directory of assets that replace or add to the game's own, laid out the same way"`
+}
+
+func main() {
+	var opts Options
+	if _, err := flags.Parse(&opts); err != nil {
+		os.Exit(1)
+	}
+
+	// Same as the game's.
+	data.CellWidth = 16
+	data.CellHeight = 11
+
+	if err := data.OverlayAssets(opts.Data); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := data.LoadMods(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := data.LoadConfigurations(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := data.LoadData(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	level, err := data.NewLevel(opts.Map)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	sim, err := world.NewSimulation(level, data.Options{
+		Map:   opts.Map,
+		Speed: opts.Speed,
+		Seed:  opts.Seed,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	sim.AutoReady = opts.AutoReady
+
+	if opts.Script != "" {
+		f, err := os.Open(opts.Script)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		sim.Steps, err = world.ParseSimulationScript(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", opts.Script, err)
+			os.Exit(1)
+		}
+	}
+
+	result, err := sim.Run(opts.Ticks)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("map: %s\n", opts.Map)
+	fmt.Printf("seed: %d\n", result.Seed)
+	fmt.Printf("mode: %s\n", result.Mode)
+	fmt.Printf("core: %d\n", result.CoreHealth)
+	fmt.Printf("points: %d\n", result.Points)
+	fmt.Printf("wave: %d/%d\n", result.Wave, result.MaxWave)
+	fmt.Printf("ticks: %d\n", result.Ticks)
+}
@@ -6,7 +6,10 @@ require (
 	github.com/hajimehoshi/ebiten/v2 v2.3.4
 	github.com/kettek/gobl v0.1.1-0.20220312222957-aba683107d7d
 	github.com/kettek/goro v0.0.0-20220620073715-117f8520bffd
+	github.com/thought-machine/go-flags v1.6.1
+	golang.org/x/crypto v0.0.0-20220525230936-793ad666bf5e
 	golang.org/x/image v0.0.0-20220321031419-a8550c1d254a
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -17,11 +20,9 @@ require (
 	github.com/jfreymuth/oggvorbis v1.0.3 // indirect
 	github.com/jfreymuth/vorbis v1.0.2 // indirect
 	github.com/radovskyb/watcher v1.0.7 // indirect
-	github.com/thought-machine/go-flags v1.6.1 // indirect
 	golang.org/x/exp v0.0.0-20190731235908-ec7cb31e5a56 // indirect
 	golang.org/x/mobile v0.0.0-20220518205345-8578da9835fd // indirect
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
 	golang.org/x/sys v0.0.0-20220408201424-a24fb2fb8a0f // indirect
 	golang.org/x/text v0.3.7 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -5,6 +5,7 @@ import (
 	"embed"
 	"image"
 	_ "image/png"
+	"io/fs"
 	"path"
 	"strings"
 
@@ -14,8 +15,14 @@ import (
 //go:embed assets/*
 var assets embed.FS
 
+// ReadFile reads the file from the highest asset layer that has it.
 func ReadFile(p string) ([]byte, error) {
-	return assets.ReadFile(path.Join("assets", p))
+	return assetLayers.ReadFile(assetPath(p))
+}
+
+// ReadDir lists the directory across every asset layer.
+func ReadDir(p string) ([]fs.DirEntry, error) {
+	return assetLayers.ReadDir(assetPath(p))
 }
 
 func ReadImage(p string) (image.Image, error) {
@@ -47,7 +54,7 @@ func ReadMusic(p string) (*Sound, error) {
 
 func ReadImagesByPrefix(prefix string) ([]image.Image, error) {
 	var images []image.Image
-	fileList, err := assets.ReadDir(path.Join("assets", "images"))
+	fileList, err := ReadDir("images")
 	for _, file := range fileList {
 		if !file.IsDir() && strings.HasPrefix(file.Name(), prefix) {
 			image, err := ReadImage(file.Name())
@@ -62,7 +69,7 @@ func ReadImagesByPrefix(prefix string) ([]image.Image, error) {
 
 func GetPathFiles(p string) ([]string, error) {
 	var files []string
-	fileList, err := assets.ReadDir(path.Join("assets", p))
+	fileList, err := ReadDir(p)
 	for _, file := range fileList {
 		if !file.IsDir() {
 			files = append(files, strings.Split(file.Name(), ".")[0])
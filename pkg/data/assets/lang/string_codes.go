@@ -29,7 +29,6 @@ const (
 	HostGame         = "host_game"
 	JoinGame         = "join_game"
 	BroadcastGame    = "broadcast_game"
-	FindBroadcast    = "find_broadcast"
 	FindPlayer       = "find_player"
 	WaitForPlayer    = "wait_for_player"
 	LocalPlayerName  = "local_player_name"
@@ -37,6 +36,65 @@ const (
 	HostSyncRate     = "host_sync_rate"
 	IPAddress        = "ip_address"
 	Port             = "port"
+	Rooms            = "rooms"
+	RefreshRooms     = "refresh_rooms"
+	RefreshingRooms  = "refreshing_rooms"
+	NoRooms          = "no_rooms"
+	RoomPassword     = "room_password"
+	LANGames         = "lan_games"
+	NoLANGames       = "no_lan_games"
+	LANUnavailable   = "lan_unavailable"
+	LANOpenSlots     = "lan_open_slots"
+	LANIncompatible  = "lan_incompatible"
+
+	// Level Editor
+	LevelEditor        = "level_editor"
+	EditorNegative     = "editor_negative"
+	EditorPositive     = "editor_positive"
+	EditorNeutral      = "editor_neutral"
+	EditorBlocked      = "editor_blocked"
+	EditorEmpty        = "editor_empty"
+	EditorPath         = "editor_path"
+	EditorNorthSpawner = "editor_north_spawner"
+	EditorSouthSpawner = "editor_south_spawner"
+	EditorCore         = "editor_core"
+	EditorPlayerStart  = "editor_player_start"
+	EditorWaves        = "editor_waves"
+	EditorName         = "editor_name"
+	EditorTitle        = "editor_title"
+	EditorTileset      = "editor_tileset"
+	EditorNext         = "editor_next"
+	EditorPoints       = "editor_points"
+	EditorWidth        = "editor_width"
+	EditorHeight       = "editor_height"
+	EditorPlayTest     = "editor_play_test"
+	EditorSave         = "editor_save"
+	EditorLoad         = "editor_load"
+	EditorNew          = "editor_new"
+	EditorSaved        = "editor_saved"
+	EditorLoaded       = "editor_loaded"
+	EditorNeedsName    = "editor_needs_name"
+	EditorBadName      = "editor_bad_name"
+	EditorNoSpawners   = "editor_no_spawners"
+	EditorSpawner      = "editor_spawner"
+	EditorPrevSpawner  = "editor_prev_spawner"
+	EditorNextSpawner  = "editor_next_spawner"
+	EditorPrevWave     = "editor_prev_wave"
+	EditorNextWave     = "editor_next_wave"
+	EditorAddWave      = "editor_add_wave"
+	EditorRemoveWave   = "editor_remove_wave"
+	EditorAddSpawn     = "editor_add_spawn"
+	EditorRemoveSpawn  = "editor_remove_spawn"
+	EditorCount        = "editor_count"
+	EditorDelay        = "editor_delay"
+	EditorEnemies      = "editor_enemies"
+	EditorUnknownEnemy = "editor_unknown_enemy"
+
+	// Mods Menu
+	Mods        = "mods"
+	NoMods      = "no_mods"
+	ApplyMods   = "apply_mods"
+	ModsApplied = "mods_applied"
 
 	// Help Screen
 	HelpToolsTurrets = "help_tools_turrets"
@@ -62,10 +120,20 @@ const (
 	HelpToggleHelp   = "help_toggle_help"
 
 	// Messages
-	MessageWantToStart    = "msg_want_to_start"
-	MessageWantToRestart  = "msg_want_to_restart"
-	MessagePressToStart   = "msg_press_to_start"
-	MessageConnectionLost = "msg_connection_lost"
+	MessageWantToStart     = "msg_want_to_start"
+	MessageWantToRestart   = "msg_want_to_restart"
+	MessagePressToStart    = "msg_press_to_start"
+	MessageConnectionLost  = "msg_connection_lost"
+	MessageDesync          = "msg_desync"
+	MessageReconnecting    = "msg_reconnecting"
+	MessageJoined          = "msg_joined"
+	MessageDeniedInvalid   = "msg_denied_invalid"
+	MessageDeniedMode      = "msg_denied_mode"
+	MessageDeniedReach     = "msg_denied_reach"
+	MessageDeniedPoints    = "msg_denied_points"
+	MessageDeniedPlacement = "msg_denied_placement"
+	MessageDeniedNotYours  = "msg_denied_not_yours"
+	MessageDeniedTooFast   = "msg_denied_too_fast"
 
 	// Tools / Turrets
 	Gun       = "gun"
@@ -0,0 +1,138 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Assets are read from a stack of layers: the embedded assets at the bottom, then any enabled mods in their load order, then any asset directories on top of them. A file in a higher layer replaces the same file in those below it, and directory listings include the files of every layer, so levels, entity configs, images, and the like can be replaced or added to without rebuilding.
+
+// layeredFS is a stack of filesystems, where later layers win.
+type layeredFS []fs.FS
+
+var (
+	// embeddedAssets are the assets built into the game.
+	embeddedAssets = mustSub(assets, "assets")
+	// overlayLayers are the asset directories that go over everything else.
+	overlayLayers []fs.FS
+	// assetLayers are all of our asset layers, starting with the embedded assets.
+	assetLayers = layeredFS{embeddedAssets}
+)
+
+// rebuildAssetLayers restacks the asset layers, such as after the loaded mods change.
+func rebuildAssetLayers() {
+	layers := layeredFS{embeddedAssets}
+	for _, m := range Mods {
+		if m.Loaded() {
+			layers = append(layers, m.fs)
+		}
+	}
+	assetLayers = append(layers, overlayLayers...)
+}
+
+func mustSub(f fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(f, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// assetPath turns one of our asset paths, which may start with a slash, into one that fs accepts.
+func assetPath(p string) string {
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+func (l layeredFS) Open(name string) (fs.File, error) {
+	for i := len(l) - 1; i >= 0; i-- {
+		if f, err := l[i].Open(name); err == nil {
+			return f, nil
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (l layeredFS) ReadFile(name string) ([]byte, error) {
+	for i := len(l) - 1; i >= 0; i-- {
+		if b, err := fs.ReadFile(l[i], name); err == nil {
+			return b, nil
+		}
+	}
+	return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir returns the entries of the directory from every layer, sorted by name. Where layers have the same entry, the highest one's is used.
+func (l layeredFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries := make(map[string]fs.DirEntry)
+	found := false
+	for _, layer := range l {
+		list, err := fs.ReadDir(layer, name)
+		if err != nil {
+			continue
+		}
+		found = true
+		for _, e := range list {
+			entries[e.Name()] = e
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	list := make([]fs.DirEntry, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name() < list[j].Name()
+	})
+	return list, nil
+}
+
+// AddAssetDir adds a directory as the new top asset layer. Its layout is the same as the embedded assets, such as levels/001.txt or images/nature/blocked.png.
+func AddAssetDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New(dir + " is not a directory")
+	}
+	overlayLayers = append(overlayLayers, os.DirFS(dir))
+	rebuildAssetLayers()
+	return nil
+}
+
+// UserAssetDir returns the per-user asset directory, such as ~/.config/magnet on Linux.
+func UserAssetDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "magnet"), nil
+}
+
+// OverlayAssets adds the per-user asset directory as a layer, then dir on top of it if it isn't empty. The per-user directory is added even if it doesn't exist yet, so that anything saved to it later, such as from the level editor, still shows up.
+func OverlayAssets(dir string) error {
+	if userDir, err := UserAssetDir(); err == nil {
+		overlayLayers = append(overlayLayers, os.DirFS(userDir))
+		rebuildAssetLayers()
+		fmt.Println("using assets from", userDir)
+	}
+	if dir != "" {
+		if err := AddAssetDir(dir); err != nil {
+			return err
+		}
+		fmt.Println("using assets from", dir)
+	}
+	return nil
+}
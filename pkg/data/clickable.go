@@ -3,15 +3,12 @@ package data
 import (
 	"image/color"
 
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
-	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/kettek/ebijam22/pkg/engine"
 )
 
 // Implements UIComponent interface
 type Clickable struct {
-	image   *ebiten.Image
+	image   *engine.Image
 	x, y    int
 	onClick func()
 }
@@ -21,7 +18,7 @@ func (c *Clickable) SetPos(x, y int) {
 	c.y = y
 }
 
-func (c *Clickable) Image() *ebiten.Image {
+func (c *Clickable) Image() *engine.Image {
 	return c.image
 }
 
@@ -31,7 +28,7 @@ func (c *Clickable) Update() {
 	}
 }
 
-func (c *Clickable) Draw(screen *ebiten.Image, screenOp *ebiten.DrawImageOptions) {
+func (c *Clickable) Draw(screen *engine.Image, screenOp *engine.DrawImageOptions) {
 	if c.image == nil {
 		return
 	}
@@ -43,7 +40,7 @@ func (c *Clickable) Draw(screen *ebiten.Image, screenOp *ebiten.DrawImageOptions
 }
 
 func (c *Clickable) IsClicked() bool {
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+	if engine.IsMouseButtonJustPressed(engine.MouseButtonLeft) {
 		return c.IsHit()
 	}
 
@@ -51,7 +48,7 @@ func (c *Clickable) IsClicked() bool {
 }
 
 func (c *Clickable) IsHit() bool {
-	cursorX, cursorY := ebiten.CursorPosition()
+	cursorX, cursorY := engine.CursorPosition()
 	minX, maxX := c.x-c.image.Bounds().Dx()/2, c.x+c.image.Bounds().Dx()/2
 	minY, maxY := c.y-c.image.Bounds().Dy()/2, c.y+c.image.Bounds().Dy()/2
 	if int(minX) < cursorX && cursorX < int(maxX) {
@@ -87,7 +84,7 @@ func (bgm *BGMIcon) Update() {
 	}
 }
 
-func (bgm *BGMIcon) Draw(screen *ebiten.Image, screenOp *ebiten.DrawImageOptions) {
+func (bgm *BGMIcon) Draw(screen *engine.Image, screenOp *engine.DrawImageOptions) {
 	if BGM.Muted {
 		screenOp.ColorM.Scale(1.0, 1.0, 1.0, 0.5)
 	}
@@ -119,7 +116,7 @@ func (sfx *SFXIcon) Update() {
 	}
 }
 
-func (sfx *SFXIcon) Draw(screen *ebiten.Image, screenOp *ebiten.DrawImageOptions) {
+func (sfx *SFXIcon) Draw(screen *engine.Image, screenOp *engine.DrawImageOptions) {
 	if SFX.Muted {
 		screenOp.ColorM.Scale(1.0, 1.0, 1.0, 0.5)
 	}
@@ -142,8 +139,8 @@ type Button struct {
 
 func NewButton(x, y int, code string, onClick func()) *Button {
 	txtString := GiveMeString(code)
-	bounds := text.BoundString(NormalFace, txtString)
-	bgImage := ebiten.NewImage(bounds.Dx(), bounds.Dy())
+	bounds := engine.BoundString(NormalFace, txtString)
+	bgImage := engine.NewImage(bounds.Dx(), bounds.Dy())
 
 	return &Button{
 		Clickable: Clickable{
@@ -157,7 +154,7 @@ func NewButton(x, y int, code string, onClick func()) *Button {
 	}
 }
 
-func NewImageButton(x, y int, image *ebiten.Image, onClick func()) *Button {
+func NewImageButton(x, y int, image *engine.Image, onClick func()) *Button {
 	return &Button{
 		Clickable: Clickable{
 			x:       x,
@@ -172,7 +169,7 @@ func (b *Button) Update() {
 	if b.IsClicked() {
 		b.onClick()
 		// This isn't the right thing to do, but it's easier to always turn the cursor back on click. (this prevents the pointer cursor being set when a button causes a travel)
-		ebiten.SetCursorShape(ebiten.CursorShapeDefault)
+		engine.SetCursorShape(engine.CursorShapeDefault)
 		return
 	}
 
@@ -180,12 +177,12 @@ func (b *Button) Update() {
 		if b.IsHit() {
 			if !b.isHovered {
 				b.isHovered = true
-				ebiten.SetCursorShape(ebiten.CursorShapePointer)
+				engine.SetCursorShape(engine.CursorShapePointer)
 			}
 		} else {
 			if b.isHovered {
 				b.isHovered = false
-				ebiten.SetCursorShape(ebiten.CursorShapeDefault)
+				engine.SetCursorShape(engine.CursorShapeDefault)
 			}
 		}
 	}
@@ -196,7 +193,7 @@ func (b *Button) IsClicked() bool {
 		return b.Clickable.IsClicked()
 	}
 
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+	if engine.IsMouseButtonJustPressed(engine.MouseButtonLeft) {
 		return b.IsHit()
 	}
 
@@ -208,8 +205,8 @@ func (b *Button) IsHit() bool {
 		return b.Clickable.IsHit()
 	}
 
-	bounds := text.BoundString(NormalFace, *b.text)
-	cursorX, cursorY := ebiten.CursorPosition()
+	bounds := engine.BoundString(NormalFace, *b.text)
+	cursorX, cursorY := engine.CursorPosition()
 	minX, maxX := b.OffsetX+b.x-bounds.Dx()/2, b.OffsetX+b.x+bounds.Dx()/2
 	minY, maxY := b.OffsetY+b.y-bounds.Dy()/2, b.OffsetY+b.y+bounds.Dy()/2
 	if int(minX) < cursorX && cursorX < int(maxX) {
@@ -220,7 +217,7 @@ func (b *Button) IsHit() bool {
 	return false
 }
 
-func (b *Button) Draw(screen *ebiten.Image, screenOp *ebiten.DrawImageOptions) {
+func (b *Button) Draw(screen *engine.Image, screenOp *engine.DrawImageOptions) {
 	if b.text == nil {
 		b.Clickable.Draw(screen, screenOp)
 		return
@@ -249,7 +246,7 @@ func (b *Button) Draw(screen *ebiten.Image, screenOp *ebiten.DrawImageOptions) {
 	)
 
 	if b.Underline {
-		ebitenutil.DrawLine(
+		engine.DrawLine(
 			screen, // Wanna see a magic (number) trick?
 			float64(b.OffsetX+(b.x)-bounds.Dx()/2)+4,
 			float64(b.OffsetY+(b.y)+bounds.Dy())-3,
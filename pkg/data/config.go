@@ -1,6 +1,7 @@
 package data
 
 import (
+	"fmt"
 	"path"
 )
 
@@ -11,43 +12,72 @@ type Config interface {
 var (
 	PlayerInit    EntityConfig
 	Player2Init   EntityConfig
+	PlayerConfigs []EntityConfig // All player configs, in player order. The first two are PlayerInit and Player2Init.
 	CoreConfig    EntityConfig
 	TurretConfigs map[string]EntityConfig
 	EnemyConfigs  map[string]EntityConfig
 )
 
+// LoadConfigurations loads every entity config, stopping at the first that has problems.
 func LoadConfigurations() error {
-	// Load the players configuration
-	config, err := NewPlayerConfig(1)
-	PlayerInit = config
-	if err != nil {
-		return err
+	if errs := loadConfigurations(false); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// CheckConfigurations loads every entity config like LoadConfigurations, but carries on past problems and returns all of them, one per line of a config.
+func CheckConfigurations() []error {
+	return loadConfigurations(true)
+}
+
+func loadConfigurations(keepGoing bool) (errs []error) {
+	// fail records err, and says whether to stop loading.
+	fail := func(err error) bool {
+		if err == nil {
+			return false
+		}
+		if configErrs, ok := err.(ConfigErrors); ok && keepGoing {
+			for _, e := range configErrs {
+				errs = append(errs, e)
+			}
+		} else {
+			errs = append(errs, err)
+		}
+		return !keepGoing
 	}
 
-	config, err = NewPlayerConfig(2)
-	Player2Init = config
-	if err != nil {
-		return err
+	// Load the players configuration
+	PlayerConfigs = nil
+	for i := 1; i <= 4; i++ {
+		config, err := NewPlayerConfig(i)
+		if fail(err) {
+			return
+		}
+		PlayerConfigs = append(PlayerConfigs, config)
 	}
+	PlayerInit = PlayerConfigs[0]
+	Player2Init = PlayerConfigs[1]
 
 	// Load the core configuration
-	CoreConfig, err = NewCoreConfig()
-	if err != nil {
-		return err
+	config, err := NewCoreConfig()
+	if fail(err) {
+		return
 	}
+	CoreConfig = config
 
 	// Traverse the turret config folder and load all turret configurations
 	TurretConfigs = make(map[string]EntityConfig)
 	turretFiles, err := GetPathFiles(path.Join("entities", "turrets"))
 	println("Loading turret configs:")
-	if err != nil {
-		return err
+	if fail(err) {
+		return
 	}
 	for _, fileName := range turretFiles {
 		println("\t", fileName)
 		TurretConfigs[fileName], err = NewTurretConfig(fileName)
-		if err != nil {
-			return err
+		if fail(err) {
+			return
 		}
 	}
 
@@ -55,15 +85,21 @@ func LoadConfigurations() error {
 	EnemyConfigs = make(map[string]EntityConfig)
 	enemyFiles, err := GetPathFiles(path.Join("entities", "enemies"))
 	println("Loading enemy configs:")
-	if err != nil {
-		return err
+	if fail(err) {
+		return
 	}
 	for _, fileName := range enemyFiles {
 		println("\t", fileName)
 		EnemyConfigs[fileName], err = NewEnemyConfig(fileName)
-		if err != nil {
-			return err
+		if fail(err) {
+			return
 		}
 	}
-	return nil
+
+	// Mods are merged by their load order, so the last one to define something wins. Let folks know when that happens.
+	ModConflicts = findModConflicts()
+	for _, c := range ModConflicts {
+		fmt.Println("mod conflict:", c)
+	}
+	return
 }
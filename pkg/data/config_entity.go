@@ -3,12 +3,13 @@ package data
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"path"
 	"strconv"
 	"strings"
 
-	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/kettek/ebijam22/pkg/engine"
 )
 
 type EntityConfig struct {
@@ -27,163 +28,304 @@ type EntityConfig struct {
 	Magnetic         bool
 	MagnetStrength   float64
 	MagnetRadius     float64
-	Images           []*ebiten.Image
-	LossImages       []*ebiten.Image
-	VictoryImages    []*ebiten.Image
-	WalkImages       []*ebiten.Image
-	HeadImages       []*ebiten.Image
+	Images           []*engine.Image
+	LossImages       []*engine.Image
+	VictoryImages    []*engine.Image
+	WalkImages       []*engine.Image
+	HeadImages       []*engine.Image
 	ColorMultiplier  [3]float64
 	ToolbeltOrder    int
 	Description      string
 }
 
+// EntityKind is the sort of entity a config is for, which decides the keys it needs.
+type EntityKind int
+
+const (
+	TurretEntity EntityKind = iota
+	EnemyEntity
+	PlayerEntity
+	CoreEntity
+)
+
+func (k EntityKind) String() string {
+	switch k {
+	case TurretEntity:
+		return "turret"
+	case EnemyEntity:
+		return "enemy"
+	case PlayerEntity:
+		return "player"
+	case CoreEntity:
+		return "core"
+	}
+	return "entity"
+}
+
+// requiredEntityKeys are the keys each kind of entity can't do without.
+var requiredEntityKeys = map[EntityKind]string{
+	TurretEntity: "TCDRXONIiod",
+	EnemyEntity:  "TCHSrWV",
+	PlayerEntity: "THDXONSIWL",
+	CoreEntity:   "THI",
+}
+
+// attackTypes are the attack types turrets can have. Polarizers and reflectors can be combined with a '&'.
+var attackTypes = map[string]bool{
+	"normal":    true,
+	"beam":      true,
+	"polarizer": true,
+	"reflector": true,
+}
+
+// ConfigError is a problem with a line of a config file.
+type ConfigError struct {
+	File string
+	Line int // 0 if the problem is with the file as a whole.
+	Msg  string
+}
+
+func (e ConfigError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// ConfigWarning is something in a config file that is likely a mistake, but doesn't stop it from loading.
+type ConfigWarning struct {
+	ConfigError
+}
+
+func (w ConfigWarning) Error() string {
+	e := w.ConfigError
+	e.Msg = "warning: " + e.Msg
+	return e.Error()
+}
+
+// ConfigErrors are every problem found in a config file.
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	var lines []string
+	for _, err := range e {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// entityKey parses a key's value into the config.
+type entityKey func(e *EntityConfig, value string) error
+
+func intKey(dst func(e *EntityConfig) *int) entityKey {
+	return func(e *EntityConfig, value string) (err error) {
+		if *dst(e), err = strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+		return nil
+	}
+}
+
+func floatKey(dst func(e *EntityConfig) *float64) entityKey {
+	return func(e *EntityConfig, value string) (err error) {
+		if *dst(e), err = strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		return nil
+	}
+}
+
+func imagesKey(dst func(e *EntityConfig) *[]*engine.Image) entityKey {
+	return func(e *EntityConfig, value string) error {
+		// Load images using prefix in value
+		images, err := ReadImagesByPrefix(value)
+		if err != nil {
+			return err
+		}
+		if len(images) == 0 {
+			return fmt.Errorf("no images start with %q", value)
+		}
+		for _, image := range images {
+			img := engine.NewImageFromImage(image)
+			*dst(e) = append(*dst(e), img)
+		}
+		return nil
+	}
+}
+
+// entityKeys are every key an entity config can have.
+var entityKeys = map[byte]entityKey{
+	'T': func(e *EntityConfig, value string) error {
+		if value == "" {
+			return errors.New("title is empty")
+		}
+		e.Title = strings.ToLower(value)
+		return nil
+	},
+	'C': intKey(func(e *EntityConfig) *int { return &e.Points }),
+	'H': intKey(func(e *EntityConfig) *int { return &e.Health }),
+	'D': intKey(func(e *EntityConfig) *int { return &e.Damage }),
+	'R': floatKey(func(e *EntityConfig) *float64 { return &e.AttackRange }),
+	'X': floatKey(func(e *EntityConfig) *float64 { return &e.AttackRate }),
+	'A': func(e *EntityConfig, value string) error {
+		value = strings.ToLower(value)
+		for _, p := range strings.Split(value, "&") {
+			if !attackTypes[p] {
+				return fmt.Errorf("%q is not an attack type", p)
+			}
+		}
+		e.AttackType = value
+		return nil
+	},
+	'O': floatKey(func(e *EntityConfig) *float64 { return &e.ProjecticleSpeed }),
+	'N': intKey(func(e *EntityConfig) *int { return &e.ProjecticleNum }),
+	'S': floatKey(func(e *EntityConfig) *float64 { return &e.Speed }),
+	'r': floatKey(func(e *EntityConfig) *float64 { return &e.Radius }),
+	'P': func(e *EntityConfig, value string) error {
+		switch value {
+		case "positive":
+			e.Polarity = PositivePolarity
+		case "negative":
+			e.Polarity = NegativePolarity
+		case "neutral":
+			e.Polarity = NeutralPolarity
+		default:
+			return fmt.Errorf("%q is not positive, negative, or neutral", value)
+		}
+		return nil
+	},
+	'M': func(e *EntityConfig, value string) (err error) {
+		if e.Magnetic, err = strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		return nil
+	},
+	'Y': floatKey(func(e *EntityConfig) *float64 { return &e.MagnetStrength }),
+	'Z': floatKey(func(e *EntityConfig) *float64 { return &e.MagnetRadius }),
+	'I': imagesKey(func(e *EntityConfig) *[]*engine.Image { return &e.Images }),
+	'W': imagesKey(func(e *EntityConfig) *[]*engine.Image { return &e.WalkImages }),
+	'L': imagesKey(func(e *EntityConfig) *[]*engine.Image { return &e.LossImages }),
+	'V': imagesKey(func(e *EntityConfig) *[]*engine.Image { return &e.VictoryImages }),
+	'i': imagesKey(func(e *EntityConfig) *[]*engine.Image { return &e.HeadImages }),
+	'c': func(e *EntityConfig, value string) error {
+		parts := strings.Split(value, ",")
+		if len(parts) != 3 {
+			return fmt.Errorf("%q is not three numbers separated by commas", value)
+		}
+		for i, p := range parts {
+			v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+			if err != nil {
+				return fmt.Errorf("%q is not a number", strings.TrimSpace(p))
+			}
+			e.ColorMultiplier[i] = v
+		}
+		return nil
+	},
+	'o': intKey(func(e *EntityConfig) *int { return &e.ToolbeltOrder }),
+	'd': func(e *EntityConfig, value string) error {
+		e.Description = value
+		return nil
+	},
+}
+
+// LoadFromFile loads the config at the given path within entities. The kind is worked out from where it is.
 func (e *EntityConfig) LoadFromFile(p string) error {
-	b, err := ReadFile(path.Join("entities", p+".txt"))
+	kind := CoreEntity
+	switch {
+	case strings.HasPrefix(p, "turrets/"):
+		kind = TurretEntity
+	case strings.HasPrefix(p, "enemies/"):
+		kind = EnemyEntity
+	case strings.HasPrefix(p, "player"):
+		kind = PlayerEntity
+	}
+	return e.LoadFromFileAs(p, kind)
+}
+
+// LoadFromFileAs loads the config at the given path within entities as the given kind of entity. Every problem with it is returned as ConfigErrors.
+func (e *EntityConfig) LoadFromFileAs(p string, kind EntityKind) error {
+	file := path.Join("entities", p+".txt")
+	b, err := ReadFile(file)
 	if err != nil {
 		return err
 	}
+	return e.LoadFromBytes(file, b, kind)
+}
+
+// LoadFromBytes loads a config from its contents, using file to say where any problems are.
+func (e *EntityConfig) LoadFromBytes(file string, b []byte, kind EntityKind) error {
+	var errs ConfigErrors
+	seen := make(map[byte]int)
 
 	scanner := bufio.NewScanner(bytes.NewReader(b))
+	line := 0
 	for scanner.Scan() {
-		t := scanner.Text()
+		line++
+		t := strings.TrimRight(scanner.Text(), " \t\r")
+		if t == "" {
+			continue
+		}
+		key := t[0]
+		parse, ok := entityKeys[key]
+		if !ok || (len(t) > 1 && t[1] != ' ' && t[1] != '\t') {
+			field := strings.Fields(t)[0]
+			errs = append(errs, ConfigError{file, line, fmt.Sprintf("unknown key %q", field)})
+			continue
+		}
+		if first, ok := seen[key]; ok {
+			errs = append(errs, ConfigError{file, line, fmt.Sprintf("duplicate key %q, first given on line %d", key, first)})
+			continue
+		}
+		seen[key] = line
 		value := strings.TrimSpace(t[1:])
-		switch t[0] {
-		case 'T':
-			e.Title = strings.ToLower(value)
-		case 'C':
-			e.Points, err = strconv.Atoi(value)
-		case 'H':
-			e.Health, err = strconv.Atoi(value)
-		case 'D':
-			e.Damage, err = strconv.Atoi(value)
-		case 'R':
-			e.AttackRange, err = strconv.ParseFloat(value, 64)
-		case 'X':
-			e.AttackRate, err = strconv.ParseFloat(value, 64)
-		case 'A':
-			e.AttackType = strings.ToLower(value)
-		case 'O':
-			e.ProjecticleSpeed, err = strconv.ParseFloat(value, 64)
-		case 'N':
-			e.ProjecticleNum, err = strconv.Atoi(value)
-		case 'S':
-			e.Speed, err = strconv.ParseFloat(value, 64)
-		case 'r':
-			e.Radius, err = strconv.ParseFloat(value, 64)
-		case 'P':
-			switch value {
-			case "positive":
-				e.Polarity = PositivePolarity
-			case "negative":
-				e.Polarity = NegativePolarity
-			default:
-				fallthrough
-			case "neutral":
-				e.Polarity = NeutralPolarity
-			}
-		case 'M':
-			e.Magnetic, err = strconv.ParseBool(value)
-		case 'Y':
-			e.MagnetStrength, err = strconv.ParseFloat(value, 64)
-		case 'Z':
-			e.MagnetRadius, err = strconv.ParseFloat(value, 64)
-		case 'I':
-			// Load images using prefix in value
-			images, err := ReadImagesByPrefix(value)
-			if err != nil {
-				return err
-			}
-			for _, image := range images {
-				img := ebiten.NewImageFromImage(image)
-				e.Images = append(e.Images, img)
-			}
-		case 'W':
-			// Load images using prefix in value
-			images, err := ReadImagesByPrefix(value)
-			if err != nil {
-				return err
-			}
-			for _, image := range images {
-				img := ebiten.NewImageFromImage(image)
-				e.WalkImages = append(e.WalkImages, img)
-			}
-		case 'L':
-			// Load images using prefix in value
-			images, err := ReadImagesByPrefix(value)
-			if err != nil {
-				return err
-			}
-			for _, image := range images {
-				img := ebiten.NewImageFromImage(image)
-				e.LossImages = append(e.LossImages, img)
-			}
-		case 'V':
-			// Load images using prefix in value
-			images, err := ReadImagesByPrefix(value)
-			if err != nil {
-				return err
-			}
-			for _, image := range images {
-				img := ebiten.NewImageFromImage(image)
-				e.VictoryImages = append(e.VictoryImages, img)
-			}
-		case 'i':
-			// Load images using prefix in value
-			images, err := ReadImagesByPrefix(value)
-			if err != nil {
-				return err
-			}
-			for _, image := range images {
-				img := ebiten.NewImageFromImage(image)
-				e.HeadImages = append(e.HeadImages, img)
-			}
-		case 'c':
-			parts := strings.Split(value, ",")
-			for i, p := range parts {
-				p = strings.TrimSpace(p)
-				if i == 0 {
-					v, _ := strconv.ParseFloat(p, 64)
-					e.ColorMultiplier[0] = v
-				} else if i == 1 {
-					v, _ := strconv.ParseFloat(p, 64)
-					e.ColorMultiplier[1] = v
-				} else if i == 2 {
-					v, _ := strconv.ParseFloat(p, 64)
-					e.ColorMultiplier[2] = v
-				}
-			}
-		case 'o':
-			e.ToolbeltOrder, err = strconv.Atoi(value)
-		case 'd':
-			e.Description = value
+		if err := parse(e, value); err != nil {
+			errs = append(errs, ConfigError{file, line, fmt.Sprintf("%c: %s", key, err)})
 		}
-		if err != nil {
-			return err
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, ConfigError{file, line, err.Error()})
+	}
+
+	for _, key := range []byte(requiredEntityKeys[kind]) {
+		if _, ok := seen[key]; !ok {
+			errs = append(errs, ConfigError{file, 0, fmt.Sprintf("missing %q, which every %s needs", key, kind)})
 		}
 	}
+
+	// Turrets and enemies are known by their file name, so the title has to agree.
+	if kind == TurretEntity || kind == EnemyEntity {
+		name := strings.TrimSuffix(path.Base(file), ".txt")
+		if line, ok := seen['T']; ok && e.Title != name {
+			errs = append(errs, ConfigError{file, line, fmt.Sprintf("title %q doesn't match the file name %q", e.Title, name)})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
 	return nil
 }
 
 func NewEnemyConfig(p string) (EntityConfig, error) {
 	config := EntityConfig{}
-	err := config.LoadFromFile(path.Join("enemies", p))
+	err := config.LoadFromFileAs(path.Join("enemies", p), EnemyEntity)
 	return config, err
 }
 
 func NewTurretConfig(p string) (EntityConfig, error) {
 	config := EntityConfig{}
-	err := config.LoadFromFile(path.Join("turrets", p))
+	err := config.LoadFromFileAs(path.Join("turrets", p), TurretEntity)
 	return config, err
 }
 
 func NewPlayerConfig(i int) (EntityConfig, error) {
 	config := EntityConfig{}
-	err := config.LoadFromFile(fmt.Sprintf("player%d", i))
+	err := config.LoadFromFileAs(fmt.Sprintf("player%d", i), PlayerEntity)
 	return config, err
 }
 
 func NewCoreConfig() (EntityConfig, error) {
 	config := EntityConfig{}
-	err := config.LoadFromFile("core")
+	err := config.LoadFromFileAs("core", CoreEntity)
 	return config, err
 }
@@ -0,0 +1,197 @@
+package data
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// errorLines splits what a config error says into one line per problem.
+func errorLines(err error) []string {
+	if err == nil {
+		return nil
+	}
+	return strings.Split(err.Error(), "\n")
+}
+
+func TestEntityConfigErrors(t *testing.T) {
+	const core = "entities/core.txt"
+	tests := []struct {
+		name string
+		file string
+		kind EntityKind
+		in   string
+		want []string
+	}{
+		{
+			name: "empty lines",
+			file: core,
+			kind: CoreEntity,
+			in:   "\nT core\n\n  \nH 50\n\t\nI core\n\n",
+		},
+		{
+			name: "unknown keys",
+			file: core,
+			kind: CoreEntity,
+			in:   "T core\nH 50\nI core\nQ 1\nHealth 50\n",
+			want: []string{
+				`entities/core.txt:4: unknown key "Q"`,
+				`entities/core.txt:5: unknown key "Health"`,
+			},
+		},
+		{
+			name: "duplicate keys",
+			file: core,
+			kind: CoreEntity,
+			in:   "T core\nH 50\nI core\nH 60\n",
+			want: []string{
+				`entities/core.txt:4: duplicate key 'H', first given on line 2`,
+			},
+		},
+		{
+			name: "bad values",
+			file: core,
+			kind: CoreEntity,
+			in:   "T core\nH fifty\nI core\nr big\nM maybe\nP sideways\n",
+			want: []string{
+				`entities/core.txt:2: H: "fifty" is not a whole number`,
+				`entities/core.txt:4: r: "big" is not a number`,
+				`entities/core.txt:5: M: "maybe" is not true or false`,
+				`entities/core.txt:6: P: "sideways" is not positive, negative, or neutral`,
+			},
+		},
+		{
+			name: "bad c",
+			file: core,
+			kind: CoreEntity,
+			in:   "T core\nH 50\nI core\nc 1, x ,1\n",
+			want: []string{
+				`entities/core.txt:4: c: "x" is not a number`,
+			},
+		},
+		{
+			name: "short c",
+			file: core,
+			kind: CoreEntity,
+			in:   "T core\nH 50\nI core\nc 1,1\n",
+			want: []string{
+				`entities/core.txt:4: c: "1,1" is not three numbers separated by commas`,
+			},
+		},
+		{
+			name: "bad attack type",
+			file: core,
+			kind: CoreEntity,
+			in:   "T core\nH 50\nI core\nA polarizer&laser\n",
+			want: []string{
+				`entities/core.txt:4: A: "laser" is not an attack type`,
+			},
+		},
+		{
+			name: "missing images",
+			file: core,
+			kind: CoreEntity,
+			in:   "T core\nH 50\nI no-such-image\n",
+			want: []string{
+				`entities/core.txt:3: I: no images start with "no-such-image"`,
+			},
+		},
+		{
+			name: "missing turret keys",
+			file: "entities/turrets/basic.txt",
+			kind: TurretEntity,
+			in:   "T basic\nC 10\n",
+			want: []string{
+				`entities/turrets/basic.txt: missing 'D', which every turret needs`,
+				`entities/turrets/basic.txt: missing 'R', which every turret needs`,
+				`entities/turrets/basic.txt: missing 'X', which every turret needs`,
+				`entities/turrets/basic.txt: missing 'O', which every turret needs`,
+				`entities/turrets/basic.txt: missing 'N', which every turret needs`,
+				`entities/turrets/basic.txt: missing 'I', which every turret needs`,
+				`entities/turrets/basic.txt: missing 'i', which every turret needs`,
+				`entities/turrets/basic.txt: missing 'o', which every turret needs`,
+				`entities/turrets/basic.txt: missing 'd', which every turret needs`,
+			},
+		},
+		{
+			name: "missing enemy keys",
+			file: "entities/enemies/walker.txt",
+			kind: EnemyEntity,
+			in:   "T walker\nH 10\nS 1\n",
+			want: []string{
+				`entities/enemies/walker.txt: missing 'C', which every enemy needs`,
+				`entities/enemies/walker.txt: missing 'r', which every enemy needs`,
+				`entities/enemies/walker.txt: missing 'W', which every enemy needs`,
+				`entities/enemies/walker.txt: missing 'V', which every enemy needs`,
+			},
+		},
+		{
+			name: "missing player keys",
+			file: "entities/player1.txt",
+			kind: PlayerEntity,
+			in:   "T player\nH 100\nI player-idle\nW player-walk\nL player-cry\n",
+			want: []string{
+				`entities/player1.txt: missing 'D', which every player needs`,
+				`entities/player1.txt: missing 'X', which every player needs`,
+				`entities/player1.txt: missing 'O', which every player needs`,
+				`entities/player1.txt: missing 'N', which every player needs`,
+				`entities/player1.txt: missing 'S', which every player needs`,
+			},
+		},
+		{
+			name: "missing core keys",
+			file: core,
+			kind: CoreEntity,
+			in:   "",
+			want: []string{
+				`entities/core.txt: missing 'T', which every core needs`,
+				`entities/core.txt: missing 'H', which every core needs`,
+				`entities/core.txt: missing 'I', which every core needs`,
+			},
+		},
+		{
+			name: "title that isn't the file name",
+			file: "entities/turrets/basic.txt",
+			kind: TurretEntity,
+			in:   "T BASIC2\nC 10\nD 2\nR 50\nX 1\nO 1\nN 1\nI turret-basic\ni turret-head2\no 2\nd It shoots.\n",
+			want: []string{
+				`entities/turrets/basic.txt:1: title "basic2" doesn't match the file name "basic"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e EntityConfig
+			got := errorLines(e.LoadFromBytes(tt.file, []byte(tt.in), tt.kind))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+// TestEntityConfigs loads every bundled config as the game does.
+func TestEntityConfigs(t *testing.T) {
+	for _, dir := range []string{"turrets", "enemies"} {
+		entries, err := ReadDir("entities/" + dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			name := strings.TrimSuffix(entry.Name(), ".txt")
+			if name == entry.Name() {
+				continue
+			}
+			var e EntityConfig
+			if err := e.LoadFromFile(dir + "/" + name); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+	for _, p := range []string{"player1", "player2", "player3", "player4", "core"} {
+		var e EntityConfig
+		if err := e.LoadFromFile(p); err != nil {
+			t.Error(err)
+		}
+	}
+}
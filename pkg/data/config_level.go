@@ -3,6 +3,7 @@ package data
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"path"
 	"strconv"
 	"strings"
@@ -17,6 +18,8 @@ type LevelConfig struct {
 	Cells   [][]Cell
 	Waves   []*Wave
 	Points  int
+	// Warnings are anything odd found when the level was loaded that it loaded anyway, such as unknown keys or cells.
+	Warnings []ConfigWarning
 }
 
 func (l *LevelConfig) newCell(r rune) (c Cell) {
@@ -52,89 +55,187 @@ func (l *LevelConfig) newCell(r rune) (c Cell) {
 	return c
 }
 
+// isCellRune returns if the rune stands for a cell.
+func (l *LevelConfig) isCellRune(r rune) bool {
+	return strings.ContainsRune("NSvC@#.,_ +-", r)
+}
+
+// cellRune returns the rune that newCell turns into the given cell.
+func (l *LevelConfig) cellRune(c Cell) rune {
+	switch c.Kind {
+	case NorthSpawnCell:
+		return 'N'
+	case SouthSpawnCell:
+		return 'S'
+	case PathCell:
+		return 'v'
+	case CoreCell:
+		return 'C'
+	case PlayerCell:
+		return '@'
+	case BlockedCell:
+		return '#'
+	case EnemyPositiveCell:
+		return '+'
+	case EnemyNegativeCell:
+		return '-'
+	case NoneCell:
+		switch c.Polarity {
+		case NegativePolarity:
+			return '.'
+		case PositivePolarity:
+			return ','
+		}
+		return '_'
+	}
+	return ' '
+}
+
+// CellRune returns the rune for the cell at x, y. Anything outside of the level, including past the end of a short row, is empty.
+func (l *LevelConfig) CellRune(x, y int) rune {
+	if y < 0 || y >= len(l.Cells) || x < 0 || x >= len(l.Cells[y]) {
+		return ' '
+	}
+	return l.cellRune(l.Cells[y][x])
+}
+
+// SetCellRune sets the cell at x, y to the one the rune stands for. Cells outside of the level are ignored.
+func (l *LevelConfig) SetCellRune(x, y int, r rune) {
+	if y < 0 || y >= len(l.Cells) || x < 0 || x >= len(l.Cells[y]) {
+		return
+	}
+	l.Cells[y][x] = l.newCell(r)
+}
+
+// Resize makes the level the given size, filling any new space with empty cells. Short rows get filled out too.
+func (l *LevelConfig) Resize(w, h int) {
+	cells := make([][]Cell, h)
+	for y := range cells {
+		cells[y] = make([]Cell, w)
+		for x := range cells[y] {
+			if y < len(l.Cells) && x < len(l.Cells[y]) {
+				cells[y][x] = l.Cells[y][x]
+			} else {
+				cells[y][x] = l.newCell(' ')
+			}
+		}
+	}
+	l.Cells = cells
+	l.Width = w
+	l.Height = h
+}
+
+// Bytes returns the level in the same format that LoadFromFile reads.
+func (l *LevelConfig) Bytes() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "T %s\n", l.Title)
+	if l.Tileset != "" {
+		fmt.Fprintf(&b, "S %s\n", l.Tileset)
+	}
+	if l.Points != 0 {
+		fmt.Fprintf(&b, "P %d\n", l.Points)
+	}
+	for _, w := range l.Waves {
+		fmt.Fprintf(&b, "W %s\n", w.String())
+	}
+	if l.Next != "" {
+		fmt.Fprintf(&b, "N %s\n", l.Next)
+	}
+	b.WriteString("\n")
+	for y, r := range l.Cells {
+		for x := range r {
+			b.WriteRune(l.CellRune(x, y))
+		}
+		b.WriteString("\n")
+	}
+	return b.Bytes()
+}
+
 func (l *LevelConfig) LoadFromFile(p string) (err error) {
-	b, err := ReadFile(path.Join("levels", p+".txt"))
+	file := path.Join("levels", p+".txt")
+	b, err := ReadFile(file)
 	if err != nil {
 		return err
 	}
+	return l.LoadFromBytes(file, b)
+}
 
+// LoadFromBytes reads a level from its contents, for levels that don't come from the assets. Problems that keep it from being played are returned as ConfigErrors, while anything it can do without, such as unknown keys or cells, is only kept in Warnings. Both use file to say where they are.
+func (l *LevelConfig) LoadFromBytes(file string, b []byte) error {
+	var errs ConfigErrors
+	l.Warnings = nil
+	warn := func(line int, format string, a ...interface{}) {
+		l.Warnings = append(l.Warnings, ConfigWarning{ConfigError{file, line, fmt.Sprintf(format, a...)}})
+	}
+	seen := make(map[byte]int)
 	parsingHeader := true
 
 	scanner := bufio.NewScanner(bytes.NewReader(b))
+	line := 0
 	for scanner.Scan() {
-		t := scanner.Text()
+		line++
+		t := strings.TrimRight(scanner.Text(), "\r")
 		if parsingHeader {
 			if len(t) == 0 {
 				parsingHeader = false
-			} else if t[0] == 'T' {
-				l.Title = strings.TrimSpace(t[1:])
-			} else if t[0] == 'S' {
-				l.Tileset = strings.TrimSpace(t[1:])
-			} else if t[0] == 'N' {
-				l.Next = strings.TrimSpace(t[1:])
-			} else if t[0] == 'P' {
-				l.Points, err = strconv.Atoi(strings.TrimSpace(t[1:]))
-			} else if t[0] == 'W' {
-				s := strings.TrimSpace(t[1:])
-				waveStrs := strings.Split(s, ";")
-				var firstWave *Wave
-				var lastWave *Wave
-				for _, w := range waveStrs {
-					var lastSpawn *SpawnList
-					wave := &Wave{}
-					spawnStrs := strings.Split(w, ",")
-					for _, s := range spawnStrs {
-						var amount int
-						var tickDelay int
-						var enemies []string
-						amountAndList := strings.Split(s, " ")
-						amountStr := amountAndList[0]
-						// Get amount and tick delay.
-						amountAndTickDelayStrs := strings.Split(amountStr, "@")
-						if len(amountAndTickDelayStrs) == 1 {
-							// No tick specified.
-							amount, _ = strconv.Atoi(amountAndTickDelayStrs[0])
-							tickDelay = 20
-						} else {
-							amount, _ = strconv.Atoi(amountAndTickDelayStrs[0])
-							tickDelay, _ = strconv.Atoi(amountAndTickDelayStrs[1])
-						}
-						// Get enemies list.
-						listStr := amountAndList[1]
-						enemies = strings.Split(listStr, "&")
-						sl := &SpawnList{
-							Kinds:     enemies,
-							Spawnrate: tickDelay,
-							Count:     amount,
-						}
-						if lastSpawn == nil {
-							wave.Spawns = sl
-						} else {
-							lastSpawn.Next = sl
-						}
-						lastSpawn = sl
-					}
-					if firstWave == nil {
-						firstWave = wave
-					}
-					if lastWave != nil {
-						lastWave.Next = wave
-					}
-					lastWave = wave
+				continue
+			}
+			key := t[0]
+			value := strings.TrimSpace(t[1:])
+			if len(t) > 1 && t[1] != ' ' && t[1] != '\t' {
+				warn(line, "unknown key %q", strings.Fields(t)[0])
+				continue
+			}
+			// Every spawner gets its own W line, but everything else should only be given once. The last one wins.
+			if first, ok := seen[key]; ok && key != 'W' {
+				warn(line, "duplicate key %q, replacing the one on line %d", key, first)
+			}
+			seen[key] = line
+			switch key {
+			case 'T':
+				l.Title = value
+			case 'S':
+				l.Tileset = value
+			case 'N':
+				l.Next = value
+			case 'P':
+				points, err := strconv.Atoi(value)
+				if err != nil {
+					errs = append(errs, ConfigError{file, line, fmt.Sprintf("P: %q is not a whole number", value)})
+				}
+				l.Points = points
+			case 'W':
+				wave, err := ParseWave(value)
+				if err != nil {
+					errs = append(errs, ConfigError{file, line, fmt.Sprintf("W: %s", err)})
 				}
-				l.Waves = append(l.Waves, firstWave)
+				l.Waves = append(l.Waves, wave)
+			default:
+				warn(line, "unknown key %q", key)
 			}
 		} else {
 			if len(t) > l.Width {
 				l.Width = len(t)
 			}
 			l.Cells = append(l.Cells, []Cell{})
-			for _, r := range t {
+			for x, r := range t {
+				if !l.isCellRune(r) {
+					warn(line, "unknown cell %q in column %d, it will be open ground", r, x+1)
+				}
 				l.Cells[l.Height] = append(l.Cells[l.Height], l.newCell(r))
 			}
 			l.Height++
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, ConfigError{file, line, err.Error()})
+	}
+	if l.Height == 0 {
+		errs = append(errs, ConfigError{file, 0, "no cells, they come after a blank line following the header"})
+	}
 
+	if len(errs) > 0 {
+		return errs
+	}
 	return nil
 }
@@ -0,0 +1,122 @@
+package data
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// levelLines is everything wrong with a level as it's printed, problems first.
+func levelLines(l LevelConfig, err error) []string {
+	lines := errorLines(err)
+	for _, w := range l.Warnings {
+		lines = append(lines, w.Error())
+	}
+	return lines
+}
+
+func TestLevelConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{
+			name: "empty lines",
+			in:   "T Test\n\n#C@#\n\n#..#\n",
+		},
+		{
+			name: "no cells",
+			in:   "T Test\nP 10\n",
+			want: []string{
+				`levels/test.txt: no cells, they come after a blank line following the header`,
+			},
+		},
+		{
+			name: "unknown keys",
+			in:   "T Test\nQ 1\nTitle Test\n\nC@\n",
+			want: []string{
+				`levels/test.txt:2: warning: unknown key 'Q'`,
+				`levels/test.txt:3: warning: unknown key "Title"`,
+			},
+		},
+		{
+			name: "duplicate keys",
+			in:   "T Test\nW 1 runner\nW 1 walker\nT Again\n\nC@\n",
+			want: []string{
+				`levels/test.txt:4: warning: duplicate key 'T', replacing the one on line 1`,
+			},
+		},
+		{
+			name: "unknown cells",
+			in:   "T Test\n\nC@\n.x,\n",
+			want: []string{
+				`levels/test.txt:4: warning: unknown cell 'x' in column 2, it will be open ground`,
+			},
+		},
+		{
+			name: "bad values",
+			in:   "T Test\nP lots\nW 5 runner,x@2 walker\nW 5@-1 runner\nW 5\nW 5 runner;\nW 5 run ner\nW 5 runner&\n\nC@\n",
+			want: []string{
+				`levels/test.txt:2: P: "lots" is not a whole number`,
+				`levels/test.txt:3: W: wave 1, spawn 2: amount "x" is not a positive whole number`,
+				`levels/test.txt:4: W: wave 1, spawn 1: tick delay "-1" is not a whole number`,
+				`levels/test.txt:5: W: wave 1, spawn 1: "5" has no enemies`,
+				`levels/test.txt:6: W: wave 2, spawn 1: is empty`,
+				`levels/test.txt:7: W: wave 1, spawn 1: "5 run ner" has a space between enemies, use & instead`,
+				`levels/test.txt:8: W: wave 1, spawn 1: "runner&" has an empty enemy`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var l LevelConfig
+			err := l.LoadFromBytes("levels/test.txt", []byte(tt.in))
+			got := levelLines(l, err)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+// TestLevelRoundTrip writes every bundled level out the way the editor saves it and reads it back in.
+func TestLevelRoundTrip(t *testing.T) {
+	entries, err := ReadDir("levels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	levels := 0
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".txt")
+		if e.IsDir() || name == e.Name() {
+			continue
+		}
+		levels++
+		var l LevelConfig
+		if err := l.LoadFromFile(name); err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		b := l.Bytes()
+		var got LevelConfig
+		if err := got.LoadFromBytes(name, b); err != nil {
+			t.Errorf("%s: reading it back: %v", name, err)
+			continue
+		}
+		if len(got.Warnings) != 0 {
+			t.Errorf("%s: reading it back: %v", name, got.Warnings)
+		}
+		got.Warnings, l.Warnings = nil, nil
+		if !reflect.DeepEqual(got, l) {
+			t.Errorf("%s: got\n%+v\nwant\n%+v", name, got, l)
+		}
+		if again := got.Bytes(); !bytes.Equal(again, b) {
+			t.Errorf("%s: written again as\n%s\nwant\n%s", name, again, b)
+		}
+	}
+	if levels == 0 {
+		t.Error("no levels")
+	}
+}
@@ -4,38 +4,38 @@ import (
 	"image"
 	"image/color"
 
-	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/kettek/ebijam22/pkg/engine"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/opentype"
 )
 
 var (
 	// Images for drawing lines.
-	EmptyImage    *ebiten.Image
-	EmptySubImage *ebiten.Image
+	EmptyImage    *engine.Image
+	EmptySubImage *engine.Image
 
 	// I'm sorry for violating our principles.
-	OrbTinyImages   []*ebiten.Image
-	OrbSmallImages  []*ebiten.Image
-	OrbMediumImages []*ebiten.Image
-	OrbLargeImages  []*ebiten.Image
+	OrbTinyImages   []*engine.Image
+	OrbSmallImages  []*engine.Image
+	OrbMediumImages []*engine.Image
+	OrbLargeImages  []*engine.Image
 
 	CellWidth, CellHeight int
 	NormalFace, BoldFace  font.Face
 )
 
 // Images is the map of all loaded images.
-var Images map[string]*ebiten.Image = make(map[string]*ebiten.Image)
+var Images map[string]*engine.Image = make(map[string]*engine.Image)
 
 // GetImage returns the image matching the given file name. IT ALSO LOADS IT.
-func GetImage(p string) (*ebiten.Image, error) {
+func GetImage(p string) (*engine.Image, error) {
 	if v, ok := Images[p]; ok {
 		return v, nil
 	}
 	if img, err := ReadImage(p); err != nil {
 		return nil, err
 	} else {
-		eimg := ebiten.NewImageFromImage(img)
+		eimg := engine.NewImageFromImage(img)
 		Images[p] = eimg
 		return eimg, nil
 	}
@@ -71,8 +71,8 @@ func GetMusic(p string) (*Sound, error) {
 // LoadData loads some data.
 func LoadData() error {
 	//
-	EmptyImage = ebiten.NewImage(3, 3)
-	EmptySubImage = EmptyImage.SubImage(image.Rect(1, 1, 2, 2)).(*ebiten.Image)
+	EmptyImage = engine.NewImage(3, 3)
+	EmptySubImage = EmptyImage.SubImage(image.Rect(1, 1, 2, 2)).(*engine.Image)
 	EmptyImage.Fill(color.White)
 
 	// Load the fonts.
@@ -113,28 +113,28 @@ func LoadData() error {
 		return err
 	}
 	for _, img := range imgs {
-		OrbTinyImages = append(OrbTinyImages, ebiten.NewImageFromImage(img))
+		OrbTinyImages = append(OrbTinyImages, engine.NewImageFromImage(img))
 	}
 	imgs, err = ReadImagesByPrefix("orb-small")
 	if err != nil {
 		return err
 	}
 	for _, img := range imgs {
-		OrbSmallImages = append(OrbSmallImages, ebiten.NewImageFromImage(img))
+		OrbSmallImages = append(OrbSmallImages, engine.NewImageFromImage(img))
 	}
 	imgs, err = ReadImagesByPrefix("orb-medium")
 	if err != nil {
 		return err
 	}
 	for _, img := range imgs {
-		OrbMediumImages = append(OrbMediumImages, ebiten.NewImageFromImage(img))
+		OrbMediumImages = append(OrbMediumImages, engine.NewImageFromImage(img))
 	}
 	imgs, err = ReadImagesByPrefix("orb-large")
 	if err != nil {
 		return err
 	}
 	for _, img := range imgs {
-		OrbLargeImages = append(OrbLargeImages, ebiten.NewImageFromImage(img))
+		OrbLargeImages = append(OrbLargeImages, engine.NewImageFromImage(img))
 	}
 
 	return nil
@@ -4,9 +4,7 @@ import (
 	"image"
 	"image/color"
 
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
-	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/kettek/ebijam22/pkg/engine"
 	"golang.org/x/image/font"
 )
 
@@ -22,22 +20,22 @@ func CurrentlyReceivingInput() bool {
 	return false
 }
 
-func DrawStaticTextByCode(code string, font font.Face, x, y int, color color.Color, screen *ebiten.Image, shouldCenter bool) image.Rectangle {
+func DrawStaticTextByCode(code string, font font.Face, x, y int, color color.Color, screen *engine.Image, shouldCenter bool) image.Rectangle {
 	translatedString := GiveMeString(code)
 	return DrawStaticText(translatedString, font, x, y, color, screen, shouldCenter)
 }
 
 // Draw static text
 // Returns the bounds for convenience
-func DrawStaticText(txt string, font font.Face, x, y int, color color.Color, screen *ebiten.Image, shouldCenter bool) image.Rectangle {
+func DrawStaticText(txt string, font font.Face, x, y int, color color.Color, screen *engine.Image, shouldCenter bool) image.Rectangle {
 	var offsetX int
 	var offsetY int
-	bounds := text.BoundString(font, txt)
+	bounds := engine.BoundString(font, txt)
 	if shouldCenter {
 		offsetX = bounds.Dx() / 2
 		offsetY = bounds.Dy() / 2
 	}
-	text.Draw(
+	engine.DrawText(
 		screen,
 		txt,
 		font,
@@ -58,14 +56,14 @@ type TextInput struct {
 	counter     int
 	borderWidth int
 	maxLength   int
-	innerImage  *ebiten.Image
+	innerImage  *engine.Image
 	OnChange    func(s string)
 }
 
 func NewTextInput(label, placeholder string, maxLength, x, y int) *TextInput {
 	borderWidth := 2
-	textSize := text.BoundString(NormalFace, "m")
-	outerImage := ebiten.NewImage((textSize.Dx()*(maxLength))+borderWidth*7, textSize.Dy()+borderWidth*7)
+	textSize := engine.BoundString(NormalFace, "m")
+	outerImage := engine.NewImage((textSize.Dx()*(maxLength))+borderWidth*7, textSize.Dy()+borderWidth*7)
 	outerBounds := outerImage.Bounds()
 	innerRectangle := image.Rectangle{
 		image.Point{
@@ -77,7 +75,7 @@ func NewTextInput(label, placeholder string, maxLength, x, y int) *TextInput {
 			Y: outerBounds.Max.Y - borderWidth,
 		}}
 	outerImage.Fill(color.White)
-	innerImage := outerImage.SubImage(innerRectangle).(*ebiten.Image)
+	innerImage := outerImage.SubImage(innerRectangle).(*engine.Image)
 	innerImage.Fill(color.Black)
 	return &TextInput{
 		label:       label,
@@ -94,7 +92,7 @@ func NewTextInput(label, placeholder string, maxLength, x, y int) *TextInput {
 }
 
 func (ti *TextInput) Update() {
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+	if engine.IsMouseButtonJustPressed(engine.MouseButtonLeft) {
 		if ti.IsClicked() {
 			ti.isActive = true
 			receivingKeyboardInput[ti.label] = true
@@ -105,7 +103,7 @@ func (ti *TextInput) Update() {
 		}
 	}
 	if ti.isActive {
-		if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) {
+		if engine.IsKeyJustPressed(engine.KeyBackspace) {
 			if len(ti.data) > 0 {
 				ti.data = ti.data[0 : len(ti.data)-1]
 				if ti.OnChange != nil {
@@ -114,7 +112,7 @@ func (ti *TextInput) Update() {
 			}
 		}
 		ti.counter++
-		ti.runes = ebiten.AppendInputChars(ti.runes[:0])
+		ti.runes = engine.AppendInputChars(ti.runes[:0])
 		if len(ti.data) < ti.maxLength {
 			ti.data += string(ti.runes)
 			if len(ti.runes) > 0 && ti.OnChange != nil {
@@ -125,12 +123,12 @@ func (ti *TextInput) Update() {
 }
 
 func (ti *TextInput) IsClicked() bool {
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
-		// labelBounds := text.BoundString(NormalFace, ti.label)
-		cursorX, cursorY := ebiten.CursorPosition()
+	if engine.IsMouseButtonJustPressed(engine.MouseButtonLeft) {
+		// labelBounds := engine.BoundString(NormalFace, ti.label)
+		cursorX, cursorY := engine.CursorPosition()
 		width := ti.innerImage.Bounds().Dx()
 		height := ti.innerImage.Bounds().Dy() / 2
-		labelBounds := text.BoundString(NormalFace, ti.label)
+		labelBounds := engine.BoundString(NormalFace, ti.label)
 		minX, maxX := ti.x, ti.x+width
 		minY, maxY := ti.y+labelBounds.Dy()-height, ti.y+labelBounds.Dy()+height
 		if int(minX) < cursorX && cursorX < int(maxX) {
@@ -143,8 +141,8 @@ func (ti *TextInput) IsClicked() bool {
 	return false
 }
 
-func (ti *TextInput) Draw(screen *ebiten.Image, screenOp *ebiten.DrawImageOptions) {
-	op := ebiten.DrawImageOptions{}
+func (ti *TextInput) Draw(screen *engine.Image, screenOp *engine.DrawImageOptions) {
+	op := engine.DrawImageOptions{}
 
 	op.GeoM.Translate(float64(ti.x), float64(ti.y))
 
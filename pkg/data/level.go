@@ -24,6 +24,11 @@ type Cell struct {
 	// ???
 }
 
+// Pathable returns if enemies can walk through the cell, before anything is built on it.
+func (c Cell) Pathable() bool {
+	return c.Kind != BlockedCell && c.Kind != EmptyCell
+}
+
 func NewLevel(path string) (Level, error) {
 	level := Level{}
 	err := level.LoadFromFile(path)
@@ -0,0 +1,408 @@
+package data
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kettek/ebijam22/pkg/engine"
+	"gopkg.in/yaml.v3"
+)
+
+// Mods are directories or zip files in the mods directory of the per-user assets. Each has a mod.yaml manifest alongside assets laid out the same as the game's own, and enabled ones are layered over the game's assets in their load order, so later mods win. The load order, and which mods are enabled, is kept in mods.yaml in the per-user assets.
+
+// GameVersion is the version of the game, which mods give the range of versions they work with against.
+const GameVersion = "1.0.0"
+
+// modManifestName is the name of a mod's manifest.
+const modManifestName = "mod.yaml"
+
+// ModDependency is another mod that a mod needs loaded before it.
+type ModDependency struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"` // Range of versions that will do, such as ">=1.2 <2". Empty means any.
+}
+
+// ModManifest describes a mod.
+type ModManifest struct {
+	Name         string          `yaml:"name"`
+	Version      string          `yaml:"version"`
+	Game         string          `yaml:"game"` // Range of game versions the mod works with. Empty means any.
+	Description  string          `yaml:"description"`
+	Dependencies []ModDependency `yaml:"dependencies"`
+}
+
+// Mod is a mod that was found, whether or not it is enabled.
+type Mod struct {
+	ModManifest
+	Path    string
+	Enabled bool
+	Problem string // Why the mod can't be loaded even though it's enabled, if anything.
+	fs      fs.FS
+	closer  io.Closer
+}
+
+// Loaded returns if the mod's assets are in use.
+func (m *Mod) Loaded() bool {
+	return m.Enabled && m.Problem == ""
+}
+
+// modOrderEntry is a mod's place in mods.yaml.
+type modOrderEntry struct {
+	Name    string `yaml:"name"`
+	Enabled bool   `yaml:"enabled"`
+}
+
+// ModConflict is a turret or enemy that more than one loaded mod defines. The last mod's is the one used.
+type ModConflict struct {
+	Kind  string
+	Title string
+	Mods  []string
+}
+
+func (c ModConflict) String() string {
+	return fmt.Sprintf("%s %q is defined by %s, using %s's", c.Kind, c.Title, strings.Join(c.Mods, ", "), c.Mods[len(c.Mods)-1])
+}
+
+var (
+	// Mods are all of the mods that were found, in load order.
+	Mods []*Mod
+	// ModConflicts are the conflicts between loaded mods, as of the last LoadConfigurations.
+	ModConflicts []ModConflict
+)
+
+// modsDir returns where mods are found.
+func modsDir() (string, error) {
+	dir, err := UserAssetDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "mods"), nil
+}
+
+// modOrderPath returns where the load order is kept.
+func modOrderPath() (string, error) {
+	dir, err := UserAssetDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "mods.yaml"), nil
+}
+
+// openMod opens the mod at the given path, either a directory or a zip file.
+func openMod(p string) (*Mod, error) {
+	m := &Mod{Path: p}
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		m.fs = os.DirFS(p)
+	} else if strings.EqualFold(filepath.Ext(p), ".zip") {
+		z, err := zip.OpenReader(p)
+		if err != nil {
+			return nil, err
+		}
+		m.fs = &z.Reader
+		m.closer = z
+	} else {
+		return nil, errors.New("not a mod")
+	}
+
+	// Zips often have everything in a single directory, so look in there too.
+	if _, err := fs.Stat(m.fs, modManifestName); err != nil {
+		entries, _ := fs.ReadDir(m.fs, ".")
+		if len(entries) == 1 && entries[0].IsDir() {
+			if sub, err := fs.Sub(m.fs, entries[0].Name()); err == nil {
+				m.fs = sub
+			}
+		}
+	}
+
+	b, err := fs.ReadFile(m.fs, modManifestName)
+	if err != nil {
+		m.close()
+		return nil, fmt.Errorf("%s: missing %s", p, modManifestName)
+	}
+	if err := yaml.Unmarshal(b, &m.ModManifest); err != nil {
+		m.close()
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	if m.Name == "" {
+		m.Name = strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+	}
+	return m, nil
+}
+
+func (m *Mod) close() {
+	if m.closer != nil {
+		m.closer.Close()
+	}
+}
+
+// LoadMods finds every mod, puts them in their load order, and layers the enabled ones over the game's assets. Mods that aren't in the load order yet are enabled and go at the end.
+func LoadMods() error {
+	for _, m := range Mods {
+		m.close()
+	}
+	Mods = nil
+
+	dir, err := modsDir()
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	found := make(map[string]*Mod)
+	var names []string
+	for _, e := range entries {
+		m, err := openMod(filepath.Join(dir, e.Name()))
+		if err != nil {
+			fmt.Println("skipping mod", err)
+			continue
+		}
+		if other, ok := found[m.Name]; ok {
+			fmt.Println("skipping mod", m.Path, "as", other.Path, "is also named", m.Name)
+			m.close()
+			continue
+		}
+		m.Enabled = true
+		found[m.Name] = m
+		names = append(names, m.Name)
+	}
+
+	// Go with the saved order for what it has, then everything else by name.
+	if p, err := modOrderPath(); err == nil {
+		if b, err := os.ReadFile(p); err == nil {
+			var order []modOrderEntry
+			if err := yaml.Unmarshal(b, &order); err != nil {
+				fmt.Println("ignoring mod order", err)
+			}
+			for _, o := range order {
+				if m, ok := found[o.Name]; ok {
+					m.Enabled = o.Enabled
+					Mods = append(Mods, m)
+					delete(found, o.Name)
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if m, ok := found[name]; ok {
+			Mods = append(Mods, m)
+		}
+	}
+
+	resolveMods()
+	return nil
+}
+
+// SaveModOrder saves the load order, and which mods are enabled.
+func SaveModOrder() error {
+	p, err := modOrderPath()
+	if err != nil {
+		return err
+	}
+	var order []modOrderEntry
+	for _, m := range Mods {
+		order = append(order, modOrderEntry{Name: m.Name, Enabled: m.Enabled})
+	}
+	b, err := yaml.Marshal(order)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, b, 0644)
+}
+
+// MoveMod moves the mod at i to j in the load order.
+func MoveMod(i, j int) {
+	if i < 0 || j < 0 || i >= len(Mods) || j >= len(Mods) {
+		return
+	}
+	m := Mods[i]
+	Mods = append(Mods[:i], Mods[i+1:]...)
+	Mods = append(Mods[:j], append([]*Mod{m}, Mods[j:]...)...)
+}
+
+// ApplyMods re-checks the mods after they've been enabled, disabled, or reordered, then reloads the configurations with them.
+func ApplyMods() error {
+	resolveMods()
+	// Anything already loaded may have come from a mod that's no longer there.
+	Images = make(map[string]*engine.Image)
+	tilesets = make(map[string]TileSet)
+	return LoadConfigurations()
+}
+
+// resolveMods works out which enabled mods can actually be loaded, then restacks the asset layers with them.
+func resolveMods() {
+	loaded := make(map[string]*Mod)
+	for _, m := range Mods {
+		m.Problem = ""
+		if !m.Enabled {
+			continue
+		}
+		if ok, err := versionInRange(GameVersion, m.Game); err != nil {
+			m.Problem = err.Error()
+		} else if !ok {
+			m.Problem = fmt.Sprintf("needs game version %s", m.Game)
+		}
+		for _, d := range m.Dependencies {
+			if m.Problem != "" {
+				break
+			}
+			dep, ok := loaded[d.Name]
+			if !ok {
+				m.Problem = fmt.Sprintf("needs %s loaded before it", d.Name)
+				break
+			}
+			if d.Version == "" {
+				continue
+			}
+			// Mods that don't give a version are as old as can be.
+			version := dep.Version
+			if strings.TrimSpace(version) == "" {
+				version = "0.0.0"
+			}
+			if ok, err := versionInRange(version, d.Version); err != nil {
+				m.Problem = err.Error()
+			} else if !ok {
+				m.Problem = fmt.Sprintf("needs %s %s, not %s", d.Name, d.Version, version)
+			}
+		}
+		if m.Problem != "" {
+			fmt.Println("not loading mod", m.Name+":", m.Problem)
+			continue
+		}
+		loaded[m.Name] = m
+	}
+	rebuildAssetLayers()
+}
+
+// findModConflicts finds every turret and enemy defined by more than one loaded mod.
+func findModConflicts() (conflicts []ModConflict) {
+	definedBy := make(map[[2]string][]string)
+	for _, m := range Mods {
+		if !m.Loaded() {
+			continue
+		}
+		for _, kind := range [][2]string{{"turret", "turrets"}, {"enemy", "enemies"}} {
+			dir := path.Join("entities", kind[1])
+			entries, err := fs.ReadDir(m.fs, dir)
+			if err != nil {
+				continue
+			}
+			for _, e := range entries {
+				if e.IsDir() {
+					continue
+				}
+				title := strings.Split(e.Name(), ".")[0]
+				if b, err := fs.ReadFile(m.fs, path.Join(dir, e.Name())); err == nil {
+					if t := entityTitle(b); t != "" {
+						title = t
+					}
+				}
+				key := [2]string{kind[0], title}
+				definedBy[key] = append(definedBy[key], m.Name)
+			}
+		}
+	}
+	for key, mods := range definedBy {
+		if len(mods) > 1 {
+			conflicts = append(conflicts, ModConflict{Kind: key[0], Title: key[1], Mods: mods})
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Kind != conflicts[j].Kind {
+			return conflicts[i].Kind < conflicts[j].Kind
+		}
+		return conflicts[i].Title < conflicts[j].Title
+	})
+	return conflicts
+}
+
+// entityTitle returns the title from an entity config, lowercased the same as when it's loaded.
+func entityTitle(b []byte) string {
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.HasPrefix(line, "T") {
+			return strings.ToLower(strings.TrimSpace(line[1:]))
+		}
+	}
+	return ""
+}
+
+// parseVersion parses a version such as "1.2.3". Missing parts are 0.
+func parseVersion(s string) (v [3]int, err error) {
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "v"), ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("bad version %q", s)
+	}
+	for i, p := range parts {
+		if v[i], err = strconv.Atoi(p); err != nil {
+			return v, fmt.Errorf("bad version %q", s)
+		}
+	}
+	return v, nil
+}
+
+// compareVersions returns -1, 0, or 1 for if a is older than, the same as, or newer than b.
+func compareVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		} else if a[i] > b[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// versionInRange returns if the version is in the range, which is any number of space-separated comparisons such as ">=1.2 <2". A version on its own has to match exactly.
+func versionInRange(version, r string) (bool, error) {
+	v, err := parseVersion(version)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range strings.Fields(r) {
+		op := strings.TrimRight(c, "0123456789.v")
+		other, err := parseVersion(c[len(op):])
+		if err != nil {
+			return false, err
+		}
+		cmp := compareVersions(v, other)
+		var ok bool
+		switch op {
+		case "", "=", "==":
+			ok = cmp == 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		case "!=":
+			ok = cmp != 0
+		default:
+			return false, fmt.Errorf("bad version range %q", r)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -3,7 +3,7 @@ package data
 import (
 	"strings"
 
-	"github.com/hajimehoshi/ebiten/v2/audio"
+	"github.com/kettek/ebijam22/pkg/engine"
 )
 
 var BGM MusicPlayer = MusicPlayer{
@@ -12,7 +12,7 @@ var BGM MusicPlayer = MusicPlayer{
 
 type MusicPlayer struct {
 	currentTrack string
-	currentMusic *audio.Player
+	currentMusic *engine.AudioPlayer
 	Muted        bool
 	volume       float64
 }
@@ -22,7 +22,7 @@ func (mp *MusicPlayer) Play(s *Sound) {
 		mp.currentMusic.Close()
 	}
 
-	var player *audio.Player
+	var player *engine.AudioPlayer
 	if mp.Muted {
 		player = s.Play(0)
 	} else {
@@ -1,16 +1,26 @@
 package data
 
 type Options struct {
-	Handshaker string  `short:"H" long:"handshaker" description:"Handshaker service address to use for search/await handshaking" default:"gamu.group:20220"`
-	Host       string  `short:"h" long:"host" description:"Directly hosting on an address"`
-	Join       string  `short:"j" long:"join" description:"Directly join an address"`
-	Search     string  `short:"s" long:"search" description:"Search for a given user using external handshaking"`
-	Await      bool    `short:"a" long:"await" description:"Await for a player search"`
-	Map        string  `short:"m" long:"map" description:"Map to start the game on" default:"001"`
-	Name       string  `short:"n" long:"name" description:"Name to user in multiplayer"`
-	Speed      float64 `short:"S" long:"speed" description:"Game speed multiplier" default:"1.0"`
-	NoMusic    bool    `long:"nomusic" description:"Disable in-game music"`
-	NoSound    bool    `long:"nosound" description:"Disable in-game sound"`
-	NoMenu     bool    `long:"nomenu" description:"Disable main menu and immediately start game"`
-	SyncRate   int     `long:"syncrate" description:"How frequently in ticks network information should be synchronized" default:"100"`
+	Handshaker   string  `short:"H" long:"handshaker" description:"Handshaker service address to use for search/await handshaking" default:"gamu.group:20220"`
+	Host         string  `short:"h" long:"host" description:"Directly hosting on an address"`
+	Join         string  `short:"j" long:"join" description:"Directly join an address"`
+	Search       string  `short:"s" long:"search" description:"Search for a given user using external handshaking"`
+	Spectate     bool    `long:"spectate" description:"Join as a spectator that only watches the host's game"`
+	Password     string  `long:"password" description:"Lobby password. Everyone needs the same one to connect, and it is used to encrypt all traffic"`
+	Await        bool    `short:"a" long:"await" description:"Await for a player search"`
+	Map          string  `short:"m" long:"map" description:"Map to start the game on" default:"001"`
+	Name         string  `short:"n" long:"name" description:"Name to user in multiplayer"`
+	Speed        float64 `short:"S" long:"speed" description:"Game speed multiplier" default:"1.0"`
+	Seed         int64   `long:"seed" description:"Seed for the world's random source. 0 picks one from the current time"`
+	NoMusic      bool    `long:"nomusic" description:"Disable in-game music"`
+	NoSound      bool    `long:"nosound" description:"Disable in-game sound"`
+	NoMenu       bool    `long:"nomenu" description:"Disable main menu and immediately start game"`
+	Record       string  `long:"record" description:"Directory to record replays of solo and hosted games to"`
+	Replay       string  `long:"replay" description:"Replay file to play back"`
+	LevelDir     string  `long:"leveldir" description:"Directory the level editor saves levels to and loads them from. Defaults to the levels directory of the per-user assets"`
+	Data         string  `long:"data" description:"Directory of assets that replace or add to the game's own, laid out the same way"`
+	CheckAssets  bool    `long:"check-assets" description:"Check every entity config and level for problems, print them, and exit"`
+	SyncRate     int     `long:"syncrate" description:"How frequently in ticks network information should be synchronized" default:"100"`
+	InterpDelay  int     `long:"interpdelay" description:"How many ticks behind the host networked entities are shown, so there is something to interpolate between" default:"6"`
+	ChecksumRate int     `long:"checksumrate" description:"How frequently in ticks the host sends a checksum of the world to detect desyncs" default:"300"`
 }
@@ -3,8 +3,7 @@ package data
 import (
 	"bytes"
 
-	"github.com/hajimehoshi/ebiten/v2/audio"
-	"github.com/hajimehoshi/ebiten/v2/audio/vorbis"
+	"github.com/kettek/ebijam22/pkg/engine"
 )
 
 var SFX = SoundPlayer{
@@ -22,7 +21,7 @@ type Sound struct {
 
 func NewSound(data []byte) (*Sound, error) {
 	// Attempt to read the vorbis file in 44100 sample rate.
-	stream, err := vorbis.DecodeWithSampleRate(48000, bytes.NewReader(data))
+	stream, err := engine.DecodeVorbis(48000, bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
@@ -42,14 +41,18 @@ func NewSound(data []byte) (*Sound, error) {
 }
 
 // Play constructs a new ebiten audio player, starts playing, and returns it. Volume is 0-1.
-func (s *Sound) Play(volume float64) *audio.Player {
-	player := audio.CurrentContext().NewPlayerFromBytes(s.bytes)
+func (s *Sound) Play(volume float64) *engine.AudioPlayer {
+	// Bail if there is no audio context, such as when running headless.
+	if !engine.HasAudio() {
+		return nil
+	}
+	player := engine.NewAudioPlayer(s.bytes)
 	player.SetVolume(volume)
 	player.Play()
 	return player
 }
 
-func (sp *SoundPlayer) PlaySound(s *Sound) *audio.Player {
+func (sp *SoundPlayer) PlaySound(s *Sound) *engine.AudioPlayer {
 	if sp.Muted {
 		return s.Play(0)
 	} else {
@@ -58,7 +61,7 @@ func (sp *SoundPlayer) PlaySound(s *Sound) *audio.Player {
 }
 
 // Wraps sound in SoundPlayer context to set muted and volume
-func (sp *SoundPlayer) Play(p string) *audio.Player {
+func (sp *SoundPlayer) Play(p string) *engine.AudioPlayer {
 	sound, _ := GetSound(p)
 	return sp.PlaySound(sound)
 }
@@ -3,11 +3,11 @@ package ui
 import (
 	"math"
 
-	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/kettek/ebijam22/pkg/engine"
 )
 
 // DrawTiled draws the given image to fill the provided width and height.
-func DrawTiled(screen *ebiten.Image, image *ebiten.Image, op *ebiten.DrawImageOptions, width, height int) {
+func DrawTiled(screen *engine.Image, image *engine.Image, op *engine.DrawImageOptions, width, height int) {
 	bgWidth := image.Bounds().Dx()
 	bgHeight := image.Bounds().Dy()
 
@@ -16,7 +16,7 @@ func DrawTiled(screen *ebiten.Image, image *ebiten.Image, op *ebiten.DrawImageOp
 
 	for y := 0.0; y < rows; y++ {
 		for x := 0.0; x < cols; x++ {
-			bgOp := &ebiten.DrawImageOptions{}
+			bgOp := &engine.DrawImageOptions{}
 			bgOp.GeoM.Concat(op.GeoM)
 			bgOp.GeoM.Translate(x*float64(bgWidth), y*float64(bgHeight))
 			screen.DrawImage(image, bgOp)
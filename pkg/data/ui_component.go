@@ -1,13 +1,13 @@
 package data
 
 import (
-	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/kettek/ebijam22/pkg/engine"
 )
 
 type UIComponent interface {
 	SetPos(x, y int)
-	Image() *ebiten.Image
+	Image() *engine.Image
 	Update()
-	Draw(screen *ebiten.Image, screenOp *ebiten.DrawImageOptions)
+	Draw(screen *engine.Image, screenOp *engine.DrawImageOptions)
 	IsClicked() bool
 }
@@ -1,5 +1,12 @@
 package data
 
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 // Wave contains a spawn list and the next wave.
 type Wave struct {
 	Spawns *SpawnList
@@ -42,3 +49,91 @@ func (w *Wave) Clone() *Wave {
 	}
 	return w2
 }
+
+// String returns the spawn list, and those after it, in the level format's wave syntax.
+func (sl *SpawnList) String() string {
+	var spawns []string
+	for ; sl != nil; sl = sl.Next {
+		spawns = append(spawns, fmt.Sprintf("%d@%d %s", sl.Count, sl.Spawnrate, strings.Join(sl.Kinds, "&")))
+	}
+	return strings.Join(spawns, ",")
+}
+
+// String returns the wave, and those after it, in the level format's wave syntax.
+func (w *Wave) String() string {
+	var waves []string
+	for ; w != nil; w = w.Next {
+		waves = append(waves, w.Spawns.String())
+	}
+	return strings.Join(waves, ";")
+}
+
+// ParseWave reads a level's W line back into its waves, the reverse of String.
+func ParseWave(s string) (*Wave, error) {
+	var firstWave *Wave
+	var lastWave *Wave
+	for i, w := range strings.Split(s, ";") {
+		wave := &Wave{}
+		var lastSpawn *SpawnList
+		for j, spawn := range strings.Split(w, ",") {
+			sl, err := parseSpawnList(spawn)
+			if err != nil {
+				return nil, fmt.Errorf("wave %d, spawn %d: %w", i+1, j+1, err)
+			}
+			if lastSpawn == nil {
+				wave.Spawns = sl
+			} else {
+				lastSpawn.Next = sl
+			}
+			lastSpawn = sl
+		}
+		if firstWave == nil {
+			firstWave = wave
+		}
+		if lastWave != nil {
+			lastWave.Next = wave
+		}
+		lastWave = wave
+	}
+	return firstWave, nil
+}
+
+// parseSpawnList reads a single `<AMOUNT>[@<TICK DELAY>] <ENEMY>[&<ENEMY>...]` spawn.
+func parseSpawnList(s string) (*SpawnList, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, errors.New("is empty")
+	}
+	if len(fields) == 1 {
+		return nil, fmt.Errorf("%q has no enemies", s)
+	}
+	if len(fields) > 2 {
+		return nil, fmt.Errorf("%q has a space between enemies, use & instead", s)
+	}
+	sl := &SpawnList{
+		// No tick specified.
+		Spawnrate: 20,
+	}
+	// Get amount and tick delay.
+	amountStr, delayStr, hasDelay := strings.Cut(fields[0], "@")
+	amount, err := strconv.Atoi(amountStr)
+	if err != nil || amount <= 0 {
+		return nil, fmt.Errorf("amount %q is not a positive whole number", amountStr)
+	}
+	sl.Count = amount
+	if hasDelay {
+		delay, err := strconv.Atoi(delayStr)
+		if err != nil || delay < 0 {
+			return nil, fmt.Errorf("tick delay %q is not a whole number", delayStr)
+		}
+		sl.Spawnrate = delay
+	}
+	// Get enemies list.
+	for _, kind := range strings.Split(fields[1], "&") {
+		if kind == "" {
+			return nil, fmt.Errorf("%q has an empty enemy", fields[1])
+		}
+		sl.Kinds = append(sl.Kinds, kind)
+	}
+	return sl, nil
+}
@@ -0,0 +1,136 @@
+//go:build !headless
+
+// Package engine is the part of ebiten that the world and its data use. Building with the headless tag swaps it out for stand-ins that draw nothing, read no input, and play nothing, so simulations can be built without cgo or a display.
+package engine
+
+import (
+	"image"
+	"image/color"
+	"io"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/audio"
+	"github.com/hajimehoshi/ebiten/v2/audio/vorbis"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"golang.org/x/image/font"
+)
+
+type (
+	Image            = ebiten.Image
+	DrawImageOptions = ebiten.DrawImageOptions
+	GeoM             = ebiten.GeoM
+	ColorM           = ebiten.ColorM
+	Key              = ebiten.Key
+	MouseButton      = ebiten.MouseButton
+	CursorShapeType  = ebiten.CursorShapeType
+	AudioPlayer      = audio.Player
+)
+
+const (
+	Key0         = ebiten.Key0
+	Key1         = ebiten.Key1
+	KeyA         = ebiten.KeyA
+	KeyD         = ebiten.KeyD
+	KeyS         = ebiten.KeyS
+	KeyW         = ebiten.KeyW
+	KeyAlt       = ebiten.KeyAlt
+	KeyShift     = ebiten.KeyShift
+	KeySpace     = ebiten.KeySpace
+	KeyTab       = ebiten.KeyTab
+	KeyBackspace = ebiten.KeyBackspace
+	KeyUp        = ebiten.KeyUp
+	KeyDown      = ebiten.KeyDown
+	KeyLeft      = ebiten.KeyLeft
+	KeyRight     = ebiten.KeyRight
+
+	MouseButtonLeft  = ebiten.MouseButtonLeft
+	MouseButtonRight = ebiten.MouseButtonRight
+
+	CursorShapeDefault = ebiten.CursorShapeDefault
+	CursorShapePointer = ebiten.CursorShapePointer
+)
+
+func NewImage(width, height int) *Image {
+	return ebiten.NewImage(width, height)
+}
+
+func NewImageFromImage(source image.Image) *Image {
+	return ebiten.NewImageFromImage(source)
+}
+
+func IsKeyPressed(key Key) bool {
+	return ebiten.IsKeyPressed(key)
+}
+
+func IsKeyJustPressed(key Key) bool {
+	return inpututil.IsKeyJustPressed(key)
+}
+
+func IsKeyJustReleased(key Key) bool {
+	return inpututil.IsKeyJustReleased(key)
+}
+
+func IsMouseButtonPressed(button MouseButton) bool {
+	return ebiten.IsMouseButtonPressed(button)
+}
+
+func IsMouseButtonJustPressed(button MouseButton) bool {
+	return inpututil.IsMouseButtonJustPressed(button)
+}
+
+func IsMouseButtonJustReleased(button MouseButton) bool {
+	return inpututil.IsMouseButtonJustReleased(button)
+}
+
+func CursorPosition() (x, y int) {
+	return ebiten.CursorPosition()
+}
+
+func Wheel() (xoff, yoff float64) {
+	return ebiten.Wheel()
+}
+
+func AppendInputChars(runes []rune) []rune {
+	return ebiten.AppendInputChars(runes)
+}
+
+func IsFocused() bool {
+	return ebiten.IsFocused()
+}
+
+func SetCursorShape(shape CursorShapeType) {
+	ebiten.SetCursorShape(shape)
+}
+
+func DrawLine(dst *Image, x1, y1, x2, y2 float64, clr color.Color) {
+	ebitenutil.DrawLine(dst, x1, y1, x2, y2, clr)
+}
+
+func DrawRect(dst *Image, x, y, width, height float64, clr color.Color) {
+	ebitenutil.DrawRect(dst, x, y, width, height, clr)
+}
+
+func DrawText(dst *Image, s string, face font.Face, x, y int, clr color.Color) {
+	text.Draw(dst, s, face, x, y, clr)
+}
+
+func BoundString(face font.Face, s string) image.Rectangle {
+	return text.BoundString(face, s)
+}
+
+// HasAudio returns if there is an audio context to play sounds with.
+func HasAudio() bool {
+	return audio.CurrentContext() != nil
+}
+
+// NewAudioPlayer returns a player for the given decoded sound. There has to be an audio context.
+func NewAudioPlayer(b []byte) *AudioPlayer {
+	return audio.CurrentContext().NewPlayerFromBytes(b)
+}
+
+// DecodeVorbis decodes an ogg vorbis sound at the given sample rate.
+func DecodeVorbis(sampleRate int, src io.ReadSeeker) (io.Reader, error) {
+	return vorbis.DecodeWithSampleRate(sampleRate, src)
+}
@@ -0,0 +1,202 @@
+//go:build headless
+
+// Package engine is the part of ebiten that the world and its data use. Building with the headless tag swaps it out for stand-ins that draw nothing, read no input, and play nothing, so simulations can be built without cgo or a display.
+package engine
+
+import (
+	"image"
+	"image/color"
+	"io"
+	"math"
+
+	"golang.org/x/image/font"
+)
+
+// Image only keeps its size, as nothing is ever drawn.
+type Image struct {
+	bounds image.Rectangle
+}
+
+func (i *Image) Bounds() image.Rectangle {
+	return i.bounds
+}
+
+func (i *Image) Size() (int, int) {
+	return i.bounds.Dx(), i.bounds.Dy()
+}
+
+func (i *Image) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+func (i *Image) At(x, y int) color.Color {
+	return color.RGBA{}
+}
+
+func (i *Image) SubImage(r image.Rectangle) image.Image {
+	return &Image{bounds: r.Intersect(i.bounds)}
+}
+
+func (i *Image) DrawImage(img *Image, options *DrawImageOptions) {}
+
+func (i *Image) Fill(clr color.Color) {}
+
+func (i *Image) Set(x, y int, clr color.Color) {}
+
+type DrawImageOptions struct {
+	GeoM   GeoM
+	ColorM ColorM
+}
+
+// GeoM is a full geometry matrix, as positions are sometimes read back out of it.
+type GeoM struct {
+	a_1, b, c, d_1, tx, ty float64 // a and d are stored minus one so the zero value is the identity, as ebiten does.
+}
+
+func (g *GeoM) Element(i, j int) float64 {
+	switch {
+	case i == 0 && j == 0:
+		return g.a_1 + 1
+	case i == 0 && j == 1:
+		return g.b
+	case i == 0 && j == 2:
+		return g.tx
+	case i == 1 && j == 0:
+		return g.c
+	case i == 1 && j == 1:
+		return g.d_1 + 1
+	case i == 1 && j == 2:
+		return g.ty
+	}
+	return 0
+}
+
+// Concat multiplies the other matrix onto this one, so that this one is applied first.
+func (g *GeoM) Concat(other GeoM) {
+	a, d := g.a_1+1, g.d_1+1
+	oa, od := other.a_1+1, other.d_1+1
+	*g = GeoM{
+		a_1: oa*a + other.b*g.c - 1,
+		b:   oa*g.b + other.b*d,
+		c:   other.c*a + od*g.c,
+		d_1: other.c*g.b + od*d - 1,
+		tx:  oa*g.tx + other.b*g.ty + other.tx,
+		ty:  other.c*g.tx + od*g.ty + other.ty,
+	}
+}
+
+func (g *GeoM) Translate(tx, ty float64) {
+	g.tx += tx
+	g.ty += ty
+}
+
+func (g *GeoM) Scale(x, y float64) {
+	g.Concat(GeoM{a_1: x - 1, d_1: y - 1})
+}
+
+func (g *GeoM) Rotate(theta float64) {
+	sin, cos := math.Sincos(theta)
+	g.Concat(GeoM{a_1: cos - 1, b: -sin, c: sin, d_1: cos - 1})
+}
+
+// ColorM does nothing, as colors are never drawn.
+type ColorM struct{}
+
+func (c *ColorM) Scale(r, g, b, a float64) {}
+
+func (c *ColorM) ScaleWithColor(clr color.Color) {}
+
+func (c *ColorM) Concat(other ColorM) {}
+
+type Key int
+
+const (
+	Key0 Key = iota
+	Key1
+	Key2
+	Key3
+	Key4
+	Key5
+	Key6
+	Key7
+	Key8
+	Key9
+	KeyA
+	KeyD
+	KeyS
+	KeyW
+	KeyAlt
+	KeyShift
+	KeySpace
+	KeyTab
+	KeyBackspace
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+)
+
+type MouseButton int
+
+const (
+	MouseButtonLeft MouseButton = iota
+	MouseButtonRight
+)
+
+type CursorShapeType int
+
+const (
+	CursorShapeDefault CursorShapeType = iota
+	CursorShapePointer
+)
+
+func NewImage(width, height int) *Image {
+	return &Image{bounds: image.Rect(0, 0, width, height)}
+}
+
+func NewImageFromImage(source image.Image) *Image {
+	return &Image{bounds: source.Bounds()}
+}
+
+func IsKeyPressed(key Key) bool                                                { return false }
+func IsKeyJustPressed(key Key) bool                                            { return false }
+func IsKeyJustReleased(key Key) bool                                           { return false }
+func IsMouseButtonPressed(button MouseButton) bool                             { return false }
+func IsMouseButtonJustPressed(button MouseButton) bool                         { return false }
+func IsMouseButtonJustReleased(button MouseButton) bool                        { return false }
+func CursorPosition() (x, y int)                                               { return 0, 0 }
+func Wheel() (xoff, yoff float64)                                              { return 0, 0 }
+func AppendInputChars(runes []rune) []rune                                     { return runes }
+func IsFocused() bool                                                          { return false }
+func SetCursorShape(shape CursorShapeType)                                     {}
+func DrawLine(dst *Image, x1, y1, x2, y2 float64, clr color.Color)             {}
+func DrawRect(dst *Image, x, y, width, height float64, clr color.Color)        {}
+func DrawText(dst *Image, s string, face font.Face, x, y int, clr color.Color) {}
+
+func BoundString(face font.Face, s string) image.Rectangle {
+	b, _ := font.BoundString(face, s)
+	return image.Rect(b.Min.X.Floor(), b.Min.Y.Floor(), b.Max.X.Ceil(), b.Max.Y.Ceil())
+}
+
+// AudioPlayer never plays anything.
+type AudioPlayer struct{}
+
+func (p *AudioPlayer) Play()                    {}
+func (p *AudioPlayer) Close() error             { return nil }
+func (p *AudioPlayer) IsPlaying() bool          { return false }
+func (p *AudioPlayer) Rewind() error            { return nil }
+func (p *AudioPlayer) SetVolume(volume float64) {}
+
+// HasAudio is always false, as there is never an audio context.
+func HasAudio() bool {
+	return false
+}
+
+func NewAudioPlayer(b []byte) *AudioPlayer {
+	return nil
+}
+
+// DecodeVorbis skips decoding, as the sound will never be played.
+func DecodeVorbis(sampleRate int, src io.ReadSeeker) (io.Reader, error) {
+	return src, nil
+}
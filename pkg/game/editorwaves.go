@@ -0,0 +1,68 @@
+package game
+
+import (
+	"strings"
+
+	"github.com/kettek/ebijam22/pkg/data"
+)
+
+// maxEditorSpawns is how many spawns a wave can have in the editor, as that's how many rows fit.
+const maxEditorSpawns = 5
+
+// editorWave is a wave being edited, as a slice of spawns rather than the level's chain of them.
+type editorWave []data.SpawnList
+
+// editorLine is all of a spawner's waves being edited, the same as a level's W line.
+type editorLine []editorWave
+
+// newEditorLine unchains a level's waves for editing.
+func newEditorLine(w *data.Wave) (line editorLine) {
+	for ; w != nil; w = w.Next {
+		var wave editorWave
+		for sl := w.Spawns; sl != nil; sl = sl.Next {
+			spawn := *sl
+			spawn.Kinds = append([]string(nil), sl.Kinds...)
+			spawn.Next = nil
+			wave = append(wave, spawn)
+		}
+		line = append(line, wave)
+	}
+	return line
+}
+
+// Wave chains the line back up into the level's waves.
+func (line editorLine) Wave() *data.Wave {
+	var first, last *data.Wave
+	for _, ew := range line {
+		wave := &data.Wave{}
+		var lastSpawn *data.SpawnList
+		for _, spawn := range ew {
+			sl := spawn
+			sl.Kinds = append([]string(nil), spawn.Kinds...)
+			sl.Next = nil
+			if lastSpawn == nil {
+				wave.Spawns = &sl
+			} else {
+				lastSpawn.Next = &sl
+			}
+			lastSpawn = &sl
+		}
+		if first == nil {
+			first = wave
+		} else {
+			last.Next = wave
+		}
+		last = wave
+	}
+	return first
+}
+
+// joinKinds shows enemy kinds in an input, separated by spaces.
+func joinKinds(kinds []string) string {
+	return strings.Join(kinds, " ")
+}
+
+// splitKinds reads enemy kinds back out of an input.
+func splitKinds(s string) []string {
+	return strings.Fields(s)
+}
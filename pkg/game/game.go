@@ -1,11 +1,13 @@
 package game
 
 import (
+	"fmt"
 	"image/color"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/audio"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
 	"github.com/kettek/ebijam22/pkg/data"
 	"github.com/kettek/ebijam22/pkg/data/assets/lang"
 	"github.com/kettek/ebijam22/pkg/net"
@@ -28,6 +30,8 @@ type Game struct {
 	players             []*world.Player
 	lostConnectionTimer int
 	HelpOverlayShown    bool
+	// replay is the replay to play back in the next play state, if any.
+	replay *world.Replay
 }
 
 // Init is used to set up all initial game structures.
@@ -45,6 +49,14 @@ func (g *Game) Init() (err error) {
 	// Setup audio context.
 	audio.NewContext(48000)
 
+	// Layer any user and --data assets over our own, before anything gets loaded.
+	if err := data.OverlayAssets(g.Options.Data); err != nil {
+		return err
+	}
+	if err := data.LoadMods(); err != nil {
+		fmt.Println("couldn't load mods", err)
+	}
+
 	// Load lang
 	err = data.InitLang()
 	if err != nil {
@@ -71,6 +83,8 @@ func (g *Game) Init() (err error) {
 	// FIXME: Don't manually network connect here. This should be handled in some intermediate state, like "preplay" or a lobby.
 	if g.Options.Host != "" || g.Options.Join != "" || g.Options.Await || g.Options.Search != "" {
 		g.net = net.NewConnection(g.Options.Name)
+		g.net.Spectator = g.Options.Spectate
+		g.net.SetPassword(g.Options.Password)
 		if g.Options.Host != "" {
 			if err := g.net.AwaitDirect(g.Options.Host, ""); err != nil {
 				panic(err)
@@ -91,6 +105,17 @@ func (g *Game) Init() (err error) {
 		go g.net.Loop()
 	}
 
+	// Load up our replay and skip straight to its level.
+	if g.Options.Replay != "" {
+		if g.replay, err = world.LoadReplay(g.Options.Replay); err != nil {
+			return err
+		}
+		g.Options.Map = g.replay.Level
+		g.Options.Speed = g.replay.Speed
+		g.Options.Seed = g.replay.Seed
+		g.Options.NoMenu = true
+	}
+
 	// Set our initial menu state.
 	if err := g.SetState(&MenuState{
 		game: g,
@@ -114,6 +139,26 @@ func (g *Game) GetPlayerByName(p string) *world.Player {
 	return nil
 }
 
+// AddPlayer adds a player, such as one that joined mid-game.
+func (g *Game) AddPlayer(p *world.Player) {
+	g.players = append(g.players, p)
+}
+
+// RemovePlayer removes a player, such as one that turned out to be a spectator.
+func (g *Game) RemovePlayer(p *world.Player) {
+	for i, pl := range g.players {
+		if pl == p {
+			g.players = append(g.players[:i], g.players[i+1:]...)
+			return
+		}
+	}
+}
+
+// Spectating returns if we've joined someone's game only to watch.
+func (g *Game) Spectating() bool {
+	return g.net.Active() && !g.net.Hosting() && g.net.Spectator
+}
+
 func (g *Game) Net() *net.Connection {
 	return &g.net
 }
@@ -126,8 +171,11 @@ func (g *Game) Update() error {
 	if g.net.Active() {
 		if g.net.Disconnected() {
 			g.lostConnectionTimer++
+		} else {
+			g.lostConnectionTimer = 0
 		}
-		if g.lostConnectionTimer >= 300 {
+		// Give the connection 30 seconds to come back before giving up.
+		if g.lostConnectionTimer >= 1800 {
 			g.lostConnectionTimer = 0
 			g.net.Close()
 			g.SetState(&MenuState{
@@ -164,8 +212,17 @@ func (g *Game) Draw(screen *ebiten.Image) {
 				float64(world.ScreenHeight-img.Bounds().Dy()-8),
 			)
 			screen.DrawImage(img, op)
+
+			// Show a warning to the left of our icon if our world has drifted from the host's.
+			if s, ok := g.state.(*PlayState); ok && s.world.Desynced() {
+				bounds := text.BoundString(data.BoldFace, data.GiveMeString(lang.MessageDesync))
+				x := world.ScreenWidth - img.Bounds().Dx() - 12 - bounds.Dx()
+				data.DrawStaticTextByCode(lang.MessageDesync, data.BoldFace, x, world.ScreenHeight-8, color.RGBA{255, 64, 64, 255}, screen, false)
+			}
 		}
-		if g.net.Disconnected() {
+		if g.net.Disconnected() && g.lostConnectionTimer < 1800 {
+			data.DrawStaticTextByCode(lang.MessageReconnecting, data.BoldFace, world.ScreenWidth/2, world.ScreenHeight/2, color.White, screen, true)
+		} else if g.net.Disconnected() {
 			data.DrawStaticTextByCode(lang.MessageConnectionLost, data.BoldFace, world.ScreenWidth/2, world.ScreenHeight/2, color.White, screen, true)
 		}
 	} else {
@@ -179,7 +236,7 @@ func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
 
 func (g *Game) SetState(s State) error {
 	if g.state != nil {
-		if err := s.Dispose(); err != nil {
+		if err := g.state.Dispose(); err != nil {
 			panic(err)
 		}
 	}
@@ -0,0 +1,110 @@
+package game
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/data/assets/lang"
+	"github.com/kettek/ebijam22/pkg/net"
+)
+
+// maxListedLANGames is how many LAN games fit on the network menu.
+const maxListedLANGames = 5
+
+type LANList struct {
+	x       int
+	browser *net.LANBrowser
+	buttons []*data.Button
+	listed  []string // What the buttons say, so they're only remade when something changes.
+	elapsed int
+	failed  bool
+	onJoin  func(game net.LANGame)
+}
+
+// Init starts listening for LAN games, listing them centered on x.
+func (l *LANList) Init(x int, onJoin func(game net.LANGame)) {
+	l.x = x
+	l.onJoin = onJoin
+	browser, err := net.NewLANBrowser()
+	if err != nil {
+		fmt.Println("failed to listen for LAN games", err)
+		l.failed = true
+		return
+	}
+	l.browser = browser
+}
+
+// Close stops listening for LAN games.
+func (l *LANList) Close() {
+	if l.browser != nil {
+		l.browser.Close()
+		l.browser = nil
+	}
+}
+
+func (l *LANList) setGames(games []net.LANGame) {
+	if len(games) > maxListedLANGames {
+		games = games[:maxListedLANGames]
+	}
+	var listed []string
+	for _, g := range games {
+		txt := fmt.Sprintf("%s - %s (%d %s)", g.Name, g.Map, g.Slots, data.GiveMeString(lang.LANOpenSlots))
+		if g.Password {
+			txt += " " + data.GiveMeString(lang.RoomPassword)
+		}
+		if !g.Compatible() {
+			txt += " " + data.GiveMeString(lang.LANIncompatible)
+		}
+		listed = append(listed, txt)
+	}
+	if fmt.Sprint(listed) == fmt.Sprint(l.listed) {
+		return
+	}
+	l.listed = listed
+
+	l.buttons = nil
+	y := 20
+	for i, g := range games {
+		(func(g net.LANGame) {
+			b := data.NewButton(l.x, y, listed[i], func() {
+				if g.Compatible() {
+					l.onJoin(g)
+				}
+			})
+			b.Hover = g.Compatible()
+			l.buttons = append(l.buttons, b)
+			y += 16
+		})(g)
+	}
+}
+
+func (l *LANList) Update() {
+	// Broadcasts come in every second, so there's no need to check much more often than that.
+	if l.browser != nil {
+		l.elapsed++
+		if l.elapsed >= 30 {
+			l.elapsed = 0
+			l.setGames(l.browser.Games())
+		}
+	}
+	for _, b := range l.buttons {
+		b.Update()
+	}
+}
+
+func (l *LANList) Draw(screen *ebiten.Image, op *ebiten.DrawImageOptions) {
+	y := int(op.GeoM.Element(1, 2))
+	data.DrawStaticTextByCode(lang.LANGames, data.BoldFace, l.x, y, color.White, screen, true)
+	if len(l.buttons) == 0 {
+		code := lang.NoLANGames
+		if l.failed {
+			code = lang.LANUnavailable
+		}
+		data.DrawStaticTextByCode(code, data.NormalFace, l.x, y+20, color.Gray{Y: 160}, screen, true)
+	}
+	for _, b := range l.buttons {
+		b.Draw(screen, op)
+	}
+}
@@ -0,0 +1,100 @@
+package game
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/data/assets/lang"
+	"github.com/kettek/ebijam22/pkg/net"
+	"github.com/kettek/ebijam22/pkg/world"
+)
+
+// maxListedRooms is how many rooms fit on the network menu.
+const maxListedRooms = 5
+
+type RoomList struct {
+	buttons    []*data.Button
+	refresh    *data.Button
+	results    chan []net.Room
+	refreshing bool
+	handshaker string
+	onJoin     func(id int)
+}
+
+func (r *RoomList) Init(handshaker string, onJoin func(id int)) {
+	r.handshaker = handshaker
+	r.onJoin = onJoin
+	r.results = make(chan []net.Room, 1)
+	r.refresh = data.NewButton(world.ScreenWidth/2+60, 0, lang.RefreshRooms, func() {
+		r.Refresh()
+	})
+	r.refresh.Hover = true
+	r.Refresh()
+}
+
+// Refresh asks the handshaker for its rooms in the background.
+func (r *RoomList) Refresh() {
+	if r.refreshing {
+		return
+	}
+	r.refreshing = true
+	go func() {
+		rooms, err := net.ListRooms(r.handshaker)
+		if err != nil {
+			fmt.Println("failed to list rooms", err)
+		}
+		r.results <- rooms
+	}()
+}
+
+func (r *RoomList) setRooms(rooms []net.Room) {
+	r.buttons = nil
+	if len(rooms) > maxListedRooms {
+		rooms = rooms[len(rooms)-maxListedRooms:]
+	}
+	y := 20
+	for _, room := range rooms {
+		(func(room net.Room) {
+			txt := fmt.Sprintf("%s - %s (%d)", room.Title, room.Map, room.Players)
+			if room.Password {
+				txt += " " + data.GiveMeString(lang.RoomPassword)
+			}
+			b := data.NewButton(world.ScreenWidth/2, y, txt, func() {
+				r.onJoin(room.ID)
+			})
+			b.Hover = true
+			r.buttons = append(r.buttons, b)
+			y += 16
+		})(room)
+	}
+}
+
+func (r *RoomList) Update() {
+	select {
+	case rooms := <-r.results:
+		r.refreshing = false
+		r.setRooms(rooms)
+	default:
+	}
+	r.refresh.Update()
+	for _, b := range r.buttons {
+		b.Update()
+	}
+}
+
+func (r *RoomList) Draw(screen *ebiten.Image, op *ebiten.DrawImageOptions) {
+	data.DrawStaticTextByCode(lang.Rooms, data.BoldFace, world.ScreenWidth/2-60, int(op.GeoM.Element(1, 2)), color.White, screen, true)
+	r.refresh.Draw(screen, op)
+	if len(r.buttons) == 0 {
+		code := lang.NoRooms
+		if r.refreshing {
+			code = lang.RefreshingRooms
+		}
+		data.DrawStaticTextByCode(code, data.NormalFace, world.ScreenWidth/2, int(op.GeoM.Element(1, 2))+20, color.Gray{Y: 160}, screen, true)
+	}
+	for _, b := range r.buttons {
+		b.Draw(screen, op)
+	}
+}
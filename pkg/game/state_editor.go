@@ -0,0 +1,626 @@
+package game
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/data/assets/lang"
+	"github.com/kettek/ebijam22/pkg/world"
+)
+
+// editorBrush is something the editor can paint, as the rune the level format uses for it.
+type editorBrush struct {
+	r    rune
+	code string
+}
+
+// editorBrushes are the brushes listed in the editor, in order.
+var editorBrushes = []editorBrush{
+	{'.', lang.EditorNegative},
+	{',', lang.EditorPositive},
+	{'_', lang.EditorNeutral},
+	{'#', lang.EditorBlocked},
+	{' ', lang.EditorEmpty},
+	{'v', lang.EditorPath},
+	{'N', lang.EditorNorthSpawner},
+	{'S', lang.EditorSouthSpawner},
+	{'C', lang.EditorCore},
+	{'@', lang.EditorPlayerStart},
+}
+
+// Where the level is drawn from, before panning.
+const (
+	editorGridX = 110
+	editorGridY = 40
+)
+
+// EditorState lets levels be painted, given waves, play-tested, and saved back out as level files.
+type EditorState struct {
+	game  *Game
+	name  string
+	level data.LevelConfig
+	lines []editorLine // The waves for each spawner, in the same order as the level's W lines.
+	brush rune
+
+	tileset    data.TileSet
+	panX, panY float64
+	showWaves  bool
+	spawner    int // The line being shown in the waves panel.
+	wave       int // The wave of that line being shown.
+	status     string
+
+	buttons     []*data.Button
+	brushes     []*data.Button
+	inputs      []*data.TextInput
+	waveButtons []*data.Button
+	waveInputs  []*data.TextInput
+	waveTitle   string
+}
+
+func (s *EditorState) Init() error {
+	// We come back here after play-testing, so only start a new level the first time.
+	if s.level.Cells == nil {
+		s.name = "custom"
+		s.newLevel()
+	}
+	if s.brush == 0 {
+		s.brush = '.'
+	}
+	s.loadTileset()
+
+	backButton := data.NewButton(15, 10, lang.Back, func() {
+		s.game.SetState(&MenuState{
+			game: s.game,
+		})
+	})
+	backButton.Hover = true
+	s.buttons = []*data.Button{backButton}
+
+	// Brushes go down the left side.
+	s.brushes = nil
+	y := 40
+	for _, b := range editorBrushes {
+		(func(b editorBrush) {
+			button := data.NewButton(55, y, b.code, func() {
+				s.brush = b.r
+			})
+			button.Hover = true
+			s.brushes = append(s.brushes, button)
+		})(b)
+		y += 16
+	}
+	y += 16
+	wavesButton := data.NewButton(55, y, lang.EditorWaves, func() {
+		s.showWaves = !s.showWaves
+		s.buildWavePanel()
+	})
+	wavesButton.Hover = true
+	s.buttons = append(s.buttons, wavesButton)
+
+	// Level details down the right side.
+	x := world.ScreenWidth - 90
+	s.inputs = nil
+	nameInput := data.NewTextInput(lang.EditorName, s.name, 16, x, 40)
+	nameInput.OnChange = func(t string) {
+		s.name = t
+	}
+	titleInput := data.NewTextInput(lang.EditorTitle, s.level.Title, 16, x, 80)
+	titleInput.OnChange = func(t string) {
+		s.level.Title = t
+	}
+	tilesetInput := data.NewTextInput(lang.EditorTileset, s.level.Tileset, 16, x, 120)
+	tilesetInput.OnChange = func(t string) {
+		s.level.Tileset = t
+		s.loadTileset()
+	}
+	nextInput := data.NewTextInput(lang.EditorNext, s.level.Next, 16, x, 160)
+	nextInput.OnChange = func(t string) {
+		s.level.Next = t
+	}
+	pointsInput := data.NewTextInput(lang.EditorPoints, strconv.Itoa(s.level.Points), 6, x-30, 200)
+	pointsInput.OnChange = func(t string) {
+		if v, err := strconv.Atoi(t); err == nil {
+			s.level.Points = v
+		}
+	}
+	widthInput := data.NewTextInput(lang.EditorWidth, strconv.Itoa(s.level.Width), 3, x-30, 240)
+	widthInput.OnChange = func(t string) {
+		if v, err := strconv.Atoi(t); err == nil && v > 0 && v <= 100 {
+			s.level.Resize(v, s.level.Height)
+			s.syncLines()
+		}
+	}
+	heightInput := data.NewTextInput(lang.EditorHeight, strconv.Itoa(s.level.Height), 3, x+30, 240)
+	heightInput.OnChange = func(t string) {
+		if v, err := strconv.Atoi(t); err == nil && v > 0 && v <= 100 {
+			s.level.Resize(s.level.Width, v)
+			s.syncLines()
+		}
+	}
+	s.inputs = append(s.inputs, nameInput, titleInput, tilesetInput, nextInput, pointsInput, widthInput, heightInput)
+
+	y = 280
+	for _, b := range []struct {
+		code    string
+		onClick func()
+	}{
+		{lang.EditorPlayTest, s.PlayTest},
+		{lang.EditorSave, s.Save},
+		{lang.EditorLoad, s.Load},
+		{lang.EditorNew, func() {
+			s.newLevel()
+			s.game.SetState(s)
+		}},
+	} {
+		button := data.NewButton(x, y, b.code, b.onClick)
+		button.Hover = true
+		s.buttons = append(s.buttons, button)
+		y += 16
+	}
+
+	s.syncLines()
+	s.buildWavePanel()
+	return nil
+}
+
+func (s *EditorState) Dispose() error {
+	return nil
+}
+
+// newLevel starts over with an empty level.
+func (s *EditorState) newLevel() {
+	s.level = data.LevelConfig{
+		Tileset: "nature",
+	}
+	s.level.Resize(15, 11)
+	for y := 0; y < s.level.Height; y++ {
+		for x := 0; x < s.level.Width; x++ {
+			s.level.SetCellRune(x, y, '.')
+		}
+	}
+	s.lines = nil
+	s.spawner = 0
+	s.wave = 0
+	s.panX, s.panY = 0, 0
+}
+
+// loadTileset loads the level's tileset, keeping the last one if it doesn't exist.
+func (s *EditorState) loadTileset() {
+	name := s.level.Tileset
+	if name == "" {
+		name = "nature"
+	}
+	if ts, err := data.LoadTileSet(name); err == nil {
+		s.tileset = ts
+	}
+}
+
+// levelDir returns where levels are saved to. By default this is the per-user assets, so that saved levels show up with the rest.
+func (s *EditorState) levelDir() string {
+	if s.game.Options.LevelDir != "" {
+		return s.game.Options.LevelDir
+	}
+	if dir, err := data.UserAssetDir(); err == nil {
+		return filepath.Join(dir, "levels")
+	}
+	return "levels"
+}
+
+// checkName returns why the level's name can't be used as a file name, or an empty string if it can. Anything that could lead outside the level directory is turned away.
+func (s *EditorState) checkName() string {
+	if s.name == "" {
+		return data.GiveMeString(lang.EditorNeedsName)
+	}
+	if strings.ContainsAny(s.name, `/\:`) || strings.Contains(s.name, "..") {
+		return data.GiveMeString(lang.EditorBadName)
+	}
+	return ""
+}
+
+// levelPath returns where the level is saved to.
+func (s *EditorState) levelPath() string {
+	return filepath.Join(s.levelDir(), s.name+".txt")
+}
+
+// Level returns the level as it is being edited, waves and all.
+func (s *EditorState) Level() data.Level {
+	l := s.level
+	l.Waves = nil
+	spawners := s.spawners()
+	for i, line := range s.lines {
+		if i >= len(spawners) {
+			// No spawner to go with it, so there's no point keeping it.
+			break
+		}
+		l.Waves = append(l.Waves, line.Wave())
+	}
+	return data.Level{LevelConfig: l}
+}
+
+// Save writes the level out to the level directory.
+func (s *EditorState) Save() {
+	if status := s.checkName(); status != "" {
+		s.status = status
+		return
+	}
+	level := s.Level()
+	if err := os.MkdirAll(s.levelDir(), 0755); err != nil {
+		s.status = err.Error()
+		return
+	}
+	if err := os.WriteFile(s.levelPath(), level.Bytes(), 0644); err != nil {
+		s.status = err.Error()
+		return
+	}
+	s.status = fmt.Sprintf("%s %s", data.GiveMeString(lang.EditorSaved), s.levelPath())
+}
+
+// Load reads the named level, preferring one saved to the level directory over the game's own.
+func (s *EditorState) Load() {
+	if status := s.checkName(); status != "" {
+		s.status = status
+		return
+	}
+	var level data.LevelConfig
+	if b, err := os.ReadFile(s.levelPath()); err == nil {
+		err = level.LoadFromBytes(s.levelPath(), b)
+		if err != nil {
+			s.status = err.Error()
+			return
+		}
+	} else if l, err := data.NewLevel(s.name); err == nil {
+		level = l.LevelConfig
+	} else {
+		s.status = err.Error()
+		return
+	}
+	level.Resize(level.Width, level.Height)
+	s.level = level
+	s.lines = nil
+	for _, w := range level.Waves {
+		s.lines = append(s.lines, newEditorLine(w))
+	}
+	s.level.Waves = nil
+	s.spawner = 0
+	s.wave = 0
+	s.panX, s.panY = 0, 0
+	s.status = fmt.Sprintf("%s %s", data.GiveMeString(lang.EditorLoaded), s.name)
+	// Rebuild everything, as the inputs show what was there before.
+	s.game.SetState(s)
+}
+
+// PlayTest plays the level as it is right now, coming back here when done.
+func (s *EditorState) PlayTest() {
+	s.game.SetState(&PlayState{
+		game:          s.game,
+		level:         s.Level(),
+		levelDataName: s.name,
+		editor:        s,
+	})
+}
+
+// spawners returns the positions of the level's spawners, in the order their waves are given.
+func (s *EditorState) spawners() (spawners [][2]int) {
+	for y, r := range s.level.Cells {
+		for x, c := range r {
+			if c.Kind == data.NorthSpawnCell || c.Kind == data.SouthSpawnCell {
+				spawners = append(spawners, [2]int{x, y})
+			}
+		}
+	}
+	return spawners
+}
+
+// syncLines makes sure every spawner has waves, as a spawner without any can't be saved.
+func (s *EditorState) syncLines() {
+	for len(s.lines) < len(s.spawners()) {
+		s.lines = append(s.lines, editorLine{editorWave{s.defaultSpawn()}})
+	}
+}
+
+// defaultSpawn is what new waves start out spawning.
+func (s *EditorState) defaultSpawn() data.SpawnList {
+	kinds := s.enemyKinds()
+	kind := "walker"
+	if _, ok := data.EnemyConfigs[kind]; !ok && len(kinds) > 0 {
+		kind = kinds[0]
+	}
+	return data.SpawnList{
+		Kinds:     []string{kind},
+		Count:     1,
+		Spawnrate: 20,
+	}
+}
+
+// enemyKinds returns the enemies that waves can spawn, sorted.
+func (s *EditorState) enemyKinds() (kinds []string) {
+	for k := range data.EnemyConfigs {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// cellAt returns the level cell under the given screen position.
+func (s *EditorState) cellAt(x, y int) (int, int, bool) {
+	cx := int(math.Floor((float64(x) - editorGridX - s.panX) / float64(data.CellWidth)))
+	cy := int(math.Floor((float64(y) - editorGridY - s.panY) / float64(data.CellHeight)))
+	if cx < 0 || cy < 0 || cx >= s.level.Width || cy >= s.level.Height {
+		return 0, 0, false
+	}
+	return cx, cy, true
+}
+
+func (s *EditorState) Update() error {
+	for _, b := range s.buttons {
+		b.Update()
+	}
+	for i, b := range s.brushes {
+		b.Active = editorBrushes[i].r == s.brush
+		b.Update()
+	}
+	for _, input := range s.inputs {
+		input.Update()
+	}
+
+	if s.showWaves {
+		for _, b := range s.waveButtons {
+			b.Update()
+		}
+		for _, input := range s.waveInputs {
+			input.Update()
+		}
+		return nil
+	}
+
+	// Pan around bigger levels.
+	if !data.CurrentlyReceivingInput() {
+		if ebiten.IsKeyPressed(ebiten.KeyLeft) {
+			s.panX += 2
+		} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
+			s.panX -= 2
+		}
+		if ebiten.IsKeyPressed(ebiten.KeyUp) {
+			s.panY += 2
+		} else if ebiten.IsKeyPressed(ebiten.KeyDown) {
+			s.panY -= 2
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyF5) {
+			s.PlayTest()
+			return nil
+		}
+	}
+
+	// Paint with the left button, clear with the right.
+	mx, my := ebiten.CursorPosition()
+	if x, y, ok := s.cellAt(mx, my); ok {
+		if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+			s.paint(x, y, s.brush)
+		} else if ebiten.IsMouseButtonPressed(ebiten.MouseButtonRight) {
+			s.paint(x, y, ' ')
+		}
+	}
+	return nil
+}
+
+// paint sets the cell, keeping each spawner's waves with it.
+func (s *EditorState) paint(x, y int, r rune) {
+	if s.level.CellRune(x, y) == r {
+		return
+	}
+	// Waves go to spawners by their order, so adding or removing one needs to shift those after it.
+	before := s.spawners()
+	s.level.SetCellRune(x, y, r)
+	after := s.spawners()
+	if len(after) > len(before) {
+		for i, p := range after {
+			if p == [2]int{x, y} && i < len(s.lines) {
+				s.lines = append(s.lines[:i], append([]editorLine{{editorWave{s.defaultSpawn()}}}, s.lines[i:]...)...)
+				break
+			}
+		}
+	} else if len(after) < len(before) {
+		for i, p := range before {
+			if p == [2]int{x, y} && i < len(s.lines) {
+				s.lines = append(s.lines[:i], s.lines[i+1:]...)
+				break
+			}
+		}
+	}
+	s.syncLines()
+}
+
+// buildWavePanel recreates the waves panel for the current spawner and wave.
+func (s *EditorState) buildWavePanel() {
+	s.waveButtons = nil
+	s.waveInputs = nil
+	spawners := s.spawners()
+	if len(spawners) == 0 {
+		s.waveTitle = data.GiveMeString(lang.EditorNoSpawners)
+		return
+	}
+	if s.spawner >= len(spawners) {
+		s.spawner = len(spawners) - 1
+	}
+	line := s.lines[s.spawner]
+	if s.wave >= len(line) {
+		s.wave = len(line) - 1
+	}
+	wave := line[s.wave]
+	s.waveTitle = fmt.Sprintf("%s %d/%d (%d, %d) - %s %d/%d",
+		data.GiveMeString(lang.EditorSpawner), s.spawner+1, len(spawners), spawners[s.spawner][0], spawners[s.spawner][1],
+		data.GiveMeString(lang.Wave), s.wave+1, len(line),
+	)
+
+	x := world.ScreenWidth/2 - 40
+	button := func(x, y int, code string, onClick func()) {
+		b := data.NewButton(x, y, code, func() {
+			onClick()
+			s.buildWavePanel()
+		})
+		b.Hover = true
+		s.waveButtons = append(s.waveButtons, b)
+	}
+	button(x-100, 60, lang.EditorPrevSpawner, func() {
+		if s.spawner > 0 {
+			s.spawner--
+			s.wave = 0
+		}
+	})
+	button(x+100, 60, lang.EditorNextSpawner, func() {
+		if s.spawner < len(spawners)-1 {
+			s.spawner++
+			s.wave = 0
+		}
+	})
+	button(x-100, 76, lang.EditorPrevWave, func() {
+		if s.wave > 0 {
+			s.wave--
+		}
+	})
+	button(x+100, 76, lang.EditorNextWave, func() {
+		if s.wave < len(s.lines[s.spawner])-1 {
+			s.wave++
+		}
+	})
+	button(x-100, 92, lang.EditorAddWave, func() {
+		l := s.lines[s.spawner]
+		l = append(l[:s.wave+1], append([]editorWave{{s.defaultSpawn()}}, l[s.wave+1:]...)...)
+		s.lines[s.spawner] = l
+		s.wave++
+	})
+	button(x+100, 92, lang.EditorRemoveWave, func() {
+		l := s.lines[s.spawner]
+		if len(l) > 1 {
+			s.lines[s.spawner] = append(l[:s.wave], l[s.wave+1:]...)
+		}
+	})
+	button(x-100, 108, lang.EditorAddSpawn, func() {
+		if len(s.lines[s.spawner][s.wave]) < maxEditorSpawns {
+			s.lines[s.spawner][s.wave] = append(s.lines[s.spawner][s.wave], s.defaultSpawn())
+		}
+	})
+	button(x+100, 108, lang.EditorRemoveSpawn, func() {
+		w := s.lines[s.spawner][s.wave]
+		if len(w) > 1 {
+			s.lines[s.spawner][s.wave] = w[:len(w)-1]
+		}
+	})
+
+	// One row of inputs for each spawn in the wave.
+	y := 140
+	for i := range wave {
+		spawn := &s.lines[s.spawner][s.wave][i]
+		count := data.NewTextInput(lang.EditorCount, strconv.Itoa(spawn.Count), 4, x-110, y)
+		count.OnChange = func(t string) {
+			if v, err := strconv.Atoi(t); err == nil && v > 0 {
+				spawn.Count = v
+			}
+		}
+		delay := data.NewTextInput(lang.EditorDelay, strconv.Itoa(spawn.Spawnrate), 4, x-60, y)
+		delay.OnChange = func(t string) {
+			if v, err := strconv.Atoi(t); err == nil && v >= 0 {
+				spawn.Spawnrate = v
+			}
+		}
+		kinds := data.NewTextInput(lang.EditorEnemies, joinKinds(spawn.Kinds), 20, x+60, y)
+		kinds.OnChange = func(t string) {
+			spawn.Kinds = splitKinds(t)
+			s.status = ""
+			for _, k := range spawn.Kinds {
+				if _, ok := data.EnemyConfigs[k]; !ok {
+					s.status = fmt.Sprintf("%s %s", data.GiveMeString(lang.EditorUnknownEnemy), k)
+				}
+			}
+		}
+		s.waveInputs = append(s.waveInputs, count, delay, kinds)
+		y += 36
+	}
+}
+
+func (s *EditorState) Draw(screen *ebiten.Image) {
+	screen.Fill(color.RGBA{16, 16, 24, 255})
+
+	if s.showWaves {
+		s.drawWaves(screen)
+	} else {
+		s.drawLevel(screen)
+	}
+
+	op := ebiten.DrawImageOptions{}
+	for _, b := range s.buttons {
+		b.Draw(screen, &op)
+	}
+	for _, b := range s.brushes {
+		b.Draw(screen, &op)
+	}
+	for _, input := range s.inputs {
+		input.Draw(screen, &op)
+	}
+
+	data.DrawStaticTextByCode(lang.LevelEditor, data.BoldFace, world.ScreenWidth/2, 10, color.White, screen, true)
+	if s.status != "" {
+		data.DrawStaticText(s.status, data.NormalFace, world.ScreenWidth/2, world.ScreenHeight-12, color.RGBA{255, 255, 0, 255}, screen, true)
+	}
+}
+
+func (s *EditorState) drawLevel(screen *ebiten.Image) {
+	// Draw the level a lot like the world does, with the runes for anything that's more than a tile.
+	for y := 0; y < s.level.Height; y++ {
+		for x := 0; x < s.level.Width; x++ {
+			px := editorGridX + s.panX + float64(x*data.CellWidth)
+			py := editorGridY + s.panY + float64(y*data.CellHeight)
+			op := &ebiten.DrawImageOptions{}
+			op.GeoM.Translate(px, py)
+			c := s.level.Cells[y][x]
+			switch c.Kind {
+			case data.BlockedCell:
+				op.GeoM.Translate(0, -11)
+				screen.DrawImage(s.tileset.BlockedImage, op)
+			case data.EmptyCell:
+				ebitenutil.DrawRect(screen, px, py, float64(data.CellWidth), float64(data.CellHeight), color.RGBA{40, 40, 48, 255})
+			default:
+				if c.Polarity == data.PositivePolarity {
+					screen.DrawImage(s.tileset.OpenPositiveImage, op)
+				} else if c.Polarity == data.NegativePolarity {
+					screen.DrawImage(s.tileset.OpenNegativeImage, op)
+				} else {
+					screen.DrawImage(s.tileset.OpenNeutralImage, op)
+				}
+			}
+			if c.Kind != data.NoneCell && c.Kind != data.BlockedCell && c.Kind != data.EmptyCell {
+				data.DrawStaticText(string(s.level.CellRune(x, y)), data.NormalFace, int(px)+data.CellWidth/2, int(py)+data.CellHeight/2-2, color.White, screen, true)
+			}
+		}
+	}
+
+	// Show what cell would be painted.
+	mx, my := ebiten.CursorPosition()
+	if x, y, ok := s.cellAt(mx, my); ok {
+		ebitenutil.DrawRect(screen, editorGridX+s.panX+float64(x*data.CellWidth), editorGridY+s.panY+float64(y*data.CellHeight), float64(data.CellWidth), float64(data.CellHeight), color.RGBA{255, 255, 255, 80})
+	}
+}
+
+func (s *EditorState) drawWaves(screen *ebiten.Image) {
+	x := world.ScreenWidth/2 - 40
+	data.DrawStaticText(s.waveTitle, data.NormalFace, x, 40, color.White, screen, true)
+	op := ebiten.DrawImageOptions{}
+	for _, b := range s.waveButtons {
+		b.Draw(screen, &op)
+	}
+	for _, input := range s.waveInputs {
+		input.Draw(screen, &op)
+	}
+	kinds := joinKinds(s.enemyKinds())
+	data.DrawStaticText(kinds, data.NormalFace, x, world.ScreenHeight-32, color.Gray{Y: 160}, screen, true)
+}
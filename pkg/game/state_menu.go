@@ -99,6 +99,30 @@ func (s *MenuState) Init() error {
 	)
 	networkButton.Hover = true
 	y += networkButton.Image().Bounds().Dy() * 3
+	editorButton := data.NewButton(
+		x,
+		y,
+		lang.LevelEditor,
+		func() {
+			s.game.SetState(&EditorState{
+				game: s.game,
+			})
+		},
+	)
+	editorButton.Hover = true
+	y += editorButton.Image().Bounds().Dy() * 3
+	modsButton := data.NewButton(
+		x,
+		y,
+		lang.Mods,
+		func() {
+			s.game.SetState(&ModsMenuState{
+				game: s.game,
+			})
+		},
+	)
+	modsButton.Hover = true
+	y += modsButton.Image().Bounds().Dy() * 3
 	exitButton := data.NewButton(
 		x,
 		y,
@@ -203,6 +227,8 @@ func (s *MenuState) Init() error {
 	s.buttons = []*data.Button{
 		startGameButton,
 		networkButton,
+		editorButton,
+		modsButton,
 		exitButton,
 		credits1aButton,
 		credits1bButton,
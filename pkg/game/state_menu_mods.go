@@ -0,0 +1,194 @@
+package game
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/data/assets/lang"
+	"github.com/kettek/ebijam22/pkg/data/ui"
+	"github.com/kettek/ebijam22/pkg/world"
+)
+
+// maxListedMods is how many mods fit on the mods menu.
+const maxListedMods = 10
+
+type ModsMenuState struct {
+	game  *Game
+	title string
+
+	tiledBackgroundImages  []*ebiten.Image
+	tiledBackgroundElapsed int
+	tiledBackgroundIndex   int
+
+	buttons    []*data.Button
+	modButtons []*data.Button
+	changed    bool
+	status     string
+}
+
+func (s *ModsMenuState) Init() error {
+	t, err := data.LoadTileSet("magnet")
+	if err != nil {
+		return err
+	}
+	s.tiledBackgroundImages = t.BackgroundImages
+
+	// Title Text
+	s.title = lang.Mods
+
+	backButton := data.NewButton(
+		15,
+		10,
+		lang.Back,
+		func() {
+			s.Apply()
+			s.game.SetState(&MenuState{
+				game: s.game,
+			})
+		},
+	)
+	backButton.Hover = true
+	applyButton := data.NewButton(
+		world.ScreenWidth/2,
+		world.ScreenHeight-40,
+		lang.ApplyMods,
+		func() {
+			s.Apply()
+		},
+	)
+	applyButton.Hover = true
+	s.buttons = []*data.Button{
+		backButton,
+		applyButton,
+	}
+
+	s.buildModButtons()
+	return nil
+}
+
+// buildModButtons recreates the list of mods, each with its toggle and buttons to move it up and down the load order.
+func (s *ModsMenuState) buildModButtons() {
+	s.modButtons = nil
+	y := 60
+	for i, m := range data.Mods {
+		if i >= maxListedMods {
+			break
+		}
+		(func(i int, m *data.Mod) {
+			check := "[ ]"
+			if m.Enabled {
+				check = "[x]"
+			}
+			toggle := data.NewButton(world.ScreenWidth/2-100, y, fmt.Sprintf("%s %s %s", check, m.Name, m.Version), func() {
+				m.Enabled = !m.Enabled
+				s.changed = true
+				s.buildModButtons()
+			})
+			toggle.Hover = true
+			toggle.Active = m.Problem != ""
+			up := data.NewButton(world.ScreenWidth/2+120, y, "^", func() {
+				data.MoveMod(i, i-1)
+				s.changed = true
+				s.buildModButtons()
+			})
+			up.Hover = true
+			down := data.NewButton(world.ScreenWidth/2+140, y, "v", func() {
+				data.MoveMod(i, i+1)
+				s.changed = true
+				s.buildModButtons()
+			})
+			down.Hover = true
+			s.modButtons = append(s.modButtons, toggle, up, down)
+		})(i, m)
+		y += 16
+	}
+}
+
+// Apply saves the load order and reloads the configurations with it, if anything changed.
+func (s *ModsMenuState) Apply() {
+	if !s.changed {
+		return
+	}
+	s.changed = false
+	if err := data.SaveModOrder(); err != nil {
+		s.status = err.Error()
+	}
+	if err := data.ApplyMods(); err != nil {
+		s.status = err.Error()
+	} else {
+		s.status = data.GiveMeString(lang.ModsApplied)
+	}
+	s.buildModButtons()
+}
+
+func (s *ModsMenuState) Dispose() error {
+	return nil
+}
+
+func (s *ModsMenuState) Update() error {
+	// Animate the background.
+	s.tiledBackgroundElapsed++
+	if s.tiledBackgroundElapsed >= 30 {
+		s.tiledBackgroundElapsed = 0
+		s.tiledBackgroundIndex++
+		if s.tiledBackgroundIndex >= len(s.tiledBackgroundImages) {
+			s.tiledBackgroundIndex = 0
+		}
+	}
+
+	for _, button := range s.buttons {
+		button.Update()
+	}
+	for _, button := range s.modButtons {
+		button.Update()
+	}
+	return nil
+}
+
+func (s *ModsMenuState) Draw(screen *ebiten.Image) {
+	// Draw our tiled background.
+	bgOp := ebiten.DrawImageOptions{}
+	bgOp.ColorM.Scale(0.5, 0.5, 0.5, 1)
+	ui.DrawTiled(screen, s.tiledBackgroundImages[s.tiledBackgroundIndex], &bgOp, world.ScreenWidth, world.ScreenHeight)
+
+	data.DrawStaticTextByCode(
+		s.title,
+		data.BoldFace,
+		world.ScreenWidth/2,
+		world.ScreenHeight/8,
+		color.White,
+		screen,
+		true,
+	)
+
+	op := ebiten.DrawImageOptions{}
+	for _, button := range s.buttons {
+		button.Draw(screen, &op)
+	}
+	for _, button := range s.modButtons {
+		button.Draw(screen, &op)
+	}
+
+	if len(data.Mods) == 0 {
+		data.DrawStaticTextByCode(lang.NoMods, data.NormalFace, world.ScreenWidth/2, 60, color.Gray{Y: 160}, screen, true)
+	}
+
+	// List why mods aren't loading, and what they're fighting over.
+	y := 60 + 16*maxListedMods + 8
+	for _, m := range data.Mods {
+		if m.Enabled && m.Problem != "" {
+			data.DrawStaticText(fmt.Sprintf("%s: %s", m.Name, m.Problem), data.NormalFace, world.ScreenWidth/2, y, color.RGBA{255, 96, 96, 255}, screen, true)
+			y += 16
+		}
+	}
+	for _, c := range data.ModConflicts {
+		data.DrawStaticText(c.String(), data.NormalFace, world.ScreenWidth/2, y, color.RGBA{255, 255, 0, 255}, screen, true)
+		y += 16
+	}
+
+	if s.status != "" {
+		data.DrawStaticText(s.status, data.NormalFace, world.ScreenWidth/2, world.ScreenHeight-20, color.White, screen, true)
+	}
+}
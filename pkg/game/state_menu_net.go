@@ -22,6 +22,8 @@ type NetworkMenuState struct {
 	magnetImage *ebiten.Image
 	magnetSpin  float64
 	mapList     MapList
+	roomList    RoomList
+	lanList     LANList
 
 	tiledBackgroundImages  []*ebiten.Image
 	tiledBackgroundElapsed int
@@ -63,6 +65,16 @@ func (s *NetworkMenuState) Init() error {
 		s.mapList.selectedMap = s.game.Options.Map
 	}
 
+	// Find out what rooms the handshaker has.
+	s.roomList.Init(s.game.Options.Handshaker, func(id int) {
+		s.JoinRoom(id)
+	})
+
+	// Listen for games on the LAN.
+	s.lanList.Init(int(float64(world.ScreenWidth)*0.8), func(game net.LANGame) {
+		s.JoinLAN(game)
+	})
+
 	// Title Text
 	s.title = lang.NetworkGame
 
@@ -129,7 +141,7 @@ func (s *NetworkMenuState) Init() error {
 		buttonY+waitGameButton.Image().Bounds().Dy()*2,
 		lang.BroadcastGame,
 		func() {
-			s.AwaitLan(false)
+			s.AwaitLAN()
 		},
 	)
 	waitLanGameButton.Hover = true
@@ -143,22 +155,12 @@ func (s *NetworkMenuState) Init() error {
 		},
 	)
 	findGameButton.Hover = true
-	findLanGameButton := data.NewButton(
-		remotePlayerX,
-		buttonY+findGameButton.Image().Bounds().Dy()*2,
-		lang.FindBroadcast,
-		func() {
-			s.AwaitLan(true)
-		},
-	)
-	findLanGameButton.Hover = true
 
 	s.buttons = []*data.Button{
 		backButton,
 		hostGameButton,
 		joinGameButton,
 		findGameButton,
-		findLanGameButton,
 		waitGameButton,
 		waitLanGameButton,
 	}
@@ -258,6 +260,7 @@ func (s *NetworkMenuState) Init() error {
 }
 
 func (s *NetworkMenuState) Dispose() error {
+	s.lanList.Close()
 	return nil
 }
 
@@ -303,6 +306,8 @@ func (s *NetworkMenuState) Update() error {
 	}
 
 	s.mapList.Update()
+	s.roomList.Update()
+	s.lanList.Update()
 
 	return nil
 }
@@ -362,6 +367,11 @@ func (s *NetworkMenuState) Draw(screen *ebiten.Image) {
 
 	op.GeoM.Translate(8, 80)
 	s.mapList.Draw(screen, &op)
+
+	op.GeoM.Reset()
+	op.GeoM.Translate(0, 140)
+	s.roomList.Draw(screen, &op)
+	s.lanList.Draw(screen, &op)
 }
 
 func (s *NetworkMenuState) StartGame() {
@@ -373,6 +383,8 @@ func (s *NetworkMenuState) StartGame() {
 
 func (s *NetworkMenuState) CreateNet() {
 	s.game.net = net.NewConnection(s.playerNameInput.GetInput())
+	s.game.net.Spectator = s.game.Options.Spectate
+	s.game.net.SetPassword(s.game.Options.Password)
 }
 
 func (s *NetworkMenuState) Host() {
@@ -406,19 +418,43 @@ func (s *NetworkMenuState) Await() {
 	s.networking = true
 	s.CreateNet()
 	go func() {
-		err := s.game.net.AwaitHandshake(s.game.Options.Handshaker, "", "")
+		err := s.game.net.HostRoom(s.game.Options.Handshaker, "", s.playerNameInput.GetInput(), s.mapList.selectedMap)
+		s.netResult <- err
+	}()
+}
+
+func (s *NetworkMenuState) JoinRoom(id int) {
+	if s.networking {
+		return
+	}
+	s.networking = true
+	s.CreateNet()
+	go func() {
+		err := s.game.net.JoinRoom(s.game.Options.Handshaker, "", id)
+		s.netResult <- err
+	}()
+}
+
+func (s *NetworkMenuState) AwaitLAN() {
+	if s.networking {
+		return
+	}
+	s.networking = true
+	s.CreateNet()
+	go func() {
+		err := s.game.net.AwaitLAN(s.mapList.selectedMap)
 		s.netResult <- err
 	}()
 }
 
-func (s *NetworkMenuState) AwaitLan(joiner bool) {
+func (s *NetworkMenuState) JoinLAN(game net.LANGame) {
 	if s.networking {
 		return
 	}
 	s.networking = true
 	s.CreateNet()
 	go func() {
-		err := s.game.net.AwaitLAN(joiner)
+		err := s.game.net.JoinLAN(game)
 		s.netResult <- err
 	}()
 }
@@ -3,6 +3,9 @@ package game
 import (
 	"fmt"
 	"image/color"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
@@ -27,6 +30,8 @@ type PlayState struct {
 	helpOverlay              HelpOverlay
 	escapeMenuButtons        []data.Button
 	readyImage, unreadyImage *ebiten.Image
+	joining                  map[string]bool // Peers we've sent mid-game travel to, so we know their travel okay isn't a restart request.
+	editor                   *EditorState    // The editor we're play-testing for, if any. Leaving, finishing, or restarting goes back to it rather than traveling.
 }
 
 func (s *PlayState) Init() error {
@@ -47,6 +52,10 @@ func (s *PlayState) Init() error {
 				s.game.net.Close()
 			}
 
+			if s.editor != nil {
+				s.game.SetState(s.editor)
+				return
+			}
 			s.game.SetState(&MenuState{
 				game: s.game,
 			})
@@ -72,19 +81,67 @@ func (s *PlayState) Init() error {
 	// Add players here...?
 	s.game.players = append(s.game.players, world.NewPlayer())
 	s.game.players[0].Local = true
-	// Add other player!
-	if s.game.net.Active() {
-		s.game.players = append(s.game.players, world.NewPlayer())
-		// Set player names if networked.
+	if s.game.replay != nil {
+		// Recreate the replay's players. None of them are local, as they're driven by the replay.
+		s.world.Playback = s.game.replay
+		s.game.replay = nil
+		s.game.players[0].Local = false
+		for i, name := range s.world.Playback.Players {
+			if i > 0 {
+				s.game.players = append(s.game.players, world.NewPlayer())
+			}
+			s.game.players[i].Name = name
+			s.game.players[i].ID = i
+		}
+	} else if s.game.net.Hosting() {
+		// Add other players! The host is always 0, everyone else goes in the order they joined.
 		s.game.players[0].Name = s.game.net.Name
-		s.game.players[1].Name = s.game.net.OtherName
+		id := 1
+		for _, name := range s.game.net.Peers() {
+			if s.game.net.Spectating(name) {
+				continue
+			}
+			pl := world.NewPlayer()
+			pl.Name = name
+			pl.ID = id
+			s.game.players = append(s.game.players, pl)
+			id++
+		}
+	} else if s.game.net.Active() {
+		// We only know about the host until their snapshot tells us who else is here, and what our real ID is.
+		s.game.players[0].Name = s.game.net.Name
+		s.game.players[0].ID = 1
+		s.game.players[0].Spectator = s.game.Spectating()
+		if peers := s.game.net.Peers(); len(peers) > 0 {
+			pl := world.NewPlayer()
+			pl.Name = peers[0]
+			s.game.players = append(s.game.players, pl)
+		}
 	}
+	s.joining = make(map[string]bool)
 
 	// Build the level.
 	if err := s.world.BuildFromLevel(s.level); err != nil {
 		return err
 	}
 
+	// Clients start from the host's view of the world, in case anything happened before we arrived.
+	if s.game.net.Active() && !s.game.net.Hosting() {
+		s.world.RequestSnapshot()
+	}
+
+	// Record if we're solo or hosting. Clients don't run the real world, so there's nothing worth recording.
+	if s.game.Options.Record != "" && s.world.Playback == nil && (!s.game.net.Active() || s.game.net.Hosting()) {
+		s.world.Recording = &world.Replay{
+			Level: s.levelDataName,
+			Speed: s.world.Speed,
+			Seed:  s.world.Seed,
+		}
+		for _, pl := range s.game.players {
+			s.world.Recording.Players = append(s.world.Recording.Players, pl.Name)
+		}
+	}
+
 	s.world.Mode = &world.PreGameMode{}
 	s.clickables = []data.UIComponent{
 		data.NewBGMIcon(),
@@ -94,6 +151,20 @@ func (s *PlayState) Init() error {
 }
 
 func (s *PlayState) Dispose() error {
+	// Save our replay, if we were recording.
+	if s.world.Recording != nil {
+		if err := os.MkdirAll(s.game.Options.Record, 0755); err != nil {
+			fmt.Println("couldn't make replay directory", err)
+		} else {
+			p := filepath.Join(s.game.Options.Record, fmt.Sprintf("%s-%d.json", s.levelDataName, time.Now().Unix()))
+			if err := s.world.Recording.Save(p); err != nil {
+				fmt.Println("couldn't save replay", err)
+			} else {
+				fmt.Println("saved replay to", p)
+			}
+		}
+	}
+
 	// Remove player entity reference.
 	for _, p := range s.game.players {
 		p.Entity = nil
@@ -142,6 +213,10 @@ func (s *PlayState) Update() error {
 	case *world.VictoryMode:
 		// TODO: Show end game stats, if possible! Then some sort of "hit okay" to travel button/key.
 		if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+			if s.editor != nil {
+				s.game.SetState(s.editor)
+				return nil
+			}
 			if s.game.net.Hosting() || !s.game.net.Active() {
 				fmt.Println("TRAVELING TO NEXT")
 				s.game.SetState(&TravelState{
@@ -155,6 +230,10 @@ func (s *PlayState) Update() error {
 			if s.game.net.Active() {
 				s.game.net.Close()
 			}
+			if s.editor != nil {
+				s.game.SetState(s.editor)
+				return nil
+			}
 			s.game.SetState(&MenuState{
 				game: s.game,
 			})
@@ -163,7 +242,10 @@ func (s *PlayState) Update() error {
 
 	// If we're the host/solo and we hit R, restart the level. If we're the client, send a request.
 	if inpututil.IsKeyJustReleased(ebiten.KeyR) {
-		if s.game.net.Hosting() || !s.game.net.Active() {
+		if s.editor != nil {
+			// The level only exists in the editor, so there's nothing to travel to.
+			s.editor.PlayTest()
+		} else if s.game.net.Hosting() || !s.game.net.Active() {
 			s.game.SetState(&TravelState{
 				game:        s.game,
 				targetLevel: s.levelDataName, // ???
@@ -175,8 +257,8 @@ func (s *PlayState) Update() error {
 	}
 
 	// Handle our network updates.
-	for _, msg := range s.game.net.Messages() {
-		switch msg := msg.(type) {
+	for _, pm := range s.game.net.PeerMessages() {
+		switch msg := pm.Message.(type) {
 		case net.TravelMessage:
 			if !s.game.net.Hosting() {
 				s.game.SetState(&TravelState{
@@ -184,21 +266,50 @@ func (s *PlayState) Update() error {
 					targetLevel: msg.Destination,
 					fromLive:    true,
 				})
-			} else {
+			} else if s.joining[pm.From] {
+				// Just their okay from joining.
+				delete(s.joining, pm.From)
+			} else if s.game.GetPlayerByName(pm.From) != nil {
+				s.AddMessage(Message{
+					content: fmt.Sprintf("%s %s", pm.From, data.GiveMeString(lang.MessageWantToRestart)),
+				})
+			}
+		case net.PeerJoinedMessage:
+			s.AddPeer(pm.From)
+		case world.RequestDenied:
+			// Only bother the player about their tool use, as moves and shots get turned down now and then just from lag.
+			if msg.Request == (world.UseToolRequest{}).Type() {
+				data.SFX.Play("denied.ogg")
 				s.AddMessage(Message{
-					content: fmt.Sprintf("%s %s", s.game.net.OtherName, data.GiveMeString(lang.MessageWantToRestart)),
+					content: data.GiveMeString(msg.LangCode()),
 				})
 			}
 		case world.StartModeRequest:
-			s.game.players[1].ReadyForWave = true
-			if !s.world.ArePlayersReady() {
+			// Peers only get to ready themselves. The host relays everyone else's with their name filled in, and as clients only hear from the host, it can be trusted.
+			name := pm.From
+			if !s.game.net.Hosting() && msg.Player != "" {
+				name = msg.Player
+			}
+			if s.game.net.Hosting() && s.game.net.Spectating(pm.From) {
+				break
+			}
+			pl := s.game.GetPlayerByName(name)
+			if pl == nil || pl.Spectator {
+				break
+			}
+			s.world.QueueInput(pl, msg)
+			if s.game.net.Hosting() {
+				msg.Player = name
+				s.world.RelayReliable(pm.From, msg)
+			}
+			if !s.game.players[0].ReadyForWave {
 				s.AddMessage(Message{
-					content: fmt.Sprintf("%s %s", s.game.net.OtherName, data.GiveMeString(lang.MessageWantToStart)),
+					content: fmt.Sprintf("%s %s", name, data.GiveMeString(lang.MessageWantToStart)),
 				})
 			}
 		default:
 			// Send unhandled messages to the world.
-			s.world.ProcessNetMessage(msg)
+			s.world.ProcessNetMessage(pm.From, msg)
 		}
 	}
 
@@ -223,7 +334,7 @@ func (s *PlayState) Update() error {
 			if s.game.net.Active() {
 				s.game.net.Send(action)
 			}
-			p.Entity.SetAction(action)
+			s.world.QueueInput(p, action)
 		}
 	}
 
@@ -325,21 +436,16 @@ func (s *PlayState) Draw(screen *ebiten.Image) {
 	mx = bounds.Dx() + offset
 
 	// Draw players and points. (don't judge me)
-	for i, pl := range s.game.players {
+	i := 0
+	for _, pl := range s.game.players {
+		if pl.Spectator {
+			continue
+		}
 		op := &ebiten.DrawImageOptions{}
 		op.GeoM.Translate(float64(world.ScreenWidth)-12, float64(i)*32)
 
 		// Draw our player image and name from right to left.
-		imgs := data.Player2Init.Images
-		if !s.game.net.Active() || s.game.net.Hosting() {
-			if i == 0 {
-				imgs = data.PlayerInit.Images
-			}
-		} else {
-			if i == 1 {
-				imgs = data.PlayerInit.Images
-			}
-		}
+		imgs := world.PlayerConfig(pl.ID).Images
 		op.GeoM.Translate(-float64(imgs[0].Bounds().Dx()/2), float64(imgs[0].Bounds().Dy()))
 		s.viewbuffer.DrawImage(imgs[0], op)
 		op.GeoM.Translate(-float64(imgs[0].Bounds().Dx()), 0)
@@ -369,6 +475,7 @@ func (s *PlayState) Draw(screen *ebiten.Image) {
 		bounds = text.BoundString(data.NormalFace, t)
 		op.GeoM.Translate(-float64(bounds.Dx()), 0)
 		text.Draw(s.viewbuffer, t, data.NormalFace, int(op.GeoM.Element(0, 2)), int(op.GeoM.Element(1, 2))+8, color.White)
+		i++
 	}
 
 	// Draw our clickables
@@ -379,8 +486,10 @@ func (s *PlayState) Draw(screen *ebiten.Image) {
 		}
 	}
 
-	// Draw our player's belt!
-	s.game.players[0].Toolbelt.Draw(s.viewbuffer)
+	// Draw our player's belt! Spectators have no use for one.
+	if !s.game.players[0].Spectator {
+		s.game.players[0].Toolbelt.Draw(s.viewbuffer)
+	}
 
 	// Actually draw our buffers to the screen!
 
@@ -443,6 +552,70 @@ func (s *PlayState) Draw(screen *ebiten.Image) {
 	}
 }
 
+// AddPeer adds a player for a peer that joined after we started the level, then brings them over. Only the host does this.
+func (s *PlayState) AddPeer(name string) {
+	if !s.game.net.Hosting() {
+		return
+	}
+	if s.game.net.Spectating(name) {
+		s.AddSpectator(name)
+		return
+	}
+	if s.game.GetPlayerByName(name) != nil {
+		return
+	}
+	// Next ID after everyone already here.
+	id := 0
+	for _, pl := range s.game.players {
+		if pl.ID >= id {
+			id = pl.ID + 1
+		}
+	}
+	pl := world.NewPlayer()
+	pl.Name = name
+	pl.ID = id
+	s.game.AddPlayer(pl)
+	s.world.AddPlayerEntity(pl)
+	if s.world.Recording != nil {
+		s.world.Recording.Players = append(s.world.Recording.Players, name)
+	}
+
+	// Send 'em to our level. They'll ask for a snapshot once they're in.
+	s.joining[name] = true
+	s.game.net.SendReliableTo(name, net.TravelMessage{
+		Destination: s.levelDataName,
+	})
+	// Everyone else needs to know about them.
+	s.world.SendPlayerPoints()
+	s.world.RelayOrdered(name, s.world.Snapshot())
+
+	s.AddMessage(Message{
+		content: fmt.Sprintf("%s %s", name, data.GiveMeString(lang.MessageJoined)),
+	})
+}
+
+// AddSpectator brings a spectator over to our level. If we made them a player before we knew they were only watching, that gets undone.
+func (s *PlayState) AddSpectator(name string) {
+	if pl := s.game.GetPlayerByName(name); pl != nil {
+		s.world.RemovePlayerEntity(pl)
+		s.game.RemovePlayer(pl)
+		if s.world.Recording != nil {
+			for i, n := range s.world.Recording.Players {
+				if n == name {
+					s.world.Recording.Players = append(s.world.Recording.Players[:i], s.world.Recording.Players[i+1:]...)
+					break
+				}
+			}
+		}
+		s.world.RelayOrdered(name, s.world.Snapshot())
+		return
+	}
+	s.joining[name] = true
+	s.game.net.SendReliableTo(name, net.TravelMessage{
+		Destination: s.levelDataName,
+	})
+}
+
 func (s *PlayState) AddMessage(m Message) {
 	if m.deathtime <= 0 || m.deathtime >= 1000 {
 		m.deathtime = 300
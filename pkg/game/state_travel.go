@@ -17,6 +17,8 @@ type TravelState struct {
 	loadedLevel data.Level
 	ready       bool
 	fromLive    bool
+	invited     map[string]bool // Peers we've sent the travel to, if hosting.
+	traveled    map[string]bool // Peers that have sent their travel okay, if hosting.
 	//
 	magnetImage *ebiten.Image
 	magnetSpin  float64
@@ -36,9 +38,15 @@ func (s *TravelState) Init() (err error) {
 	if s.game.net.Active() {
 		// If we're hosting, send the required travel to other client.
 		if s.game.net.Hosting() {
+			s.invited = make(map[string]bool)
+			s.traveled = make(map[string]bool)
 			s.game.net.SendReliable(net.TravelMessage{
 				Destination: s.targetLevel,
 			})
+			s.game.net.SetRoomMap(s.targetLevel)
+			for _, name := range s.game.net.Peers() {
+				s.invited[name] = true
+			}
 			if err := s.LoadLevel(); err != nil {
 				return err
 			}
@@ -105,12 +113,26 @@ func (s *TravelState) Update() error {
 				break
 			}
 		}
-		// If we're connected and hosting, wait for the okay from the client.
+		// If we're connected and hosting, wait for the okay from every client.
 	} else if s.game.net.Connected() {
-		for _, msg := range s.game.net.Messages() {
-			switch msg.(type) {
+		for _, msg := range s.game.net.PeerMessages() {
+			switch msg.Message.(type) {
+			case net.PeerJoinedMessage:
+				// Someone showed up mid-travel, bring 'em along.
+				if !s.invited[msg.From] {
+					s.invited[msg.From] = true
+					s.game.net.SendReliableTo(msg.From, net.TravelMessage{
+						Destination: s.targetLevel,
+					})
+				}
 			case net.TravelMessage:
-				s.ready = true
+				s.traveled[msg.From] = true
+			}
+		}
+		s.ready = true
+		for _, name := range s.game.net.Peers() {
+			if !s.traveled[name] {
+				s.ready = false
 			}
 		}
 	} else {
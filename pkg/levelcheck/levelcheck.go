@@ -0,0 +1,215 @@
+// Package levelcheck finds problems with levels that would otherwise only show up when playing them.
+package levelcheck
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/goro/pathing"
+)
+
+// Check checks the named level in the assets, returning every problem with it. Unknown keys and cells and repeated keys are problems here, even though the game loads levels past them. Only a spawner without waves, which some levels want, is a data.ConfigWarning. Entity configurations need to be loaded first, so the enemies in its waves can be found.
+func Check(name string) []error {
+	file := path.Join("levels", name+".txt")
+	b, err := data.ReadFile(file)
+	if err != nil {
+		return []error{err}
+	}
+	return check(name, file, b)
+}
+
+// CheckBytes checks a level's contents, using file to say where problems are.
+func CheckBytes(file string, b []byte) []error {
+	return check(strings.TrimSuffix(path.Base(file), ".txt"), file, b)
+}
+
+// CheckAll checks every level in the assets.
+func CheckAll() (errs []error) {
+	entries, err := data.ReadDir("levels")
+	if err != nil {
+		return []error{err}
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".txt") {
+			continue
+		}
+		errs = append(errs, Check(strings.TrimSuffix(entry.Name(), ".txt"))...)
+	}
+	return errs
+}
+
+func check(name, file string, b []byte) (errs []error) {
+	var level data.LevelConfig
+	if err := level.LoadFromBytes(file, b); err != nil {
+		if configErrs, ok := err.(data.ConfigErrors); ok {
+			for _, e := range configErrs {
+				errs = append(errs, e)
+			}
+		} else {
+			errs = append(errs, err)
+		}
+	}
+	// The game gets by without them, but they're mistakes all the same.
+	for _, w := range level.Warnings {
+		errs = append(errs, w.ConfigError)
+	}
+	lines := findLines(b)
+	problem := func(line int, format string, a ...interface{}) {
+		errs = append(errs, data.ConfigError{File: file, Line: line, Msg: fmt.Sprintf(format, a...)})
+	}
+	warn := func(line int, format string, a ...interface{}) {
+		errs = append(errs, data.ConfigWarning{ConfigError: data.ConfigError{File: file, Line: line, Msg: fmt.Sprintf(format, a...)}})
+	}
+
+	// Find everything in the level the same way the world does, reading from top-left to bottom-right.
+	var spawners [][2]int
+	var cores [][2]int
+	players := 0
+	for y, r := range level.Cells {
+		for x, c := range r {
+			switch c.Kind {
+			case data.NorthSpawnCell, data.SouthSpawnCell:
+				spawners = append(spawners, [2]int{x, y})
+			case data.CoreCell:
+				cores = append(cores, [2]int{x, y})
+			case data.PlayerCell:
+				players++
+			}
+		}
+	}
+	if players == 0 {
+		problem(0, "no player start (@)")
+	}
+	if len(cores) == 0 {
+		problem(0, "no core (C)")
+	}
+
+	// Each spawner takes the W line in the same position. Spawners without one never spawn anything, which some levels want.
+	if len(level.Waves) > len(spawners) {
+		for i := len(spawners); i < len(level.Waves); i++ {
+			problem(lines.waves[i], "W line %d has no spawner to go with it, there are only %d", i+1, len(spawners))
+		}
+	} else {
+		for i := len(level.Waves); i < len(spawners); i++ {
+			warn(lines.row(spawners[i][1]), "spawner in column %d has no W line, so it won't spawn anything", spawners[i][0]+1)
+		}
+	}
+	for i, wave := range level.Waves {
+		for ; wave != nil; wave = wave.Next {
+			for sl := wave.Spawns; sl != nil; sl = sl.Next {
+				for _, kind := range sl.Kinds {
+					if _, ok := data.EnemyConfigs[kind]; !ok {
+						problem(lines.waves[i], "W: no enemy named %q", kind)
+					}
+				}
+			}
+		}
+	}
+
+	// Enemies head for the last core, so every spawner needs a way to it.
+	if len(cores) > 0 {
+		core := cores[len(cores)-1]
+		for _, s := range spawners {
+			if !canPath(level, s, core) {
+				problem(lines.row(s[1]), "spawner in column %d has no path to the core", s[0]+1)
+			}
+		}
+	}
+
+	if level.Next != "" {
+		errs = append(errs, checkNext(name, level.Next, file, lines.next)...)
+	}
+	return errs
+}
+
+// canPath returns if an enemy could walk from one cell to another, following the same rules as the world's pathing when nothing has been built.
+func canPath(level data.LevelConfig, from, to [2]int) bool {
+	if from == to {
+		return true
+	}
+	path := pathing.NewPathFromFunc(level.Width, level.Height, func(x, y int) uint32 {
+		// Short rows are filled out with nothing.
+		if x >= len(level.Cells[y]) {
+			return pathing.MaximumCost
+		}
+		if !level.Cells[y][x].Pathable() {
+			return pathing.MaximumCost
+		}
+		return 0
+	}, pathing.AlgorithmAStar)
+	for _, s := range path.Compute(from[0], from[1], to[0], to[1]) {
+		if s.X() == to[0] && s.Y() == to[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNext makes sure the level's next level exists, and that following the chain of next levels comes to an end.
+func checkNext(name, next, file string, line int) (errs []error) {
+	problem := func(format string, a ...interface{}) {
+		errs = append(errs, data.ConfigError{File: file, Line: line, Msg: fmt.Sprintf(format, a...)})
+	}
+	if _, err := data.ReadFile(path.Join("levels", next+".txt")); err != nil {
+		problem("N: no level named %q", next)
+		return
+	}
+
+	chain := []string{name}
+	visited := map[string]bool{name: true}
+	for next != "" {
+		chain = append(chain, next)
+		if visited[next] {
+			problem("N: the next levels go around in a circle: %s", strings.Join(chain, " -> "))
+			return
+		}
+		visited[next] = true
+		level, err := data.NewLevel(next)
+		if err != nil {
+			// That level's problems are its own to report.
+			return
+		}
+		next = level.Next
+	}
+	return
+}
+
+// levelLines are the line numbers of the parts of a level file, for pointing at problems.
+type levelLines struct {
+	waves []int
+	next  int
+	cells int // The line of the first row of cells.
+}
+
+func (l levelLines) row(y int) int {
+	return l.cells + y
+}
+
+func findLines(b []byte) (l levelLines) {
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	line := 0
+	for scanner.Scan() {
+		line++
+		t := scanner.Text()
+		if len(t) == 0 {
+			l.cells = line + 1
+			break
+		}
+		if len(t) > 1 && t[1] != ' ' && t[1] != '\t' {
+			continue
+		}
+		switch t[0] {
+		case 'W':
+			l.waves = append(l.waves, line)
+		case 'N':
+			if l.next == 0 {
+				l.next = line
+			}
+		}
+	}
+	return l
+}
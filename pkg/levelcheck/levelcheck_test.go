@@ -0,0 +1,154 @@
+package levelcheck
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kettek/ebijam22/pkg/data"
+)
+
+func TestMain(m *testing.M) {
+	// Same as the game's.
+	data.CellWidth = 16
+	data.CellHeight = 11
+
+	if err := data.LoadConfigurations(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		in   string
+		want []string
+	}{
+		{
+			name: "fine",
+			in:   "T Test\nW 5 runner;2@10 walker&flier\nN 001\n\n#####\n#N.C#\n#@..#\n#####\n",
+		},
+		{
+			name: "empty lines",
+			in:   "T Test\nW 5 runner\n\n#####\n#N.C#\n\n#@..#\n#####\n",
+		},
+		{
+			name: "unknown keys",
+			in:   "T Test\nQ 1\nWave 5 runner\nW 5 runner\n\n#N@C#\n",
+			want: []string{
+				`levels/test.txt:2: unknown key 'Q'`,
+				`levels/test.txt:3: unknown key "Wave"`,
+			},
+		},
+		{
+			name: "unknown cells",
+			in:   "T Test\nW 5 runner\n\n#N@xC#\n",
+			want: []string{
+				`levels/test.txt:4: unknown cell 'x' in column 4, it will be open ground`,
+			},
+		},
+		{
+			name: "duplicate keys",
+			in:   "T Test\nW 5 runner\nT Again\n\n#N@C#\n",
+			want: []string{
+				`levels/test.txt:3: duplicate key 'T', replacing the one on line 1`,
+			},
+		},
+		{
+			name: "bad wave",
+			in:   "T Test\nW 5 runner,3\n\n#N@C#\n",
+			want: []string{
+				`levels/test.txt:2: W: wave 1, spawn 2: "3" has no enemies`,
+			},
+		},
+		{
+			name: "more waves than spawners",
+			in:   "T Test\nW 5 runner\nW 5 walker\nW 5 flier\n\n#N@C#\n",
+			want: []string{
+				`levels/test.txt:3: W line 2 has no spawner to go with it, there are only 1`,
+				`levels/test.txt:4: W line 3 has no spawner to go with it, there are only 1`,
+			},
+		},
+		{
+			name: "fewer waves than spawners",
+			in:   "T Test\nW 5 runner\n\n#N@C#\n#S..#\n",
+			want: []string{
+				`levels/test.txt:5: warning: spawner in column 2 has no W line, so it won't spawn anything`,
+			},
+		},
+		{
+			name: "unknown enemy",
+			in:   "T Test\nW 5 runner;3 walker&goblin\n\n#N@C#\n",
+			want: []string{
+				`levels/test.txt:2: W: no enemy named "goblin"`,
+			},
+		},
+		{
+			name: "no path to the core",
+			in:   "T Test\nW 5 runner\nW 5 runner\n\n#N#C#\n#@#S#\n",
+			want: []string{
+				`levels/test.txt:5: spawner in column 2 has no path to the core`,
+			},
+		},
+		{
+			name: "no player or core",
+			in:   "T Test\n\n#...#\n",
+			want: []string{
+				`levels/test.txt: no player start (@)`,
+				`levels/test.txt: no core (C)`,
+			},
+		},
+		{
+			name: "missing next level",
+			in:   "T Test\nN nowhere\n\n#@C#\n",
+			want: []string{
+				`levels/test.txt:2: N: no level named "nowhere"`,
+			},
+		},
+		{
+			name: "next levels in a circle",
+			file: "levels/005.txt",
+			in:   "T Test\nN 001\n\n#@C#\n",
+			want: []string{
+				`levels/005.txt:2: N: the next levels go around in a circle: 005 -> 001 -> 002 -> 003 -> 004 -> 005`,
+			},
+		},
+		{
+			name: "next level is itself",
+			file: "levels/005.txt",
+			in:   "T Test\nN 005\n\n#@C#\n",
+			want: []string{
+				`levels/005.txt:2: N: the next levels go around in a circle: 005 -> 005`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := tt.file
+			if file == "" {
+				file = "levels/test.txt"
+			}
+			var got []string
+			for _, err := range CheckBytes(file, []byte(tt.in)) {
+				got = append(got, err.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+// TestCheckAll makes sure the bundled levels have no problems.
+func TestCheckAll(t *testing.T) {
+	for _, err := range CheckAll() {
+		if _, ok := err.(data.ConfigWarning); !ok {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package magservice
+
+import "time"
+
+// limiter is a token bucket per IP, so one noisy client can't drown out everyone else.
+type limiter struct {
+	rate    float64 // Tokens per second.
+	burst   float64
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(rate, burst float64) *limiter {
+	return &limiter{
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// allow returns if the IP can send another packet, taking a token if so.
+func (l *limiter) allow(ip string, t time.Time) bool {
+	b, ok := l.buckets[ip]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: t}
+		l.buckets[ip] = b
+	}
+	b.tokens += t.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = t
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// cleanup forgets any IPs whose buckets have filled back up, as they're no different from new ones.
+func (l *limiter) cleanup(t time.Time) {
+	for ip, b := range l.buckets {
+		if b.tokens+t.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, ip)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package magservice
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	l := newLimiter(2, 3)
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if !l.allow("1.2.3.4", start) {
+			t.Fatalf("packet %d of the burst was limited", i)
+		}
+	}
+	if l.allow("1.2.3.4", start) {
+		t.Error("packet past the burst was allowed")
+	}
+	if !l.allow("5.6.7.8", start) {
+		t.Error("another IP was limited")
+	}
+
+	// Half a second at 2 a second is one more packet.
+	if !l.allow("1.2.3.4", start.Add(500*time.Millisecond)) {
+		t.Error("packet after refilling was limited")
+	}
+	if l.allow("1.2.3.4", start.Add(500*time.Millisecond)) {
+		t.Error("packet past the refill was allowed")
+	}
+
+	// Buckets never fill past the burst.
+	later := start.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if !l.allow("1.2.3.4", later) {
+			t.Fatalf("packet %d of the later burst was limited", i)
+		}
+	}
+	if l.allow("1.2.3.4", later) {
+		t.Error("packet past the later burst was allowed")
+	}
+}
+
+func TestLimiterCleanup(t *testing.T) {
+	l := newLimiter(1, 2)
+	start := time.Now()
+
+	l.allow("1.2.3.4", start)
+	l.allow("1.2.3.4", start)
+	l.allow("5.6.7.8", start)
+
+	l.cleanup(start.Add(time.Second))
+	if _, ok := l.buckets["5.6.7.8"]; ok {
+		t.Error("full bucket wasn't cleaned up")
+	}
+	if _, ok := l.buckets["1.2.3.4"]; !ok {
+		t.Error("bucket still filling up was cleaned up")
+	}
+
+	l.cleanup(start.Add(2 * time.Second))
+	if len(l.buckets) != 0 {
+		t.Errorf("got %d buckets, want 0", len(l.buckets))
+	}
+}
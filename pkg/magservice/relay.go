@@ -0,0 +1,83 @@
+package magservice
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"time"
+
+	enet "github.com/kettek/ebijam22/pkg/net"
+)
+
+// relay is a relay session between two clients we introduced, for when they can't reach each other directly. Relayed packets are forwarded as they are, sealed or not, so we never know what's in them.
+type relay struct {
+	a, b     AddressKey
+	lastUsed time.Time
+	packets  int
+}
+
+// newRelay starts a relay session between the two clients, returning its session.
+func (s *Server) newRelay(a, b AddressKey) uint64 {
+	var buf [8]byte
+	for {
+		if _, err := rand.Read(buf[:]); err != nil {
+			s.logf("relay session failed", "error", err)
+			return 0
+		}
+		session := binary.BigEndian.Uint64(buf[:])
+		if _, ok := s.relays[session]; session != 0 && !ok {
+			s.relays[session] = &relay{
+				a:        a,
+				b:        b,
+				lastUsed: time.Now(),
+			}
+			s.stats.RelaysOpened++
+			return session
+		}
+	}
+}
+
+// forward passes a relayed packet on to the other end of its session. Only the two clients in a session can use it.
+func (s *Server) forward(b []byte, from *net.UDPAddr) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stats.Packets++
+
+	if !s.relayLimiter.allow(from.IP.String(), time.Now()) {
+		s.stats.Limited++
+		return
+	}
+	session, ok := enet.RelaySession(b)
+	if !ok {
+		s.stats.Malformed++
+		return
+	}
+	r, ok := s.relays[session]
+	if !ok {
+		s.stats.Malformed++
+		return
+	}
+	var to AddressKey
+	switch IPToAddressKey(from) {
+	case r.a:
+		to = r.b
+	case r.b:
+		to = r.a
+	default:
+		s.stats.Malformed++
+		s.logf("relay from stranger", "address", from, "a", r.a, "b", r.b)
+		return
+	}
+	toAddress := AddressKeyToIP(to)
+	if toAddress == nil {
+		return
+	}
+	if r.packets == 0 {
+		s.logf("relaying", "from", from, "to", to)
+	}
+	r.lastUsed = time.Now()
+	r.packets++
+	s.stats.Relayed++
+	s.stats.RelayedBytes += len(b)
+	s.conn.WriteTo(b, toAddress)
+}
@@ -0,0 +1,116 @@
+package magservice
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"testing"
+	"time"
+
+	enet "github.com/kettek/ebijam22/pkg/net"
+)
+
+// relayed returns a relayed datagram for the session.
+func relayed(session uint64, payload string) []byte {
+	b := make([]byte, enet.RelayHeaderSize, enet.RelayHeaderSize+len(payload))
+	b[0] = enet.RelayMark
+	binary.BigEndian.PutUint64(b[1:], session)
+	return append(b, payload...)
+}
+
+// matchRelayed introduces two clients through a relaying server, returning their relay session.
+func matchRelayed(t *testing.T, a, b *testClient) uint64 {
+	t.Helper()
+	a.send(enet.RegisterMessage, "a")
+	a.expect(enet.HandshakerMessage)
+	b.send(enet.RegisterMessage, "b")
+	b.expect(enet.HandshakerMessage)
+	b.send(enet.AwaitMessage, "a")
+
+	fieldsA := a.expect(enet.ArrivedMessage)
+	fieldsB := b.expect(enet.ArrivedMessage)
+	if len(fieldsA) != 2 || len(fieldsB) != 2 || fieldsA[1] != fieldsB[1] {
+		t.Fatalf("got arrivals %q and %q, want the same session in both", fieldsA, fieldsB)
+	}
+	session, err := strconv.ParseUint(fieldsA[1], 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+func TestRelayForwards(t *testing.T) {
+	s := startServer(t, Config{Relay: true})
+	a := newTestClient(t, s)
+	b := newTestClient(t, s)
+	session := matchRelayed(t, a, b)
+
+	msg := relayed(session, "from a")
+	a.write(msg)
+	if got := b.read(2 * time.Second); !bytes.Equal(got, msg) {
+		t.Errorf("b got %q, want %q", got, msg)
+	}
+	msg = relayed(session, "from b")
+	b.write(msg)
+	if got := a.read(2 * time.Second); !bytes.Equal(got, msg) {
+		t.Errorf("a got %q, want %q", got, msg)
+	}
+	if st := s.Stats(); st.Relayed != 2 || st.Relays != 1 || st.RelaysOpened != 1 {
+		t.Errorf("got %d relayed, %d relays, and %d opened, want 2, 1, and 1", st.Relayed, st.Relays, st.RelaysOpened)
+	}
+}
+
+func TestRelayRejectsStrangers(t *testing.T) {
+	s := startServer(t, Config{Relay: true})
+	a := newTestClient(t, s)
+	b := newTestClient(t, s)
+	stranger := newTestClient(t, s)
+	session := matchRelayed(t, a, b)
+
+	malformed := s.Stats().Malformed
+	stranger.write(relayed(session, "from a stranger"))
+	a.write(relayed(session+1, "to nowhere"))
+	waitFor(t, s, "the relays to be turned down", func(st Stats) bool {
+		return st.Malformed == malformed+2
+	})
+	a.expectNothing()
+	b.expectNothing()
+	if st := s.Stats(); st.Relayed != 0 {
+		t.Errorf("got %d relayed, want 0", st.Relayed)
+	}
+}
+
+func TestRelayDisabled(t *testing.T) {
+	s := startServer(t, Config{})
+	a := newTestClient(t, s)
+	b := newTestClient(t, s)
+
+	a.send(enet.RegisterMessage, "a")
+	a.expect(enet.HandshakerMessage)
+	b.send(enet.RegisterMessage, "b")
+	b.expect(enet.HandshakerMessage)
+	b.send(enet.AwaitMessage, "a")
+	if fields := a.expect(enet.ArrivedMessage); len(fields) != 1 {
+		t.Errorf("got arrival %q, want no session", fields)
+	}
+	if st := s.Stats(); st.Relays != 0 {
+		t.Errorf("got %d relays, want 0", st.Relays)
+	}
+}
+
+func TestRelayExpires(t *testing.T) {
+	s := startServer(t, Config{
+		Relay:           true,
+		RelayTimeout:    50 * time.Millisecond,
+		JanitorInterval: 10 * time.Millisecond,
+	})
+	a := newTestClient(t, s)
+	b := newTestClient(t, s)
+	session := matchRelayed(t, a, b)
+
+	waitFor(t, s, "the relay to expire", func(st Stats) bool {
+		return st.Relays == 0
+	})
+	a.write(relayed(session, "too late"))
+	b.expectNothing()
+}
@@ -0,0 +1,474 @@
+/*
+Package magservice is a _very_ simple handshaker service for use with basic UDP punching. Clients register a name, then either wait for someone to await them, await someone else, or host and join rooms.
+*/
+package magservice
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	enet "github.com/kettek/ebijam22/pkg/net"
+)
+
+// AddressKey is a key that represents an ip address and port.
+type AddressKey string
+
+type MessageBox struct {
+	name           string              // String for this messagebox
+	wavingAt       map[string]struct{} // waving at other names
+	connectionTime time.Time
+	room           *enet.Room // The room they're hosting, if any.
+}
+
+func IPToAddressKey(addr *net.UDPAddr) (a AddressKey) {
+	return AddressKey(addr.String())
+}
+
+func AddressKeyToIP(a AddressKey) *net.UDPAddr {
+	addr, _ := net.ResolveUDPAddr("udp", string(a))
+	return addr
+}
+
+// maxRoomsSize is how big a room list can get before we stop adding rooms to it, so it fits in one datagram.
+const maxRoomsSize = 1200
+
+// maxPacketSize is the biggest packet we'll bother reading. Relayed packets are the biggest by far, and clients keep those under enet.MaxPacketSize.
+const maxPacketSize = 2048
+
+// Config is how a Server should run. Zero values get the defaults.
+type Config struct {
+	Address         string        // UDP address to listen on.
+	AdminAddress    string        // TCP address to serve stats on, none if empty. Keep it local!
+	ClientTimeout   time.Duration // How long a client stays registered without re-registering.
+	JanitorInterval time.Duration // How often stale clients are cleaned up.
+	RateLimit       float64       // Packets per second allowed from each IP.
+	RateBurst       float64       // How many packets an IP can send in a burst.
+	Relay           bool          // Whether to relay traffic for clients that can't reach each other.
+	RelayTimeout    time.Duration // How long a relay session lasts without being used.
+	RelayRateLimit  float64       // Relayed packets per second allowed from each IP. Games send a lot more than handshakes do.
+	RelayBurst      float64
+	Logger          *log.Logger
+}
+
+func (c *Config) defaults() {
+	if c.ClientTimeout == 0 {
+		c.ClientTimeout = 30 * time.Second
+	}
+	if c.JanitorInterval == 0 {
+		c.JanitorInterval = 5 * time.Second
+	}
+	if c.RateLimit == 0 {
+		c.RateLimit = 20
+	}
+	if c.RateBurst == 0 {
+		c.RateBurst = 40
+	}
+	if c.RelayTimeout == 0 {
+		c.RelayTimeout = 60 * time.Second
+	}
+	if c.RelayRateLimit == 0 {
+		c.RelayRateLimit = 300
+	}
+	if c.RelayBurst == 0 {
+		c.RelayBurst = 600
+	}
+	if c.Logger == nil {
+		c.Logger = log.New(os.Stdout, "", log.LstdFlags)
+	}
+}
+
+// Server is the handshaker service. It is safe to use from multiple goroutines.
+type Server struct {
+	config       Config
+	conn         *net.UDPConn
+	admin        net.Listener
+	limiter      *limiter
+	relayLimiter *limiter
+
+	lock       sync.Mutex // Guards everything below.
+	clients    map[AddressKey]*MessageBox
+	lastRoomID int // The last ID given to a room. IDs are never reused, so a client can't join the wrong room by accident.
+	relays     map[uint64]*relay
+	stats      Stats
+	closed     bool
+	done       chan struct{}
+}
+
+// NewServer returns a server with the given config. It won't do anything until Listen is called.
+func NewServer(config Config) *Server {
+	config.defaults()
+	return &Server{
+		config:       config,
+		limiter:      newLimiter(config.RateLimit, config.RateBurst),
+		relayLimiter: newLimiter(config.RelayRateLimit, config.RelayBurst),
+		clients:      make(map[AddressKey]*MessageBox),
+		relays:       make(map[uint64]*relay),
+		done:         make(chan struct{}),
+	}
+}
+
+// Listen binds the server's UDP address, along with its admin address if it has one.
+func (s *Server) Listen() error {
+	addr, err := net.ResolveUDPAddr("udp", s.config.Address)
+	if err != nil {
+		return err
+	}
+	s.conn, err = net.ListenUDP("udp", addr)
+	if err != nil {
+		return err
+	}
+	if s.config.AdminAddress != "" {
+		s.admin, err = net.Listen("tcp", s.config.AdminAddress)
+		if err != nil {
+			s.conn.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+// Addr returns the UDP address the server is listening on.
+func (s *Server) Addr() *net.UDPAddr {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// AdminAddr returns the address stats are served on, if any.
+func (s *Server) AdminAddr() net.Addr {
+	if s.admin == nil {
+		return nil
+	}
+	return s.admin.Addr()
+}
+
+// ListenAndServe is Listen followed by Serve.
+func (s *Server) ListenAndServe() error {
+	if err := s.Listen(); err != nil {
+		return err
+	}
+	return s.Serve()
+}
+
+// Serve handles packets until the server is closed, which returns nil.
+func (s *Server) Serve() error {
+	if s.conn == nil {
+		return errors.New("not listening")
+	}
+	s.logf("starting", "address", s.conn.LocalAddr())
+	go s.janitor()
+	if s.admin != nil {
+		s.logf("serving stats", "address", s.admin.Addr())
+		go s.serveAdmin()
+	}
+
+	// Begin the Eternal Listen (tm)
+	buffer := make([]byte, maxPacketSize)
+	for {
+		bytesRead, remoteAddr, err := s.conn.ReadFromUDP(buffer)
+		if err != nil {
+			if s.isClosed() {
+				return nil
+			}
+			// Most likely an ICMP error bubbling up from an earlier write, which is no reason to stop.
+			s.logf("read failed", "error", err)
+			s.lock.Lock()
+			s.stats.ReadErrors++
+			s.lock.Unlock()
+			continue
+		}
+		if bytesRead > 0 && buffer[0] == enet.RelayMark {
+			s.forward(buffer[:bytesRead], remoteAddr)
+			continue
+		}
+		s.handle(buffer[:bytesRead], remoteAddr)
+	}
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	s.lock.Unlock()
+
+	if s.admin != nil {
+		s.admin.Close()
+	}
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+func (s *Server) isClosed() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.closed
+}
+
+// janitor removes any clients that have been here for too long without re-registering, along with relay sessions nobody is using.
+func (s *Server) janitor() {
+	ticker := time.NewTicker(s.config.JanitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case t := <-ticker.C:
+			s.cleanup(t)
+		}
+	}
+}
+
+func (s *Server) cleanup(t time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for k, v := range s.clients {
+		if t.Sub(v.connectionTime) > s.config.ClientTimeout {
+			delete(s.clients, k)
+			s.stats.Expired++
+			if v.room != nil {
+				s.logf("closed room", "address", k, "name", v.name, "room", v.room.ID)
+			} else {
+				s.logf("cleaned up", "address", k, "name", v.name)
+			}
+		}
+	}
+	for session, r := range s.relays {
+		if t.Sub(r.lastUsed) > s.config.RelayTimeout {
+			delete(s.relays, session)
+			s.logf("closed relay", "a", r.a, "b", r.b, "packets", r.packets)
+		}
+	}
+	s.limiter.cleanup(t)
+	s.relayLimiter.cleanup(t)
+}
+
+// handle handles a single packet.
+func (s *Server) handle(msg []byte, remoteAddr *net.UDPAddr) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stats.Packets++
+
+	if !s.limiter.allow(remoteAddr.IP.String(), time.Now()) {
+		s.stats.Limited++
+		return
+	}
+
+	clientKey := IPToAddressKey(remoteAddr)
+
+	a, fields, err := enet.ParseHandshake(msg)
+	if err != nil {
+		s.stats.Malformed++
+		s.logf("malformed packet", "address", clientKey, "size", len(msg))
+		return
+	}
+
+	// Send an immediate response to the client to make sure they know we're alive.
+	s.conn.WriteTo(enet.FormatHandshake(enet.HandshakerMessage), remoteAddr)
+
+	// Everything but listing rooms needs something to go on.
+	if a != enet.ListRoomsMessage && (len(fields) == 0 || fields[0] == "") {
+		s.stats.Malformed++
+		return
+	}
+
+	switch a {
+	case enet.RegisterMessage:
+		s.register(clientKey, fields[0])
+	case enet.AwaitMessage:
+		s.await(clientKey, fields[0])
+	case enet.RoomMessage:
+		s.openRoom(clientKey, fields[0])
+	case enet.ListRoomsMessage:
+		// Anyone can ask from someone else's address, so those we don't know get no more than they sent, lest we be used to flood them.
+		limit := len(msg)
+		if _, ok := s.clients[clientKey]; ok {
+			limit = maxRoomsSize
+		}
+		s.sendRooms(remoteAddr, limit)
+	case enet.JoinRoomMessage:
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			s.stats.Malformed++
+			return
+		}
+		s.joinRoom(clientKey, remoteAddr, id)
+	default:
+		s.stats.Malformed++
+		s.logf("unknown message", "address", clientKey, "type", a)
+	}
+}
+
+func (s *Server) register(clientKey AddressKey, name string) {
+	// Hosts keep re-registering, so hang on to their room.
+	var room *enet.Room
+	if mbox, ok := s.clients[clientKey]; ok {
+		room = mbox.room
+	} else {
+		s.logf("registered", "address", clientKey, "name", name)
+	}
+	s.clients[clientKey] = &MessageBox{
+		name:           name,
+		connectionTime: time.Now(),
+		wavingAt:       make(map[string]struct{}),
+		room:           room,
+	}
+	s.stats.Registrations++
+	// Check if any clients are waiting this target and send arrival msg.
+	for otherClientKey, mbox := range s.clients {
+		if _, ok := mbox.wavingAt[name]; ok {
+			delete(mbox.wavingAt, name)
+			s.match(otherClientKey, clientKey)
+		}
+	}
+}
+
+func (s *Server) await(clientKey AddressKey, name string) {
+	mbox, ok := s.clients[clientKey]
+	if !ok {
+		return
+	}
+	var matched = false
+	for otherClientKey, otherMbox := range s.clients {
+		if otherMbox.name == name && otherClientKey != clientKey {
+			s.match(otherClientKey, clientKey)
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		if _, ok := mbox.wavingAt[name]; !ok {
+			mbox.wavingAt[name] = struct{}{}
+			s.logf("awaiting arrival", "address", clientKey, "name", mbox.name, "target", name)
+		}
+	}
+}
+
+func (s *Server) openRoom(clientKey AddressKey, payload string) {
+	mbox, ok := s.clients[clientKey]
+	if !ok {
+		return
+	}
+	var room enet.Room
+	if err := json.Unmarshal([]byte(payload), &room); err != nil {
+		s.stats.Malformed++
+		s.logf("bad room", "address", clientKey, "error", err)
+		return
+	}
+	if len(room.Title) > enet.MaxRoomTitle {
+		room.Title = room.Title[:enet.MaxRoomTitle]
+	}
+	if len(room.Map) > enet.MaxRoomTitle {
+		room.Map = room.Map[:enet.MaxRoomTitle]
+	}
+	if mbox.room != nil {
+		room.ID = mbox.room.ID
+	} else {
+		s.lastRoomID++
+		room.ID = s.lastRoomID
+		s.stats.RoomsOpened++
+		s.logf("opened room", "address", clientKey, "name", mbox.name, "room", room.ID, "title", room.Title)
+	}
+	mbox.room = &room
+	mbox.connectionTime = time.Now()
+}
+
+func (s *Server) joinRoom(clientKey AddressKey, remoteAddr *net.UDPAddr, id int) {
+	if _, ok := s.clients[clientKey]; !ok {
+		return
+	}
+	for otherClientKey, otherMbox := range s.clients {
+		if otherMbox.room != nil && otherMbox.room.ID == id && otherClientKey != clientKey {
+			s.match(otherClientKey, clientKey)
+			return
+		}
+	}
+	s.logf("missing room", "address", clientKey, "room", id)
+	s.conn.WriteTo(enet.FormatHandshake(enet.MissingRoomMessage), remoteAddr)
+}
+
+// sendRooms sends as many rooms as fit in limit bytes, oldest first. An empty list is always sent, as it's no bigger than saying we're alive.
+func (s *Server) sendRooms(to *net.UDPAddr, limit int) {
+	if limit > maxRoomsSize {
+		limit = maxRoomsSize
+	}
+	var rooms []enet.Room
+	for _, mbox := range s.clients {
+		if mbox.room != nil {
+			rooms = append(rooms, *mbox.room)
+		}
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].ID < rooms[j].ID
+	})
+	list := func() []byte {
+		if len(rooms) == 0 {
+			return enet.FormatHandshake(enet.RoomsMessage, "[]")
+		}
+		b, _ := json.Marshal(rooms)
+		return enet.FormatHandshake(enet.RoomsMessage, string(b))
+	}
+	msg := list()
+	for len(rooms) > 0 && len(msg) > limit {
+		rooms = rooms[:len(rooms)-1]
+		msg = list()
+	}
+	s.conn.WriteTo(msg, to)
+}
+
+// match introduces the two clients to each other, giving them a relay session if we're relaying.
+func (s *Server) match(host, client AddressKey) {
+	// Room hosts stick around for whoever else wants to join.
+	if mbox, ok := s.clients[host]; ok && mbox.room == nil {
+		delete(s.clients, host)
+	}
+	if mbox, ok := s.clients[client]; ok && mbox.room == nil {
+		delete(s.clients, client)
+	}
+	var session uint64
+	if s.config.Relay {
+		session = s.newRelay(host, client)
+	}
+	s.sendArrival(host, client, session)
+	s.sendArrival(client, host, session)
+}
+
+func (s *Server) sendArrival(to, target AddressKey, session uint64) {
+	s.stats.Arrivals++
+	s.logf("sending arrival", "to", to, "target", target)
+	toAddress := AddressKeyToIP(to)
+	if toAddress == nil {
+		return
+	}
+	fields := []string{string(target)}
+	if session != 0 {
+		fields = append(fields, strconv.FormatUint(session, 10))
+	}
+	s.conn.WriteTo(enet.FormatHandshake(enet.ArrivedMessage, fields...), toAddress)
+}
+
+// logf logs an event along with key/value pairs, so it's easy to grep through.
+func (s *Server) logf(event string, kv ...interface{}) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "event=%q", event)
+	for i := 0; i+1 < len(kv); i += 2 {
+		fmt.Fprintf(&b, " %v=%q", kv[i], fmt.Sprint(kv[i+1]))
+	}
+	s.config.Logger.Println(b.String())
+}
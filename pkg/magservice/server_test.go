@@ -0,0 +1,358 @@
+package magservice
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	enet "github.com/kettek/ebijam22/pkg/net"
+)
+
+// startServer starts a server on a random loopback port, closing it when the test is done.
+func startServer(t *testing.T, config Config) *Server {
+	t.Helper()
+	config.Address = "127.0.0.1:0"
+	config.Logger = log.New(io.Discard, "", 0)
+	s := NewServer(config)
+	if err := s.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve()
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// testClient is a bare UDP socket talking to the server.
+type testClient struct {
+	t      *testing.T
+	conn   *net.UDPConn
+	server *net.UDPAddr
+}
+
+func newTestClient(t *testing.T, s *Server) *testClient {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &testClient{t: t, conn: conn, server: s.Addr()}
+}
+
+func (c *testClient) addr() string {
+	return c.conn.LocalAddr().String()
+}
+
+func (c *testClient) write(b []byte) {
+	c.t.Helper()
+	if _, err := c.conn.WriteToUDP(b, c.server); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *testClient) send(kind enet.HandshakeMessage, fields ...string) {
+	c.t.Helper()
+	c.write(enet.FormatHandshake(kind, fields...))
+}
+
+// read returns the next datagram, or nil if nothing arrives in time.
+func (c *testClient) read(timeout time.Duration) []byte {
+	b := make([]byte, maxPacketSize)
+	c.conn.SetReadDeadline(time.Now().Add(timeout))
+	n, _, err := c.conn.ReadFromUDP(b)
+	if err != nil {
+		return nil
+	}
+	return b[:n]
+}
+
+// expect waits for the given handshake message, skipping the server's acknowledgements.
+func (c *testClient) expect(kind enet.HandshakeMessage) []string {
+	c.t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		b := c.read(time.Until(deadline))
+		if b == nil {
+			break
+		}
+		a, fields, err := enet.ParseHandshake(b)
+		if err != nil {
+			c.t.Fatalf("unexpected packet %q", b)
+		}
+		if a == kind {
+			return fields
+		}
+		if a != enet.HandshakerMessage {
+			c.t.Fatalf("expected message %d, got %d %v", kind, a, fields)
+		}
+	}
+	c.t.Fatalf("timed out waiting for message %d", kind)
+	return nil
+}
+
+// expectNothing checks that nothing but acknowledgements arrive for a little while.
+func (c *testClient) expectNothing() {
+	c.t.Helper()
+	for {
+		b := c.read(100 * time.Millisecond)
+		if b == nil {
+			return
+		}
+		if a, _, err := enet.ParseHandshake(b); err != nil || a != enet.HandshakerMessage {
+			c.t.Fatalf("unexpected packet %q", b)
+		}
+	}
+}
+
+// waitFor waits for the server's stats to satisfy cond.
+func waitFor(t *testing.T, s *Server, what string, cond func(st Stats) bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond(s.Stats()) {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s, stats are %+v", what, s.Stats())
+}
+
+func TestAwaitRegistered(t *testing.T) {
+	s := startServer(t, Config{})
+	alice := newTestClient(t, s)
+	bob := newTestClient(t, s)
+
+	alice.send(enet.RegisterMessage, "alice")
+	alice.expect(enet.HandshakerMessage)
+	bob.send(enet.RegisterMessage, "bob")
+	bob.expect(enet.HandshakerMessage)
+	bob.send(enet.AwaitMessage, "alice")
+
+	if fields := alice.expect(enet.ArrivedMessage); fields[0] != bob.addr() {
+		t.Errorf("alice got arrival of %q, want %q", fields[0], bob.addr())
+	}
+	if fields := bob.expect(enet.ArrivedMessage); fields[0] != alice.addr() {
+		t.Errorf("bob got arrival of %q, want %q", fields[0], alice.addr())
+	}
+	if st := s.Stats(); st.Arrivals != 2 || st.Clients != 0 {
+		t.Errorf("got %d arrivals and %d clients, want 2 and 0", st.Arrivals, st.Clients)
+	}
+}
+
+func TestAwaitBeforeRegister(t *testing.T) {
+	s := startServer(t, Config{})
+	alice := newTestClient(t, s)
+	bob := newTestClient(t, s)
+
+	bob.send(enet.RegisterMessage, "bob")
+	bob.expect(enet.HandshakerMessage)
+	bob.send(enet.AwaitMessage, "alice")
+	bob.expect(enet.HandshakerMessage)
+	bob.expectNothing()
+
+	alice.send(enet.RegisterMessage, "alice")
+	if fields := alice.expect(enet.ArrivedMessage); fields[0] != bob.addr() {
+		t.Errorf("alice got arrival of %q, want %q", fields[0], bob.addr())
+	}
+	if fields := bob.expect(enet.ArrivedMessage); fields[0] != alice.addr() {
+		t.Errorf("bob got arrival of %q, want %q", fields[0], alice.addr())
+	}
+}
+
+func TestAwaitUnregistered(t *testing.T) {
+	s := startServer(t, Config{})
+	bob := newTestClient(t, s)
+
+	// Only registered clients get to wait for anyone.
+	bob.send(enet.AwaitMessage, "alice")
+	bob.expect(enet.HandshakerMessage)
+	bob.expectNothing()
+	if st := s.Stats(); st.Clients != 0 {
+		t.Errorf("got %d clients, want 0", st.Clients)
+	}
+}
+
+func TestRooms(t *testing.T) {
+	s := startServer(t, Config{})
+	host := newTestClient(t, s)
+	joiner := newTestClient(t, s)
+
+	host.send(enet.RegisterMessage, "host")
+	host.expect(enet.HandshakerMessage)
+	room, _ := json.Marshal(enet.Room{Title: strings.Repeat("t", enet.MaxRoomTitle+10), Map: "001", Players: 1})
+	host.send(enet.RoomMessage, string(room))
+	host.expect(enet.HandshakerMessage)
+	// Re-registering keeps the room.
+	host.send(enet.RegisterMessage, "host")
+	host.expect(enet.HandshakerMessage)
+
+	rooms, err := enet.ListRooms(s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms) != 1 {
+		t.Fatalf("got %d rooms, want 1", len(rooms))
+	}
+	if rooms[0].ID != 1 || rooms[0].Map != "001" || len(rooms[0].Title) != enet.MaxRoomTitle {
+		t.Errorf("got room %+v", rooms[0])
+	}
+
+	joiner.send(enet.RegisterMessage, "joiner")
+	joiner.expect(enet.HandshakerMessage)
+	joiner.send(enet.JoinRoomMessage, "2")
+	joiner.expect(enet.MissingRoomMessage)
+
+	joiner.send(enet.JoinRoomMessage, strconv.Itoa(rooms[0].ID))
+	if fields := joiner.expect(enet.ArrivedMessage); fields[0] != host.addr() {
+		t.Errorf("joiner got arrival of %q, want %q", fields[0], host.addr())
+	}
+	if fields := host.expect(enet.ArrivedMessage); fields[0] != joiner.addr() {
+		t.Errorf("host got arrival of %q, want %q", fields[0], joiner.addr())
+	}
+	// The host sticks around for more players.
+	if st := s.Stats(); st.Rooms != 1 || st.RoomsOpened != 1 || st.Clients != 1 {
+		t.Errorf("got %d rooms, %d opened, and %d clients, want 1, 1, and 1", st.Rooms, st.RoomsOpened, st.Clients)
+	}
+}
+
+func TestEmptyRooms(t *testing.T) {
+	s := startServer(t, Config{})
+	lister := newTestClient(t, s)
+
+	lister.send(enet.ListRoomsMessage)
+	if fields := lister.expect(enet.RoomsMessage); len(fields) != 1 || fields[0] != "[]" {
+		t.Errorf("got rooms %q, want []", fields)
+	}
+}
+
+func TestRoomsAmplification(t *testing.T) {
+	s := startServer(t, Config{})
+	for i := 0; i < 5; i++ {
+		host := newTestClient(t, s)
+		host.send(enet.RegisterMessage, "host"+strconv.Itoa(i))
+		host.expect(enet.HandshakerMessage)
+		room, _ := json.Marshal(enet.Room{Title: strings.Repeat("t", enet.MaxRoomTitle), Map: "001"})
+		host.send(enet.RoomMessage, string(room))
+		host.expect(enet.HandshakerMessage)
+	}
+	lister := newTestClient(t, s)
+	count := func(fields []string) int {
+		t.Helper()
+		var rooms []enet.Room
+		if err := json.Unmarshal([]byte(fields[0]), &rooms); err != nil {
+			t.Fatal(err)
+		}
+		return len(rooms)
+	}
+
+	// A small request from someone we don't know gets a small answer.
+	request := enet.FormatHandshake(enet.ListRoomsMessage, strings.Repeat("-", 300))
+	lister.write(request)
+	lister.expect(enet.HandshakerMessage)
+	b := lister.read(time.Second)
+	if len(b) > len(request) {
+		t.Errorf("got %d bytes of rooms for a %d byte request", len(b), len(request))
+	}
+	if a, fields, err := enet.ParseHandshake(b); err != nil || a != enet.RoomsMessage || count(fields) == 0 || count(fields) == 5 {
+		t.Errorf("got %q, want some of the rooms", b)
+	}
+	lister.send(enet.ListRoomsMessage)
+	if fields := lister.expect(enet.RoomsMessage); fields[0] != "[]" {
+		t.Errorf("got rooms %q for a bare request, want []", fields[0])
+	}
+
+	// A padded one gets them all.
+	if rooms, err := enet.ListRooms(s.Addr().String()); err != nil || len(rooms) != 5 {
+		t.Errorf("got %d rooms and %v, want 5", len(rooms), err)
+	}
+	// As does anyone registered.
+	lister.send(enet.RegisterMessage, "lister")
+	lister.expect(enet.HandshakerMessage)
+	lister.send(enet.ListRoomsMessage)
+	if fields := lister.expect(enet.RoomsMessage); count(fields) != 5 {
+		t.Errorf("got %d rooms when registered, want 5", count(fields))
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	s := startServer(t, Config{})
+	c := newTestClient(t, s)
+
+	packets := [][]byte{
+		[]byte("hello"),
+		[]byte(""),
+		enet.FormatHandshake(enet.RegisterMessage),
+		enet.FormatHandshake(enet.RegisterMessage, ""),
+		enet.FormatHandshake(enet.JoinRoomMessage, "abc"),
+		enet.FormatHandshake(99, "what"),
+		[]byte("0 %zz"),
+		[]byte(strings.Repeat("x", 4*maxPacketSize)),
+		{enet.RelayMark, 1, 2},
+		{enet.RelayMark, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+	}
+	for _, b := range packets {
+		c.write(b)
+	}
+	waitFor(t, s, "malformed packets", func(st Stats) bool {
+		return st.Packets == len(packets)
+	})
+	if st := s.Stats(); st.Malformed != len(packets) {
+		t.Errorf("got %d malformed packets, want %d", st.Malformed, len(packets))
+	}
+
+	// Registering a room with a bad payload needs a registration first.
+	c.send(enet.RegisterMessage, "c")
+	c.send(enet.RoomMessage, "{")
+	waitFor(t, s, "the bad room", func(st Stats) bool {
+		return st.Malformed == len(packets)+1
+	})
+	if st := s.Stats(); st.Rooms != 0 {
+		t.Errorf("got %d rooms, want 0", st.Rooms)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	s := startServer(t, Config{RateLimit: 0.001, RateBurst: 3})
+	c := newTestClient(t, s)
+
+	const sent = 10
+	for i := 0; i < sent; i++ {
+		c.send(enet.ListRoomsMessage)
+	}
+	waitFor(t, s, "all packets", func(st Stats) bool {
+		return st.Packets == sent
+	})
+	if st := s.Stats(); st.Limited != sent-3 {
+		t.Errorf("got %d limited packets, want %d", st.Limited, sent-3)
+	}
+	// Only the packets that got through are answered.
+	for i := 0; i < 3; i++ {
+		c.expect(enet.RoomsMessage)
+	}
+	c.expectNothing()
+}
+
+func TestJanitor(t *testing.T) {
+	s := startServer(t, Config{
+		ClientTimeout:   50 * time.Millisecond,
+		JanitorInterval: 10 * time.Millisecond,
+	})
+	c := newTestClient(t, s)
+
+	c.send(enet.RegisterMessage, "c")
+	c.expect(enet.HandshakerMessage)
+	if st := s.Stats(); st.Clients != 1 {
+		t.Fatalf("got %d clients, want 1", st.Clients)
+	}
+	waitFor(t, s, "the client to expire", func(st Stats) bool {
+		return st.Clients == 0 && st.Expired == 1
+	})
+	waitFor(t, s, "the client's limiter to be forgotten", func(st Stats) bool {
+		return st.Limiters == 0
+	})
+}
@@ -0,0 +1,76 @@
+package magservice
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Stats are counters for how the server has been doing since it started.
+type Stats struct {
+	Packets       int // Packets received, including limited and malformed ones.
+	Limited       int // Packets dropped for going over the rate limit.
+	Malformed     int // Packets that made no sense.
+	ReadErrors    int
+	Registrations int
+	Arrivals      int // Arrivals sent, two per match.
+	Expired       int // Clients cleaned up by the janitor.
+	RoomsOpened   int
+	RelaysOpened  int
+	Relayed       int // Packets relayed.
+	RelayedBytes  int
+	Clients       int // Currently registered clients.
+	Rooms         int // Currently open rooms.
+	Relays        int // Currently open relay sessions.
+	Limiters      int // IPs currently being rate limited, or close to it.
+}
+
+// Stats returns the server's current stats.
+func (s *Server) Stats() Stats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	st := s.stats
+	st.Clients = len(s.clients)
+	for _, mbox := range s.clients {
+		if mbox.room != nil {
+			st.Rooms++
+		}
+	}
+	st.Relays = len(s.relays)
+	st.Limiters = len(s.limiter.buckets) + len(s.relayLimiter.buckets)
+	return st
+}
+
+// WriteMetrics writes the stats in the Prometheus text format, as that's what everything scrapes.
+func (st Stats) WriteMetrics(w io.Writer) {
+	metric := func(name, kind, help string, v int) {
+		fmt.Fprintf(w, "# HELP magservice_%s %s\n# TYPE magservice_%s %s\nmagservice_%s %d\n", name, help, name, kind, name, v)
+	}
+	metric("packets_total", "counter", "Packets received.", st.Packets)
+	metric("limited_total", "counter", "Packets dropped by the rate limiter.", st.Limited)
+	metric("malformed_total", "counter", "Packets that made no sense.", st.Malformed)
+	metric("read_errors_total", "counter", "Failed reads.", st.ReadErrors)
+	metric("registrations_total", "counter", "Registrations, including refreshes.", st.Registrations)
+	metric("arrivals_total", "counter", "Arrivals sent.", st.Arrivals)
+	metric("expired_total", "counter", "Clients cleaned up for going quiet.", st.Expired)
+	metric("rooms_opened_total", "counter", "Rooms opened.", st.RoomsOpened)
+	metric("relays_opened_total", "counter", "Relay sessions opened.", st.RelaysOpened)
+	metric("relayed_total", "counter", "Packets relayed.", st.Relayed)
+	metric("relayed_bytes_total", "counter", "Bytes relayed.", st.RelayedBytes)
+	metric("clients", "gauge", "Registered clients.", st.Clients)
+	metric("rooms", "gauge", "Open rooms.", st.Rooms)
+	metric("relays", "gauge", "Open relay sessions.", st.Relays)
+	metric("limiters", "gauge", "IPs being tracked by the rate limiter.", st.Limiters)
+}
+
+// serveAdmin serves the stats on the admin address until the server is closed.
+func (s *Server) serveAdmin() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		s.Stats().WriteMetrics(w)
+	})
+	if err := http.Serve(s.admin, mux); err != nil && !s.isClosed() {
+		s.logf("admin stopped", "error", err)
+	}
+}
@@ -0,0 +1,119 @@
+package net
+
+import (
+	"encoding/json"
+)
+
+// Codec turns messages into packets and back. Peers agree on which one to use when they say henlo, and henlos themselves are always sent as JSON so that anyone can read them.
+type Codec interface {
+	// Version identifies the codec. Newer codecs get higher versions. For anything but JSON, it is also the first byte of every packet.
+	Version() int
+	// Encode encodes the packet. Its message may be nil if it only carries acks.
+	Encode(pk Packet) ([]byte, error)
+	// Decode decodes a packet made by Encode. Messages of unknown types come back as nil.
+	Decode(b []byte) (Packet, error)
+}
+
+var codecs = make(map[int]Codec)
+
+// AddCodec makes the codec available for peers to negotiate.
+func AddCodec(c Codec) {
+	codecs[c.Version()] = c
+}
+
+func init() {
+	AddCodec(JSONCodec{})
+	AddCodec(BinaryCodec{})
+}
+
+// negotiateCodec returns the newest codec both us and a peer that supports up to the given version have.
+func negotiateCodec(ours Codec, theirs int) Codec {
+	if ours == nil {
+		return JSONCodec{}
+	}
+	if theirs >= ours.Version() {
+		return ours
+	}
+	for v := theirs; v > 0; v-- {
+		if c, ok := codecs[v]; ok {
+			return c
+		}
+	}
+	return JSONCodec{}
+}
+
+// codecForPacket returns the codec that made the packet, or nil if it isn't a typed message at all.
+func codecForPacket(b []byte) Codec {
+	if len(b) == 0 {
+		return nil
+	}
+	if b[0] == '{' {
+		return JSONCodec{}
+	}
+	// Handshake messages start with a digit, so they'll never match a codec version.
+	if c, ok := codecs[int(b[0])]; ok && c.Version() != 0 {
+		return c
+	}
+	return nil
+}
+
+// JSONCodec is the original codec, where the message is marshalled into a JSON envelope.
+type JSONCodec struct{}
+
+// jsonPacket is the JSON envelope.
+type jsonPacket struct {
+	TypedMessage
+	Seq     int     `json:"s,omitempty"`
+	Ack     int     `json:"a,omitempty"`
+	AckBits uint64  `json:"b,omitempty"`
+	ID      int     `json:"i,omitempty"`
+	Channel Channel `json:"c,omitempty"`
+	Order   int     `json:"o,omitempty"`
+}
+
+// Version returns 0, as JSON is what everyone starts with.
+func (JSONCodec) Version() int {
+	return 0
+}
+
+// Encode marshals the packet into a JSON envelope.
+func (JSONCodec) Encode(pk Packet) ([]byte, error) {
+	envelope := jsonPacket{
+		Seq:     pk.Seq,
+		Ack:     pk.Ack,
+		AckBits: pk.AckBits,
+		ID:      pk.ID,
+		Channel: pk.Channel,
+		Order:   pk.Order,
+	}
+
+	payload, err := json.Marshal(pk.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	if pk.Message != nil {
+		envelope.Type = pk.Message.Type()
+	}
+	envelope.Data = payload
+
+	return json.Marshal(envelope)
+}
+
+// Decode unmarshals a JSON envelope and its message.
+func (JSONCodec) Decode(b []byte) (Packet, error) {
+	var envelope jsonPacket
+	if err := json.Unmarshal(b, &envelope); err != nil {
+		return Packet{}, err
+	}
+	// Unknown types come back as nil, same as bare acks, so they still get acked.
+	return Packet{
+		Seq:     envelope.Seq,
+		Ack:     envelope.Ack,
+		AckBits: envelope.AckBits,
+		ID:      envelope.ID,
+		Channel: envelope.Channel,
+		Order:   envelope.Order,
+		Message: envelope.Message(),
+	}, nil
+}
@@ -0,0 +1,306 @@
+package net
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+)
+
+// BinaryCodec packs messages into a compact binary form. A packet is the codec version, the sequence number and ack as uvarints, the ack bits, the message type and reliable ID as uvarints, the channel and order for reliable messages, then the message itself.
+//
+// Messages are encoded by walking their fields in order, so both sides must have the same message definitions, which the codec version negotiation takes care of. Only what JSON would send is sent: exported fields not tagged with `json:"-"`. Interface fields can't be decoded by JSON either, so they're skipped and always arrive as nil, as do slices and maps of them.
+type BinaryCodec struct{}
+
+// Version returns 1.
+func (BinaryCodec) Version() int {
+	return 1
+}
+
+var errShortPacket = errors.New("binary packet too short")
+
+// Encode encodes the packet.
+func (c BinaryCodec) Encode(pk Packet) ([]byte, error) {
+	e := binaryEncoder{b: []byte{byte(c.Version())}}
+	e.uvarint(uint64(pk.Seq))
+	e.uvarint(uint64(pk.Ack))
+	e.value(reflect.ValueOf(pk.AckBits))
+	t := MissingMessageType
+	if pk.Message != nil {
+		t = pk.Message.Type()
+	}
+	e.uvarint(uint64(t))
+	e.uvarint(uint64(pk.ID))
+	if pk.ID != 0 {
+		e.b = append(e.b, byte(pk.Channel))
+		if pk.Channel != UnorderedChannel {
+			e.uvarint(uint64(pk.Order))
+		}
+	}
+	if pk.Message != nil {
+		e.value(reflect.ValueOf(pk.Message))
+	}
+	return e.b, nil
+}
+
+// Decode decodes a packet made by Encode. Unknown message types come back as nil, along with the rest of the packet so they can still be acked.
+func (c BinaryCodec) Decode(b []byte) (pk Packet, err error) {
+	if len(b) == 0 || int(b[0]) != c.Version() {
+		return pk, errors.New("not a binary packet")
+	}
+	d := binaryDecoder{b: b[1:]}
+	pk.Seq = int(d.uvarint())
+	pk.Ack = int(d.uvarint())
+	d.value(reflect.ValueOf(&pk.AckBits).Elem())
+	t := TypedMessageType(d.uvarint())
+	pk.ID = int(d.uvarint())
+	if pk.ID != 0 {
+		if ch := d.bytes(1); ch != nil {
+			pk.Channel = Channel(ch[0])
+		}
+		if pk.Channel != UnorderedChannel {
+			pk.Order = int(d.uvarint())
+		}
+	}
+	if d.err != nil {
+		return Packet{}, d.err
+	}
+	handler, ok := TypedMessageMap[t]
+	if t == MissingMessageType || !ok || handler.kind == nil {
+		return pk, nil
+	}
+	v := reflect.New(handler.kind).Elem()
+	d.value(v)
+	if d.err != nil {
+		return Packet{}, d.err
+	}
+	pk.Message, _ = v.Interface().(Message)
+	return pk, nil
+}
+
+// binaryFields returns the indices of the struct's fields that get encoded.
+func binaryFields(t reflect.Type) (fields []int) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		if f.Tag.Get("json") == "-" {
+			continue
+		}
+		fields = append(fields, i)
+	}
+	return fields
+}
+
+type binaryEncoder struct {
+	b []byte
+}
+
+func (e *binaryEncoder) uvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	e.b = append(e.b, buf[:n]...)
+}
+
+func (e *binaryEncoder) varint(v int64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(buf[:], v)
+	e.b = append(e.b, buf[:n]...)
+}
+
+func (e *binaryEncoder) value(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.b = append(e.b, 1)
+		} else {
+			e.b = append(e.b, 0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.varint(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.uvarint(v.Uint())
+	case reflect.Float32:
+		var buf [4]byte
+		binary.LittleEndian.PutUint32(buf[:], math.Float32bits(float32(v.Float())))
+		e.b = append(e.b, buf[:]...)
+	case reflect.Float64:
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v.Float()))
+		e.b = append(e.b, buf[:]...)
+	case reflect.String:
+		e.uvarint(uint64(v.Len()))
+		e.b = append(e.b, v.String()...)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Interface {
+			e.uvarint(0)
+			return
+		}
+		e.uvarint(uint64(v.Len()))
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			// Bytes, such as json.RawMessage, go as-is.
+			e.b = append(e.b, v.Bytes()...)
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			e.value(v.Index(i))
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			e.value(v.Index(i))
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() == reflect.Interface || v.Type().Key().Kind() == reflect.Interface {
+			e.uvarint(0)
+			return
+		}
+		e.uvarint(uint64(v.Len()))
+		iter := v.MapRange()
+		for iter.Next() {
+			e.value(iter.Key())
+			e.value(iter.Value())
+		}
+	case reflect.Struct:
+		for _, i := range binaryFields(v.Type()) {
+			e.value(v.Field(i))
+		}
+	case reflect.Ptr:
+		if v.IsNil() {
+			e.b = append(e.b, 0)
+			return
+		}
+		e.b = append(e.b, 1)
+		e.value(v.Elem())
+	default:
+		// Interfaces, funcs, and channels don't go over the wire.
+	}
+}
+
+type binaryDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *binaryDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = errShortPacket
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *binaryDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.err = errShortPacket
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *binaryDecoder) bytes(n uint64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if uint64(len(d.b)) < n {
+		d.err = errShortPacket
+		return nil
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+// length reads a length, making sure there's at least that many bytes left so a bad packet can't have us allocate the world.
+func (d *binaryDecoder) length() int {
+	n := d.uvarint()
+	if n > uint64(len(d.b)) {
+		d.err = errShortPacket
+		return 0
+	}
+	return int(n)
+}
+
+func (d *binaryDecoder) value(v reflect.Value) {
+	if d.err != nil {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if b := d.bytes(1); b != nil {
+			v.SetBool(b[0] != 0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(d.varint())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v.SetUint(d.uvarint())
+	case reflect.Float32:
+		if b := d.bytes(4); b != nil {
+			v.SetFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(b))))
+		}
+	case reflect.Float64:
+		if b := d.bytes(8); b != nil {
+			v.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+		}
+	case reflect.String:
+		n := d.length()
+		if b := d.bytes(uint64(n)); b != nil {
+			v.SetString(string(b))
+		}
+	case reflect.Slice:
+		n := d.length()
+		if d.err != nil || n == 0 {
+			return
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := d.bytes(uint64(n))
+			v.SetBytes(append([]byte{}, b...))
+			return
+		}
+		s := reflect.MakeSlice(v.Type(), n, n)
+		for i := 0; i < n && d.err == nil; i++ {
+			d.value(s.Index(i))
+		}
+		v.Set(s)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			d.value(v.Index(i))
+		}
+	case reflect.Map:
+		n := d.length()
+		if d.err != nil {
+			return
+		}
+		m := reflect.MakeMapWithSize(v.Type(), n)
+		for i := 0; i < n && d.err == nil; i++ {
+			k := reflect.New(v.Type().Key()).Elem()
+			e := reflect.New(v.Type().Elem()).Elem()
+			d.value(k)
+			d.value(e)
+			m.SetMapIndex(k, e)
+		}
+		v.Set(m)
+	case reflect.Struct:
+		for _, i := range binaryFields(v.Type()) {
+			d.value(v.Field(i))
+		}
+	case reflect.Ptr:
+		b := d.bytes(1)
+		if b == nil || b[0] == 0 {
+			return
+		}
+		p := reflect.New(v.Type().Elem())
+		d.value(p.Elem())
+		v.Set(p)
+	}
+}
@@ -0,0 +1,85 @@
+package net
+
+import (
+	"reflect"
+	"testing"
+)
+
+// unknownMessage is a message type nobody registered.
+type unknownMessage struct {
+	A int
+}
+
+func (m unknownMessage) Type() TypedMessageType {
+	return 9999
+}
+
+var testPackets = []Packet{
+	{},
+	{Seq: 1, Ack: 2},
+	// Ack bits all the way to the top, and numbers past what fits in a byte or an int32.
+	{Seq: 1 << 40, Ack: 300, AckBits: 1<<63 | 1, ID: 1 << 33, Channel: 2, Order: 70000, Message: HenloMessage{
+		Greeting: "henlo",
+		Name:     "ünïcode",
+		You:      "you",
+		Codec:    1,
+		Key:      []byte{0, 1, 2, 255},
+		Proof:    []byte{9},
+	}},
+	{Seq: 5, ID: 3, Message: TravelMessage{Destination: "002"}},
+	{Seq: 6, Message: PingMessage{}},
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for v, c := range codecs {
+		for _, pk := range testPackets {
+			b, err := c.Encode(pk)
+			if err != nil {
+				t.Fatalf("codec %d: encoding %+v: %v", v, pk, err)
+			}
+			if got := codecForPacket(b); got != c {
+				t.Errorf("codec %d: packet %q looks like it's from codec %v", v, b, got)
+			}
+			got, err := c.Decode(b)
+			if err != nil {
+				t.Fatalf("codec %d: decoding %+v: %v", v, pk, err)
+			}
+			if !reflect.DeepEqual(got, pk) {
+				t.Errorf("codec %d: got %+v, want %+v", v, got, pk)
+			}
+		}
+	}
+}
+
+func TestCodecUnknownType(t *testing.T) {
+	pk := Packet{Seq: 4, Ack: 3, ID: 2, Message: unknownMessage{A: 1}}
+	for v, c := range codecs {
+		b, err := c.Encode(pk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.Decode(b)
+		if err != nil {
+			t.Fatalf("codec %d: %v", v, err)
+		}
+		// Everything but the message, so that it can still be acked.
+		want := pk
+		want.Message = nil
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("codec %d: got %+v, want %+v", v, got, want)
+		}
+	}
+}
+
+func TestBinaryCodecTruncated(t *testing.T) {
+	c := BinaryCodec{}
+	b, err := c.Encode(testPackets[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < len(b); n++ {
+		if pk, err := c.Decode(b[:n]); err == nil {
+			t.Errorf("decoded %d of %d bytes into %+v", n, len(b), pk)
+		}
+	}
+}
@@ -1,14 +1,12 @@
 package net
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net"
 	"os"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 )
@@ -18,51 +16,77 @@ type Connection struct {
 
 	// handshakerAddr is the target handshaker service.
 	handshakerAddr *net.UDPAddr
+	// target is who we originally joined, either a name for the handshaker or a direct address. It is used to rejoin if the connection is lost.
+	target string
+	// room is the room we're hosting through the handshaker, if any.
+	room *Room
+	// roomID is the handshaker room we joined, if any. Like target, it is used to rejoin.
+	roomID int
+	// relays are the handshaker's relay sessions for the peers it introduced us to.
+	relays []*relayRoute
 
 	// conn is our own base connection.
 	conn *net.UDPConn
 
-	// otherConn is the peer we wish to play with.
-	otherConn     *net.UDPConn
-	otherAddress  *net.UDPAddr
-	OtherName     string
-	multicastConn *net.UDPConn
+	// peers are the other players we're playing with. Clients only ever have the host.
+	peers []*Peer
+	// multicastConns are what we're broadcasting our LAN game on.
+	multicastConns []*net.UDPConn
+	// lanGame is the game we're broadcasting on the LAN, if any. Like room, it is guarded by lock.
+	lanGame *LANGame
+
+	// Spectator is set by clients that only want to watch the host's game.
+	Spectator bool
+	// Codec is the newest codec we'd like to use. Each peer gets the newest one both sides have.
+	Codec Codec
+	// noSessions is set if we couldn't make a session key, so that we don't keep trying.
+	noSessions bool
+	// password is the key derived from the lobby password, if any.
+	password []byte
 
 	//
-	connected    bool
-	disconnected bool
-	active       bool
-	hosting      bool
+	active  bool
+	hosting bool
 
 	//
-	lastReceived time.Time
-	lastSent     time.Time
+	lastSent time.Time
 
 	//
-	messages             chan Message
-	reliableID           int
-	sendingReliables     []ReliableTypedMessage
-	receivedReliables    []ReliableTypedMessage
-	confirmedReliableIDs []int
-	reliableLock         sync.Mutex
+	messages chan PeerMessage
+	lock     sync.Mutex // Guards peers and their reliables.
 }
 
 func NewConnection(name string) Connection {
 	return Connection{
 		Name:     name,
-		messages: make(chan Message, 1000),
+		Codec:    BinaryCodec{},
+		messages: make(chan PeerMessage, 1000),
 		active:   true,
 	}
 }
 
-// Connected returns if the connection is actually connected.
+// Connected returns if the connection is actually connected to any peer.
 func (c *Connection) Connected() bool {
-	return c.connected
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, p := range c.peers {
+		if p.connected {
+			return true
+		}
+	}
+	return false
 }
 
-// Connected returns if the connection was disconnected.
+// Disconnected returns if the connection was lost to all of our peers.
 func (c *Connection) Disconnected() bool {
-	return c.disconnected
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, p := range c.peers {
+		if !p.disconnected {
+			return false
+		}
+	}
+	return len(c.peers) > 0
 }
 
 // Active returns if the connection should be connected.
@@ -79,16 +103,12 @@ func (c *Connection) Close() {
 	if c.conn != nil {
 		c.conn.Close()
 	}
-	if c.otherConn != nil {
-		c.otherConn.Close()
-	}
-	if c.multicastConn != nil {
-		c.multicastConn.Close()
-	}
+	c.closeLAN()
 	c.active = false
 	c.hosting = false
-	c.connected = false
-	c.disconnected = false
+	c.lock.Lock()
+	c.peers = nil
+	c.lock.Unlock()
 }
 
 func (c *Connection) AwaitHandshake(handshaker string, local string, target string) error {
@@ -112,12 +132,18 @@ func (c *Connection) AwaitHandshake(handshaker string, local string, target stri
 
 	c.handshakerAddr = handshakerAddr
 	c.conn = localConn
+	c.target = target
+	c.lock.Lock()
+	c.relays = nil
+	c.lock.Unlock()
 	fmt.Println("listening on", localConn.LocalAddr().String())
 
 	localConn.SetDeadline(time.Now().Add(time.Duration(10) * time.Second))
+	// Clear our deadline when done, otherwise writes start failing once we're in the main loop.
+	defer localConn.SetDeadline(time.Time{})
 
 	log.Println("Sending register message to handshaker service")
-	_, err = localConn.WriteTo([]byte(fmt.Sprintf("%d %s", RegisterMessage, c.Name)), c.handshakerAddr)
+	_, err = localConn.WriteTo(FormatHandshake(RegisterMessage, c.Name), c.handshakerAddr)
 	if err != nil {
 		return err
 	}
@@ -131,29 +157,37 @@ func (c *Connection) AwaitHandshake(handshaker string, local string, target stri
 			return err
 		}
 		// Ignore sends from non-handhsaker.
-		if fromAddr.String() != handshakerAddr.String() {
+		if !sameAddr(fromAddr, handshakerAddr) {
 			continue
 		}
 		c.conn.SetReadDeadline(time.Time{})
-		msg := string(buffer[0:bytesRead])
-		a, err := strconv.Atoi(msg)
+		a, _, err := ParseHandshake(buffer[0:bytesRead])
 		if err != nil {
 			return err
 		}
-		if a != int(HandshakerMessage) {
+		if a != HandshakerMessage {
 			return errors.New("incorrect handshake response")
 		}
 		break
 	}
 
-	if target != "" {
+	if c.roomID != 0 {
+		log.Printf("Sending join message for room %d to handshaker service\n", c.roomID)
+		_, err := localConn.WriteTo(FormatHandshake(JoinRoomMessage, strconv.Itoa(c.roomID)), c.handshakerAddr)
+		if err != nil {
+			return err
+		}
+	} else if target != "" {
 		log.Printf("Sending await message for %s to handshaker service\n", target)
-		_, err := localConn.WriteTo([]byte(fmt.Sprintf("%d %s", AwaitMessage, target)), c.handshakerAddr)
+		_, err := localConn.WriteTo(FormatHandshake(AwaitMessage, target), c.handshakerAddr)
 		if err != nil {
 			return err
 		}
 	} else {
 		c.hosting = true
+		if err := c.sendRoom(); err != nil {
+			return err
+		}
 	}
 
 	return c.awaitHandshake()
@@ -162,31 +196,33 @@ func (c *Connection) AwaitHandshake(handshaker string, local string, target stri
 func (c *Connection) awaitHandshake() error {
 	fmt.Println("entering main await")
 	for {
-		buffer := make([]byte, 1024)
+		buffer := make([]byte, maxDatagramSize)
 		bytesRead, fromAddr, err := c.conn.ReadFromUDP(buffer)
 		if err != nil {
 			return err
 		}
-		msg := string(buffer[0:bytesRead])
-		parts := strings.Split(msg, " ")
-		a, err := strconv.Atoi(parts[0])
+		a, fields, err := ParseHandshake(buffer[0:bytesRead])
 		if err != nil {
-			return err
+			fmt.Println("unhandled message from", fromAddr.String())
+			continue
 		}
-		if a == int(ArrivedMessage) {
-			otherAddr, err := net.ResolveUDPAddr("udp", parts[1])
+		if a == ArrivedMessage && len(fields) > 0 {
+			otherAddr, err := net.ResolveUDPAddr("udp", fields[0])
 			if err != nil {
 				return err
 			}
-			_, err = c.conn.WriteTo([]byte(fmt.Sprintf("%d %s", HelloMessage, c.Name)), otherAddr)
+			_, err = c.conn.WriteTo(c.hello(), otherAddr)
 			if err != nil {
 				return err
 			}
-			c.otherAddress = otherAddr
+			c.joinedPeer(otherAddr, "")
+			c.addRelay(otherAddr, parseRelaySession(fields))
 			return nil
-		} else if a == int(HelloMessage) {
-			c.otherAddress = fromAddr
+		} else if a == HelloMessage && len(fields) > 0 {
+			c.joinedPeer(fromAddr, fields[0])
 			return nil
+		} else if a == MissingRoomMessage {
+			return errors.New("room no longer exists")
 		} else {
 			fmt.Println("unhandled message from", fromAddr.String())
 			continue
@@ -209,14 +245,16 @@ func (c *Connection) AwaitDirect(local string, target string) error {
 	}
 
 	c.conn = localConn
+	c.target = target
 	fmt.Println("listening on", localConn.LocalAddr().String())
 
+	var otherAddr *net.UDPAddr
 	if target != "" {
-		otherAddr, err := net.ResolveUDPAddr("udp", target)
+		otherAddr, err = net.ResolveUDPAddr("udp", target)
 		if err != nil {
 			return err
 		}
-		_, err = c.conn.WriteTo([]byte(fmt.Sprintf("%d %s", HelloMessage, c.Name)), otherAddr)
+		_, err = c.conn.WriteTo(c.hello(), otherAddr)
 		if err != nil {
 			return err
 		}
@@ -226,28 +264,34 @@ func (c *Connection) AwaitDirect(local string, target string) error {
 
 	// Start the listen loop.
 	for {
-		buffer := make([]byte, 1024)
+		buffer := make([]byte, maxDatagramSize)
+		// Keep saying hello every second if we're joining, as the first may have been lost.
+		if otherAddr != nil {
+			c.conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+		}
 		bytesRead, fromAddr, err := c.conn.ReadFromUDP(buffer)
-		if err != nil {
+		if os.IsTimeout(err) {
+			c.conn.WriteTo(c.hello(), otherAddr)
+			continue
+		} else if err != nil {
 			return err
 		}
-		msg := string(buffer[0:bytesRead])
-		parts := strings.Split(msg, " ")
-		a, err := strconv.Atoi(parts[0])
+		c.conn.SetReadDeadline(time.Time{})
+		a, fields, err := ParseHandshake(buffer[0:bytesRead])
 		if err != nil {
 			return err
 		}
-		if a == int(HelloMessage) {
-			fmt.Println("got hello from self-declared", parts[1])
+		if a == HelloMessage && len(fields) > 0 {
+			fmt.Println("got hello from self-declared", fields[0])
 			fmt.Println(fromAddr.String())
 
 			// Send hello back to let the other client that we're ready to rumble.
-			_, err := c.conn.WriteTo([]byte(fmt.Sprintf("%d %s", HelloMessage, c.Name)), fromAddr)
+			_, err := c.conn.WriteTo(c.hello(), fromAddr)
 			if err != nil {
 				return err
 			}
 
-			c.otherAddress = fromAddr
+			c.joinedPeer(fromAddr, fields[0])
 			break
 		} else {
 			// BOGUS
@@ -256,277 +300,469 @@ func (c *Connection) AwaitDirect(local string, target string) error {
 	return nil
 }
 
-func (c *Connection) AwaitLAN(joiner bool) error {
-	localAddr, err := net.ResolveUDPAddr("udp4", "")
-	if err != nil {
-		return err
-	}
-
-	// Start listening!
-	localConn, err := net.ListenUDP("udp4", localAddr)
-	if err != nil {
-		return err
+func (c *Connection) Loop() {
+	fmt.Println("starting main loop with", len(c.peers), "peer(s)")
+	// Give fragments of big packets somewhere to wait while we get to them.
+	c.conn.SetReadBuffer(4 * 1024 * 1024)
+	c.lock.Lock()
+	for _, p := range c.peers {
+		p.connected = true
+		p.lastReceived = time.Now()
 	}
-
-	c.conn = localConn
-	fmt.Println("listening on", localConn.LocalAddr().String())
-
-	multiAddr, err := net.ResolveUDPAddr("udp4", "239.0.0.0:20221")
-	if err != nil {
-		return err
+	c.lock.Unlock()
+	if err := c.Send(c.henlo("hai")); err != nil {
+		panic(err)
 	}
-	if !joiner {
-		c.multicastConn, err = net.DialUDP("udp4", nil, multiAddr)
-		if err != nil {
-			return err
+	c.lastSent = time.Now()
+	var lastRegistered, lastBroadcast time.Time
+	// Nothing we decode keeps hold of this, so it can be reused for every read.
+	b := make([]byte, maxDatagramSize)
+	for {
+		if !c.active {
+			return
 		}
-		fmt.Println("broadcasting on", c.multicastConn.LocalAddr().String())
-		c.hosting = true
-	} else {
-		c.multicastConn, err = net.ListenMulticastUDP("udp4", nil, multiAddr)
-		if err != nil {
-			return err
+		t := time.Now()
+		// More than 5 seconds have passed since last receive, presume failure.
+		c.lock.Lock()
+		for _, p := range c.peers {
+			if p.connected && t.Sub(p.lastReceived) > 5*time.Second {
+				c.losePeer(p, "lost connection to")
+			}
+		}
+		c.expireFragments(t)
+		c.lock.Unlock()
+		c.updateRelays(t)
+
+		if !c.hosting {
+			if c.Disconnected() {
+				// Go through the same steps we originally joined with.
+				if err := c.rejoin(); err != nil {
+					if !c.active {
+						return
+					}
+					fmt.Println("failed to rejoin", err)
+					time.Sleep(1 * time.Second)
+					continue
+				}
+				c.resume(c.peers[0])
+				continue
+			}
+		} else if c.handshakerAddr != nil && t.Sub(lastRegistered) > 10*time.Second {
+			// Keep ourselves registered with the handshaker so that more players, or ones that lost their connection, can find us.
+			c.conn.WriteTo(FormatHandshake(RegisterMessage, c.Name), c.handshakerAddr)
+			c.sendRoom()
+			lastRegistered = t
+		}
+		if c.hosting && t.Sub(lastBroadcast) > 1*time.Second {
+			// Keep telling the LAN about our game while there's room for more players.
+			c.broadcastLAN()
+			lastBroadcast = t
 		}
-		fmt.Println("listening for broadcast on", c.multicastConn.LocalAddr().String())
-	}
 
-	// Start the listen loop.
-	for {
-		if !joiner {
-			buffer := make([]byte, 1024)
-			c.conn.SetReadDeadline(time.Now().Add(1 * time.Second))
-			bytesRead, fromAddr, err := c.conn.ReadFromUDP(buffer)
-			if err != nil && !os.IsTimeout(err) {
-				return err
+		// Send a ping every 3 seconds.
+		if t.Sub(c.lastSent) > 3*time.Second {
+			c.Send(PingMessage{})
+			// Also keep saying henlo until the host says it back, as it tells us our name.
+			if !c.hosting && len(c.peers) > 0 && !c.peers[0].greeted {
+				c.Send(c.henlo("hai"))
 			}
-			if os.IsTimeout(err) {
-				c.multicastConn.Write([]byte(fmt.Sprintf("%d %s", BroadcastMessage, c.conn.LocalAddr())))
-			} else {
-				msg := string(buffer[0:bytesRead])
-				parts := strings.Split(msg, " ")
-				a, err := strconv.Atoi(parts[0])
-				if err != nil {
-					return err
-				}
-				if a == int(HelloMessage) {
-					c.otherAddress = fromAddr
-					c.multicastConn.Close()
-					c.multicastConn = nil
-					break
-				}
+		}
+
+		// Attempt to read any pending messages. The deadline is short, as resends and acks need to go out on time even when nothing is arriving.
+		c.conn.SetReadDeadline(t.Add(ackDelay))
+		n, foreignAddr, err := c.conn.ReadFromUDP(b)
+		if err != nil && !os.IsTimeout(err) {
+			if !c.active {
+				return
 			}
-			// 1. Await localConn for msg
-			// 2. If nothing, send broadcast of localConn
-			// 3. Otherwise, send hello on localConn back to sender.
-		} else {
-			// 1. Await multicastConn for msg
-			// 2.
-			buffer := make([]byte, 1024)
-			c.conn.SetReadDeadline(time.Now().Add(1 * time.Second))
-			bytesRead, fromAddr, err := c.multicastConn.ReadFromUDP(buffer)
-			if err != nil && !os.IsTimeout(err) {
-				return err
+			fmt.Println("disconnect")
+			fmt.Println(err)
+			if c.hosting {
+				return
+			}
+			// Let the rejoin take care of it.
+			c.lock.Lock()
+			for _, p := range c.peers {
+				p.connected = false
+				p.disconnected = true
 			}
-			if os.IsTimeout(err) {
-				//
+			c.lock.Unlock()
+			continue
+		}
+		packet, from := b[:n], foreignAddr
+		if err == nil && n > 0 {
+			if c.handshakerAddr != nil && sameAddr(foreignAddr, c.handshakerAddr) {
+				if b[0] == RelayMark {
+					packet, from = c.unrelay(packet)
+				}
 			} else {
-				msg := string(buffer[0:bytesRead])
+				c.heardDirectly(foreignAddr)
+			}
+		}
+		if from != nil {
+			c.handlePacket(packet, from, false)
+		}
 
-				parts := strings.Split(msg, " ")
-				a, err := strconv.Atoi(parts[0])
-				if err != nil {
-					return err
-				}
-				if a == int(BroadcastMessage) {
-					targetAddr, err := net.ResolveUDPAddr("udp", parts[1])
-					if err != nil {
-						continue
-					}
-					// Let's construct a correct address by joining the multicast ip to the target port.
-					targetAddr.IP = fromAddr.IP
+		// Resend anything that's gone unacked for too long, and send acks that have nothing to ride along with.
+		c.lock.Lock()
+		c.updateReliables(time.Now())
+		c.lock.Unlock()
+	}
+}
 
-					_, err = c.conn.WriteTo([]byte(fmt.Sprintf("%d %s", HelloMessage, c.Name)), targetAddr)
-					if err != nil {
-						return err
-					}
-					c.otherAddress = targetAddr
+// handlePacket handles a single packet from the main loop, once it has been opened and any fragments of it have been put back together.
+func (c *Connection) handlePacket(b []byte, fromAddr *net.UDPAddr, sealed bool) {
+	if len(b) > 0 && b[0] == sealedMark {
+		peer := c.peerByAddress(fromAddr)
+		if peer == nil {
+			return
+		}
+		c.lock.Lock()
+		var plain []byte
+		err := errors.New("no session")
+		if peer.session != nil {
+			plain, err = peer.session.open(b)
+		}
+		c.lock.Unlock()
+		if err != nil {
+			// Probably from before a new session, or someone up to no good. Either way there's nothing to be done with it.
+			return
+		}
+		c.handlePacket(plain, fromAddr, true)
+		return
+	}
 
-					c.multicastConn.Close()
-					c.multicastConn = nil
+	// Once there's a session, or if there has to be one, the only thing we take unsealed is a henlo.
+	insecure := false
+	if !sealed {
+		if peer := c.peerByAddress(fromAddr); peer != nil {
+			c.lock.Lock()
+			insecure = peer.session != nil || c.password != nil
+			c.lock.Unlock()
+		}
+	}
 
-					break
-				}
+	if len(b) > 0 && b[0] == fragmentMark {
+		if insecure {
+			return
+		}
+		if peer := c.peerByAddress(fromAddr); peer != nil {
+			if whole := c.reassemble(peer, b); whole != nil {
+				c.handlePacket(whole, fromAddr, sealed)
 			}
 		}
+		return
 	}
-	return nil
-}
 
-func (c *Connection) Loop() {
-	fmt.Println("starting main loop with", c.otherAddress.String())
-	if err := c.Send(HenloMessage{
-		Name:     c.Name,
-		Greeting: "hai",
-	}); err != nil {
-		panic(err)
+	// Typed messages always start with their codec's mark, so anything else is handshaking.
+	codec := codecForPacket(b)
+	if codec == nil {
+		if len(b) > 0 {
+			c.handleHandshake(b, fromAddr)
+		}
+		return
 	}
-	c.connected = true
-	c.lastReceived = time.Now()
-	c.lastSent = time.Now()
-	for {
-		t := time.Now()
-		// More than 5 seconds have passed since last receive, presume failure.
-		if t.Sub(c.lastReceived) > 5*time.Second {
-			fmt.Println("lost connection")
-			c.connected = false
-			c.disconnected = true
-			return
+	peer := c.peerByAddress(fromAddr)
+	if peer == nil {
+		return
+	}
+	pk, err := codec.Decode(b)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if insecure {
+		// Its seq and acks can't be trusted, so only the henlo itself counts.
+		if m, ok := pk.Message.(HenloMessage); ok && pk.ID == 0 {
+			c.greet(peer, m, false)
 		}
-		// Send a ping every 3 seconds.
-		if t.Sub(c.lastSent) > 3*time.Second {
-			c.Send(PingMessage{})
+		return
+	}
+	c.lock.Lock()
+	peer.lastReceived = time.Now()
+	wasDisconnected := peer.disconnected
+	messages := peer.receive(pk, peer.lastReceived)
+	c.lock.Unlock()
+	// They came back from the same address, so nothing needs to be done other than letting the game know.
+	if wasDisconnected {
+		c.resume(peer)
+	}
+	for _, m := range messages {
+		switch m := m.(type) {
+		case HenloMessage:
+			c.greet(peer, m, sealed)
+		case PingMessage:
+		default:
+			c.messages <- PeerMessage{From: peer.Name, Message: m}
 		}
+	}
+}
 
-		// Attempt to read any pending messages, with a 2 second deadline.
-		b := make([]byte, 1000)
-		c.conn.SetReadDeadline(t.Add(2 * time.Second))
-		n, foreignAddr, err := c.conn.ReadFromUDP(b)
-		if err != nil && !os.IsTimeout(err) {
-			fmt.Println("disconnect")
-			c.connected = false
-			fmt.Println(err)
+// greet handles a peer's henlo. The host makes sure everyone has a unique name and tells them what it is, as names are how players are told apart.
+func (c *Connection) greet(p *Peer, m HenloMessage, sealed bool) {
+	if !c.hosting {
+		c.lock.Lock()
+		if !c.startSession(p, m, sealed) {
+			if c.awaitingProof(m) {
+				c.sendPacket(p, Packet{Message: c.henlo("hai")})
+			}
+			c.lock.Unlock()
 			return
 		}
-		if foreignAddr.String() != c.otherAddress.String() {
-			continue
+		if m.You == "" {
+			// The host hasn't let us in yet, likely as it didn't have our key to check our password proof against. Now it does.
+			if !p.greeted {
+				c.sendPacket(p, Packet{Message: c.henlo("hai")})
+			}
+			c.lock.Unlock()
+			return
 		}
-		b = b[:n]
-		var msg ReliableTypedMessage
-		if err = json.Unmarshal(b, &msg); err != nil {
-			fmt.Println(err)
-		} else {
-			c.lastReceived = time.Now()
-			// Handle reliable messages such that we send a response whenever we receive it.
-			if msg.InboundID != 0 {
-				c.reliableLock.Lock()
-				// See if we've already confirmed sending this particular message.
-				for _, id := range c.confirmedReliableIDs {
-					if id == msg.InboundID {
-						c.reliableLock.Unlock()
-						// Ignore it if we've already confirmed it.
-						goto loopEnd
-					}
-				}
-				for i, m := range c.sendingReliables {
-					if m.OutboundID == msg.InboundID {
-						c.confirmedReliableIDs = append(c.confirmedReliableIDs, m.OutboundID)
-						c.sendingReliables = append(c.sendingReliables[:i], c.sendingReliables[i+1:]...)
-						c.reliableLock.Unlock()
-						goto loopEnd
-					}
-				}
-				c.reliableLock.Unlock()
-			} else if msg.OutboundID != 0 {
-				c.reliableLock.Lock()
-				// Always send an empty response with the ID.
-				c.sendReliableWithIDs(nil, msg.OutboundID, 0)
-				// Only continue processing if we haven't processed this specific message yet.
-				for _, m := range c.receivedReliables {
-					if m.OutboundID == msg.InboundID {
-						c.reliableLock.Unlock()
-						goto loopEnd
-					}
+		if m.You != c.Name {
+			fmt.Println("host knows us as", m.You)
+			c.Name = m.You
+		}
+		p.Name = m.Name
+		p.greeted = true
+		p.codec = negotiateCodec(c.Codec, m.Codec)
+		c.lock.Unlock()
+		return
+	}
+
+	c.lock.Lock()
+	if !c.startSession(p, m, sealed) {
+		if c.awaitingProof(m) {
+			// Give them our key so they can prove they know the password.
+			c.sendPacket(p, Packet{Message: c.henlo("hai")})
+		} else if !p.greeted {
+			// They're not getting in, so don't let them take up a spot.
+			c.removePeer(p)
+		}
+		c.lock.Unlock()
+		return
+	}
+	if p.Name == "" {
+		p.Name = c.uniqueName(m.Name, p)
+	}
+	joined := !p.greeted
+	p.greeted = true
+	p.Spectator = m.Spectator
+	p.codec = negotiateCodec(c.Codec, m.Codec)
+	codec := p.codec.Version()
+	c.lock.Unlock()
+
+	reply := c.henlo("hai")
+	reply.Spectator = false
+	reply.You = p.Name
+	reply.Codec = codec
+	c.SendTo(p.Name, reply)
+	if joined {
+		c.messages <- PeerMessage{From: p.Name, Message: PeerJoinedMessage{}}
+	}
+}
+
+// handleHandshake handles handshake messages that arrive during the main loop. This is how the host accepts more players, as well as peers that lost their connection.
+func (c *Connection) handleHandshake(msg []byte, fromAddr *net.UDPAddr) {
+	if !c.hosting {
+		return
+	}
+	a, fields, err := ParseHandshake(msg)
+	if err != nil || len(fields) == 0 {
+		return
+	}
+	if a == HelloMessage {
+		var resumed *Peer
+		c.lock.Lock()
+		peer := c.peerByAddressLocked(fromAddr)
+		if peer == nil {
+			// See if this is a peer coming back from a new address.
+			for _, p := range c.peers {
+				if p.disconnected && p.Name == fields[0] {
+					fmt.Println(p.Name, "rejoined from", fromAddr.String())
+					p.address = fromAddr
+					peer = p
+					resumed = p
+					break
 				}
-				c.receivedReliables = append(c.receivedReliables, msg)
-				c.reliableLock.Unlock()
-			}
-			switch m := msg.Message().(type) {
-			case HenloMessage:
-				c.OtherName = m.Name
-			case PingMessage:
-			default:
-				c.messages <- m
 			}
 		}
-	loopEnd:
-		// This is a bit of a bad spot for this, but resend any unconfirmed reliable messages at this point.
-		// FIXME: We should have some sort of "back off" algorithm that adjusts the time to wait to resend in according to time last received, so as to prevent spamming a d/ced connection into oblivion.
-		for _, m := range c.sendingReliables {
-			n := time.Now()
-			if n.Sub(m.lastSent) >= 500*time.Millisecond {
-				c.reliableLock.Lock()
-				c.sendReliableWithIDs(m.Message(), 0, m.OutboundID)
-				c.reliableLock.Unlock()
-				m.lastSent = n
+		if peer == nil {
+			if len(c.peers) >= MaxPeers {
+				c.lock.Unlock()
+				fmt.Println("ignoring hello from", fromAddr.String(), "as we're full")
+				return
 			}
+			peer = &Peer{
+				address:      fromAddr,
+				connected:    true,
+				lastReceived: time.Now(),
+			}
+			peer.Name = c.uniqueName(fields[0], peer)
+			c.peers = append(c.peers, peer)
+			fmt.Println(peer.Name, "joined from", fromAddr.String())
+		} else if peer.Name == "" {
+			peer.Name = c.uniqueName(fields[0], peer)
+		} else if peer.disconnected {
+			resumed = peer
+		}
+		c.writeRaw(c.hello(), fromAddr)
+		c.lock.Unlock()
+
+		if resumed != nil {
+			c.resume(resumed)
 		}
+	} else if a == ArrivedMessage && c.handshakerAddr != nil && sameAddr(fromAddr, c.handshakerAddr) {
+		otherAddr, err := net.ResolveUDPAddr("udp", fields[0])
+		if err != nil {
+			return
+		}
+		// Say hello so they know where we are, then wait for their hello to add them. It may come through the relay instead.
+		c.addRelay(otherAddr, parseRelaySession(fields))
+		c.conn.WriteTo(c.hello(), otherAddr)
 	}
 }
 
-// Send sends the given message interface to the other player.
-func (c *Connection) Send(msg Message) error {
-	var envelope TypedMessage
+// losePeer marks the peer as disconnected, until they come back. The lock must be held.
+func (c *Connection) losePeer(p *Peer, reason string) {
+	fmt.Println(reason, p.Name)
+	p.connected = false
+	p.disconnected = true
+	p.dropReliables()
+}
 
-	payload, err := json.Marshal(msg)
-	if err != nil {
-		return err
+// rejoin re-runs our original AwaitDirect or AwaitHandshake with a fresh local connection.
+func (c *Connection) rejoin() error {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	if c.handshakerAddr != nil {
+		return c.AwaitHandshake(c.handshakerAddr.String(), "", c.target)
 	}
+	return c.AwaitDirect("", c.target)
+}
 
-	envelope.Type = msg.Type()
-	envelope.Data = payload
+// resume marks the peer as connected again and lets the game know, so that it can resync.
+func (c *Connection) resume(p *Peer) {
+	c.lock.Lock()
+	p.connected = true
+	p.disconnected = false
+	p.lastReceived = time.Now()
+	c.lock.Unlock()
+	c.SendTo(p.Name, c.henlo("hai again"))
+	c.messages <- PeerMessage{From: p.Name, Message: ReconnectedMessage{}}
+}
 
-	bytes, err := json.Marshal(envelope)
-	if err != nil {
-		return err
+// Send sends the given message interface to all peers.
+func (c *Connection) Send(msg Message) error {
+	return c.send("", msg)
+}
+
+// SendTo sends the given message interface to the named peer.
+func (c *Connection) SendTo(name string, msg Message) error {
+	if name == "" {
+		return errors.New("missing peer name")
 	}
+	return c.send(name, msg)
+}
 
-	if bytes != nil {
-		_, err = c.conn.WriteTo(bytes, c.otherAddress)
+func (c *Connection) send(name string, msg Message) (err error) {
+	c.lock.Lock()
+	for _, p := range c.peers {
+		if name != "" && p.Name != name {
+			continue
+		}
+		if _, serr := c.sendPacket(p, Packet{Message: msg}); serr != nil {
+			err = serr
+		}
 	}
-	c.lastSent = time.Now()
+	c.lock.Unlock()
 	return err
 }
 
-// SendReliable sends the given message with special resending until a confirmation is received.
-func (c *Connection) SendReliable(msg Message) error {
-	c.reliableLock.Lock()
-	c.reliableID++
-	env, err := c.sendReliableWithIDs(msg, 0, c.reliableID)
-	env.lastSent = time.Now()
-	c.sendingReliables = append(c.sendingReliables, env)
-	c.reliableLock.Unlock()
-	return err
+// sendPacket fills in the packet's sequence number and acks, then encodes and sends it. The lock must be held.
+func (c *Connection) sendPacket(p *Peer, pk Packet) ([]byte, error) {
+	p.header(&pk)
+	if m, ok := pk.Message.(HenloMessage); ok {
+		c.sign(p, &m)
+		pk.Message = m
+	}
+	bytes, err := c.codecFor(p, pk.Message).Encode(pk)
+	if err != nil {
+		return nil, err
+	}
+	c.lastSent = time.Now()
+	if _, ok := pk.Message.(HenloMessage); ok {
+		// Henlos are how sessions get started, so they can't be sealed.
+		return bytes, c.writeRaw(bytes, p.address)
+	}
+	return bytes, c.writeTo(p, bytes)
 }
 
-func (c *Connection) sendReliableWithIDs(msg Message, inboundID, outboundID int) (ReliableTypedMessage, error) {
-	var envelope ReliableTypedMessage
+// codecFor returns the codec to send the message to the peer with. Henlos are always JSON, as they're how we agree on a codec in the first place.
+func (c *Connection) codecFor(p *Peer, msg Message) Codec {
+	if _, ok := msg.(HenloMessage); ok || p.codec == nil {
+		return JSONCodec{}
+	}
+	return p.codec
+}
 
-	payload, err := json.Marshal(msg)
-	if err != nil {
-		return envelope, err
+// codecVersion returns the newest codec version we support.
+func (c *Connection) codecVersion() int {
+	if c.Codec == nil {
+		return 0
 	}
+	return c.Codec.Version()
+}
+
+// SendReliable sends the given message to all peers with special resending until a confirmation is received.
+func (c *Connection) SendReliable(msg Message) error {
+	return c.sendReliable("", UnorderedChannel, msg)
+}
 
-	if msg != nil {
-		envelope.Type = msg.Type()
+// SendReliableTo reliably sends the given message to the named peer.
+func (c *Connection) SendReliableTo(name string, msg Message) error {
+	if name == "" {
+		return errors.New("missing peer name")
 	}
-	envelope.Data = payload
-	envelope.InboundID = inboundID
-	envelope.OutboundID = outboundID
+	return c.sendReliable(name, UnorderedChannel, msg)
+}
 
-	bytes, err := json.Marshal(envelope)
-	if err != nil {
-		return envelope, err
+// SendOrdered reliably sends the given message to all peers on the given channel. Peers won't get it until they've gotten everything sent before it on the same channel.
+func (c *Connection) SendOrdered(ch Channel, msg Message) error {
+	return c.sendReliable("", ch, msg)
+}
+
+// SendOrderedTo reliably sends the given message to the named peer on the given channel.
+func (c *Connection) SendOrderedTo(name string, ch Channel, msg Message) error {
+	if name == "" {
+		return errors.New("missing peer name")
 	}
+	return c.sendReliable(name, ch, msg)
+}
 
-	if bytes != nil {
-		_, err = c.conn.WriteTo(bytes, c.otherAddress)
+func (c *Connection) sendReliable(name string, ch Channel, msg Message) (err error) {
+	c.lock.Lock()
+	for _, p := range c.peers {
+		if name != "" && p.Name != name {
+			continue
+		}
+		if qerr := c.queueReliable(p, ch, msg); qerr != nil {
+			err = qerr
+		}
 	}
-	c.lastSent = time.Now()
-	return envelope, err
+	c.lock.Unlock()
+	return err
 }
 
-// Messages returns the current contents of the messages channel as a slice.
+// Messages returns the current contents of the messages channel as a slice, without who sent them.
 func (c *Connection) Messages() (m []Message) {
+	for _, pm := range c.PeerMessages() {
+		m = append(m, pm.Message)
+	}
+	return m
+}
+
+// PeerMessages returns the current contents of the messages channel as a slice.
+func (c *Connection) PeerMessages() (m []PeerMessage) {
 	for {
 		done := false
 		select {
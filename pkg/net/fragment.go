@@ -0,0 +1,171 @@
+package net
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaxPacketSize is the largest datagram we'll send in one go, including any sealing and relaying. Anything bigger is split into fragments, as datagrams over the path's MTU tend to get dropped along the way.
+const MaxPacketSize = 1200
+
+// datagramOverhead is how much sealing and relaying can add to a packet.
+const datagramOverhead = sealedOverhead + RelayHeaderSize
+
+// maxDatagramSize is the largest datagram there can be, so reads never cut anything off.
+const maxDatagramSize = 65535
+
+// MaxMessageSize is the largest packet that can be sent, once fragmented. World snapshots are the biggest thing we send, and they fit with plenty of room to spare.
+const MaxMessageSize = 1 << 20
+
+// fragmentSize is how much of a packet goes in each fragment, leaving room for the header, and for sealing and relaying.
+const fragmentSize = MaxPacketSize - 1 - 3*binary.MaxVarintLen32 - datagramOverhead
+
+// maxFragments caps how many fragments a single packet may be split into, which is as many as it takes to carry the largest message. As the count comes from the sender, this and fragments being no bigger than fragmentSize keep a peer from having us allocate anything too silly.
+const maxFragments = (MaxMessageSize + fragmentSize - 1) / fragmentSize
+
+// maxReassemblies caps how many of a peer's packets we'll put back together at once. Fragments of any more are dropped until some finish or time out, and reliable ones get sent again anyway.
+const maxReassemblies = 8
+
+// fragmentMark starts every fragment. It can't be mistaken for a codec version, a JSON object, or a handshake.
+const fragmentMark = 0xFF
+
+// fragmentTimeout is how long we wait on a packet's next fragment before giving up on it. It's longer than the longest wait between resends, so a big reliable message can fill in its missing fragments over a few of them.
+const fragmentTimeout = 2 * maxRTO
+
+// fragmentSet is a packet we're still receiving the fragments of.
+type fragmentSet struct {
+	parts    [][]byte
+	received int
+	updated  time.Time // When we last got one of its fragments, even a repeat.
+	done     bool      // Kept around for a bit so late resends don't start it all over again.
+}
+
+// writeTo sends the packet to the peer, splitting it into a new set of fragments if it is too big. The lock must be held.
+func (c *Connection) writeTo(p *Peer, b []byte) error {
+	if len(b) <= MaxPacketSize-datagramOverhead {
+		return c.writeDatagram(p, b)
+	}
+	p.fragmentID++
+	return c.writeFragments(p, b, p.fragmentID)
+}
+
+// writeFragments sends the packet to the peer as the given fragment set. Resending a packet with the same set lets the peer fill in whatever fragments it missed. The lock must be held.
+func (c *Connection) writeFragments(p *Peer, b []byte, id int) error {
+	if len(b) <= MaxPacketSize-datagramOverhead {
+		return c.writeDatagram(p, b)
+	}
+
+	fragments, err := fragment(b, id)
+	if err != nil {
+		return err
+	}
+	for _, f := range fragments {
+		if err := c.writeDatagram(p, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fragment splits the packet into the fragments of the given set.
+func fragment(b []byte, id int) ([][]byte, error) {
+	if len(b) > MaxMessageSize {
+		return nil, errors.New("packet too large to fragment")
+	}
+	count := (len(b) + fragmentSize - 1) / fragmentSize
+	fragments := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		chunk := b[i*fragmentSize:]
+		if len(chunk) > fragmentSize {
+			chunk = chunk[:fragmentSize]
+		}
+		f := []byte{fragmentMark}
+		f = appendUvarint(f, uint64(id))
+		f = appendUvarint(f, uint64(i))
+		f = appendUvarint(f, uint64(count))
+		f = append(f, chunk...)
+		fragments = append(fragments, f)
+	}
+	return fragments, nil
+}
+
+// reassemble adds a fragment from the peer, returning the whole packet once all of its fragments have arrived.
+func (c *Connection) reassemble(p *Peer, b []byte) []byte {
+	d := binaryDecoder{b: b[1:]}
+	id := int(d.uvarint())
+	index := int(d.uvarint())
+	count := int(d.uvarint())
+	if d.err != nil || count <= 0 || count > maxFragments || index < 0 || index >= count || len(d.b) > fragmentSize {
+		fmt.Println("bad fragment from", p.Name)
+		return nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	p.lastReceived = time.Now()
+	if p.fragments == nil {
+		p.fragments = make(map[int]*fragmentSet)
+	}
+	set, ok := p.fragments[id]
+	if !ok {
+		if p.reassembling() >= maxReassemblies {
+			fmt.Println("too many packets being reassembled from", p.Name)
+			return nil
+		}
+		set = &fragmentSet{
+			parts: make([][]byte, count),
+		}
+		p.fragments[id] = set
+	}
+	set.updated = time.Now()
+	if set.done || len(set.parts) != count || set.parts[index] != nil {
+		// Either a duplicate or something's off, either way we've got it covered.
+		return nil
+	}
+	set.parts[index] = append([]byte{}, d.b...)
+	set.received++
+	if set.received < count {
+		return nil
+	}
+
+	var whole []byte
+	for _, part := range set.parts {
+		whole = append(whole, part...)
+	}
+	set.parts = nil
+	set.done = true
+	return whole
+}
+
+// reassembling returns how many of the peer's packets are still waiting on fragments. The lock must be held.
+func (p *Peer) reassembling() (n int) {
+	for _, set := range p.fragments {
+		if !set.done {
+			n++
+		}
+	}
+	return n
+}
+
+// expireFragments throws out any packets that have been waiting on fragments for too long. The lock must be held.
+func (c *Connection) expireFragments(t time.Time) {
+	for _, p := range c.peers {
+		for id, set := range p.fragments {
+			if t.Sub(set.updated) > fragmentTimeout {
+				if !set.done {
+					fmt.Printf("dropping incomplete packet from %s, got %d/%d fragments\n", p.Name, set.received, len(set.parts))
+				}
+				delete(p.fragments, id)
+			}
+		}
+	}
+}
+
+// appendUvarint is binary.AppendUvarint, which we don't have yet.
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
@@ -0,0 +1,116 @@
+package net
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// testPacket returns a packet big enough to need n fragments.
+func testPacket(n int) []byte {
+	b := make([]byte, (n-1)*fragmentSize+10)
+	for i := range b {
+		b[i] = byte(i * 7)
+	}
+	return b
+}
+
+func TestFragmentReassembly(t *testing.T) {
+	var c Connection
+	p := &Peer{Name: "peer"}
+	b := testPacket(4)
+	fragments, err := fragment(b, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fragments) != 4 {
+		t.Fatalf("got %d fragments, want 4", len(fragments))
+	}
+
+	// Out of order, with repeats, including of the last one.
+	order := []int{2, 0, 2, 3, 0, 1}
+	var whole []byte
+	for i, f := range order {
+		got := c.reassemble(p, fragments[f])
+		if got != nil && i != len(order)-1 {
+			t.Fatalf("packet came back after fragment %d of %v", f, order[:i+1])
+		}
+		if got != nil {
+			whole = got
+		}
+	}
+	if !bytes.Equal(whole, b) {
+		t.Fatalf("reassembled %d bytes that don't match the %d sent", len(whole), len(b))
+	}
+	// A late resend doesn't bring it back again.
+	for _, f := range fragments {
+		if c.reassemble(p, f) != nil {
+			t.Error("finished packet came back again")
+		}
+	}
+}
+
+func TestFragmentMissing(t *testing.T) {
+	var c Connection
+	p := &Peer{Name: "peer"}
+	c.peers = []*Peer{p}
+	fragments, err := fragment(testPacket(3), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range fragments[:2] {
+		if c.reassemble(p, f) != nil {
+			t.Fatal("packet came back without all of its fragments")
+		}
+	}
+	// It waits a while for the rest before giving up.
+	c.expireFragments(time.Now())
+	if p.reassembling() != 1 {
+		t.Fatalf("got %d packets being reassembled, want 1", p.reassembling())
+	}
+	c.expireFragments(time.Now().Add(fragmentTimeout + time.Second))
+	if p.reassembling() != 0 || len(p.fragments) != 0 {
+		t.Fatalf("got %d packets being reassembled after they timed out", p.reassembling())
+	}
+	// Coming too late, the rest is a packet all of its own that never finishes.
+	if c.reassemble(p, fragments[2]) != nil {
+		t.Error("packet came back from its last fragment alone")
+	}
+}
+
+func TestFragmentLimits(t *testing.T) {
+	if _, err := fragment(make([]byte, MaxMessageSize+1), 1); err == nil {
+		t.Error("fragmented a packet over MaxMessageSize")
+	}
+	if fragments, err := fragment(make([]byte, MaxMessageSize), 1); err != nil || len(fragments) > maxFragments {
+		t.Errorf("got %d fragments and %v for the largest message, want at most %d", len(fragments), err, maxFragments)
+	}
+
+	var c Connection
+	p := &Peer{Name: "peer"}
+	bad := [][]byte{
+		// More fragments than any message needs.
+		appendUvarint(appendUvarint(appendUvarint([]byte{fragmentMark}, 1), 0), maxFragments+1),
+		// An index past the count.
+		appendUvarint(appendUvarint(appendUvarint([]byte{fragmentMark}, 1), 2), 2),
+		// No count at all.
+		appendUvarint(appendUvarint([]byte{fragmentMark}, 1), 0),
+		// Bigger than any fragment we'd send.
+		append(appendUvarint(appendUvarint(appendUvarint([]byte{fragmentMark}, 1), 0), 2), make([]byte, fragmentSize+1)...),
+	}
+	for _, f := range bad {
+		c.reassemble(p, f)
+	}
+	if p.reassembling() != 0 {
+		t.Errorf("started reassembling %d packets from bad fragments", p.reassembling())
+	}
+
+	// Only so many packets are put back together at once.
+	for id := 1; id <= maxReassemblies+1; id++ {
+		fragments, _ := fragment(testPacket(2), id)
+		c.reassemble(p, fragments[0])
+	}
+	if p.reassembling() != maxReassemblies {
+		t.Errorf("got %d packets being reassembled, want %d", p.reassembling(), maxReassemblies)
+	}
+}
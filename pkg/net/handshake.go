@@ -0,0 +1,51 @@
+package net
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Handshake messages are the message's number followed by its fields, separated by spaces. Each field is escaped, so names and addresses with spaces, brackets, or zones in them, such as "[fe80::1%Ethernet 2]:20220", come out the other side the same.
+
+// FormatHandshake puts together a handshake message.
+func FormatHandshake(kind HandshakeMessage, fields ...string) []byte {
+	var b strings.Builder
+	b.WriteString(strconv.Itoa(int(kind)))
+	for _, f := range fields {
+		b.WriteByte(' ')
+		b.WriteString(url.PathEscape(f))
+	}
+	return []byte(b.String())
+}
+
+// ParseHandshake splits a handshake message back into its kind and fields.
+func ParseHandshake(b []byte) (HandshakeMessage, []string, error) {
+	parts := strings.Split(string(b), " ")
+	a, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, nil, errors.New("not a handshake message")
+	}
+	fields := parts[1:]
+	for i, f := range fields {
+		if fields[i], err = url.PathUnescape(f); err != nil {
+			return 0, nil, err
+		}
+	}
+	return HandshakeMessage(a), fields, nil
+}
+
+// hello returns our hello message.
+func (c *Connection) hello() []byte {
+	return FormatHandshake(HelloMessage, c.Name)
+}
+
+// sameAddr returns if the two addresses are the same. IPv4 addresses match their IPv4-mapped IPv6 forms, as that's what they look like when they arrive on a dual-stack socket.
+func sameAddr(a, b *net.UDPAddr) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Port == b.Port && a.IP.Equal(b.IP) && a.Zone == b.Zone
+}
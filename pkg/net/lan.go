@@ -0,0 +1,294 @@
+package net
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LAN games are found with multicast. Hosts broadcast where they're listening to an IPv4 group, as well as to an IPv6 link-local group on every interface that has IPv6, and joiners listen on all of them. Every host keeps broadcasting while it has room for more players, so joiners can collect them all into a list to pick from.
+
+// lanPort is the port LAN broadcasts are sent to.
+const lanPort = 20221
+
+// lanExpiry is how long a LAN game stays listed after its last broadcast.
+const lanExpiry = 5 * time.Second
+
+// ProtocolVersion is the version of the game's networking. Hosts broadcast it so that LAN players can tell which games they're able to join.
+const ProtocolVersion = 1
+
+var (
+	lanGroup4 = net.IPv4(239, 0, 0, 0)
+	lanGroup6 = net.ParseIP("ff02::4d47") // Link-local, so it never leaves the network.
+)
+
+// LANGame is a game being broadcast on the LAN.
+type LANGame struct {
+	Name     string `json:"n"`
+	Map      string `json:"m"`
+	Version  int    `json:"v"`
+	Slots    int    `json:"s"` // How many more players can join.
+	Password bool   `json:"pw"`
+	// Addr is where the host is listening, put together from where its broadcast came from.
+	Addr *net.UDPAddr `json:"-"`
+
+	lastHeard time.Time
+}
+
+// Compatible returns if the game was broadcast by a version we can play with.
+func (g LANGame) Compatible() bool {
+	return g.Version == ProtocolVersion
+}
+
+// lanInterfaces returns the interfaces that IPv6 broadcasts can go over.
+func lanInterfaces() (ifis []net.Interface) {
+	all, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	for _, ifi := range all {
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+		addrs, _ := ifi.Addrs()
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.To4() == nil {
+				ifis = append(ifis, ifi)
+				break
+			}
+		}
+	}
+	return ifis
+}
+
+// openLAN opens up a connection to every LAN group we can, either to broadcast on or to listen on.
+func openLAN(joiner bool) (conns []*net.UDPConn, err error) {
+	open := func(network string, ifi *net.Interface, addr *net.UDPAddr) {
+		var conn *net.UDPConn
+		var err error
+		if joiner {
+			conn, err = net.ListenMulticastUDP(network, ifi, addr)
+		} else {
+			conn, err = net.DialUDP(network, nil, addr)
+		}
+		if err != nil {
+			fmt.Println("can't use LAN group", addr.String(), err)
+			return
+		}
+		conns = append(conns, conn)
+	}
+
+	open("udp4", nil, &net.UDPAddr{IP: lanGroup4, Port: lanPort})
+	for _, ifi := range lanInterfaces() {
+		ifi := ifi
+		if joiner {
+			open("udp6", &ifi, &net.UDPAddr{IP: lanGroup6, Port: lanPort})
+		} else {
+			open("udp6", nil, &net.UDPAddr{IP: lanGroup6, Port: lanPort, Zone: ifi.Name})
+		}
+	}
+	if len(conns) == 0 {
+		return nil, errors.New("no LAN groups available")
+	}
+	for _, conn := range conns {
+		if joiner {
+			fmt.Println("listening for broadcast on", conn.LocalAddr().String())
+		} else {
+			fmt.Println("broadcasting to", conn.RemoteAddr().String())
+		}
+	}
+	return conns, nil
+}
+
+// closeLAN closes all of our LAN group connections.
+func (c *Connection) closeLAN() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, conn := range c.multicastConns {
+		conn.Close()
+	}
+	c.multicastConns = nil
+	c.lanGame = nil
+}
+
+// broadcastLAN tells the LAN about our game, if we're hosting one there.
+func (c *Connection) broadcastLAN() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.lanGame == nil {
+		return
+	}
+	game := *c.lanGame
+	game.Version = ProtocolVersion
+	game.Password = c.password != nil
+	game.Slots = MaxPeers - len(c.peers)
+	if game.Slots <= 0 {
+		// Nobody else can get in, so don't tempt them.
+		return
+	}
+	b, err := json.Marshal(game)
+	if err != nil {
+		return
+	}
+	broadcast := FormatHandshake(BroadcastMessage, c.conn.LocalAddr().String(), string(b))
+	for _, conn := range c.multicastConns {
+		conn.Write(broadcast)
+	}
+}
+
+// AwaitLAN hosts a game on the LAN, broadcasting it until the first player joins. The main loop keeps broadcasting afterwards while there's room for more.
+func (c *Connection) AwaitLAN(mapName string) error {
+	// Start listening! This is dual-stack where the system allows it, so peers can reach us however they heard about us.
+	localConn, err := net.ListenUDP("udp", &net.UDPAddr{})
+	if err != nil {
+		return err
+	}
+
+	c.conn = localConn
+	fmt.Println("listening on", localConn.LocalAddr().String())
+
+	conns, err := openLAN(false)
+	if err != nil {
+		return err
+	}
+	c.lock.Lock()
+	c.multicastConns = conns
+	c.lanGame = &LANGame{
+		Name: c.Name,
+		Map:  mapName,
+	}
+	c.lock.Unlock()
+
+	c.hosting = true
+	// 1. Await localConn for msg
+	// 2. If nothing, send broadcast of localConn
+	// 3. Otherwise, send hello on localConn back to sender.
+	for {
+		buffer := make([]byte, maxDatagramSize)
+		c.conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+		bytesRead, fromAddr, err := c.conn.ReadFromUDP(buffer)
+		if err != nil && !os.IsTimeout(err) {
+			c.closeLAN()
+			return err
+		}
+		if os.IsTimeout(err) {
+			c.broadcastLAN()
+			continue
+		}
+		a, fields, err := ParseHandshake(buffer[0:bytesRead])
+		if err != nil {
+			continue
+		}
+		if a == HelloMessage && len(fields) > 0 {
+			c.conn.SetReadDeadline(time.Time{})
+			if _, err := c.conn.WriteTo(c.hello(), fromAddr); err != nil {
+				c.closeLAN()
+				return err
+			}
+			c.joinedPeer(fromAddr, fields[0])
+			return nil
+		}
+	}
+}
+
+// JoinLAN joins a game found by a LANBrowser.
+func (c *Connection) JoinLAN(game LANGame) error {
+	if game.Addr == nil {
+		return errors.New("LAN game has no address")
+	}
+	if !game.Compatible() {
+		return fmt.Errorf("LAN game is version %d, but we're version %d", game.Version, ProtocolVersion)
+	}
+	return c.AwaitDirect("", game.Addr.String())
+}
+
+// LANBrowser listens for LAN broadcasts, keeping a list of the games being hosted.
+type LANBrowser struct {
+	conns []*net.UDPConn
+	games map[string]*LANGame // Keyed by host address.
+	lock  sync.Mutex
+}
+
+// NewLANBrowser starts listening for LAN games on every group we can.
+func NewLANBrowser() (*LANBrowser, error) {
+	conns, err := openLAN(true)
+	if err != nil {
+		return nil, err
+	}
+	b := &LANBrowser{
+		conns: conns,
+		games: make(map[string]*LANGame),
+	}
+	for _, conn := range conns {
+		go b.listen(conn)
+	}
+	return b, nil
+}
+
+// listen collects broadcasts from one group until it's closed.
+func (b *LANBrowser) listen(conn *net.UDPConn) {
+	buffer := make([]byte, maxDatagramSize)
+	for {
+		bytesRead, fromAddr, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			return
+		}
+		a, fields, err := ParseHandshake(buffer[0:bytesRead])
+		if err != nil || a != BroadcastMessage || len(fields) == 0 {
+			continue
+		}
+		broadcastAddr, err := net.ResolveUDPAddr("udp", fields[0])
+		if err != nil {
+			continue
+		}
+		var game LANGame
+		if len(fields) > 1 {
+			// Anything without this is from before versions were broadcast, which leaves it at 0 and so incompatible.
+			json.Unmarshal([]byte(fields[1]), &game)
+		}
+		// Let's construct a correct address by joining the broadcaster's ip, and zone if it's link-local, to the target port.
+		game.Addr = &net.UDPAddr{
+			IP:   fromAddr.IP,
+			Port: broadcastAddr.Port,
+			Zone: fromAddr.Zone,
+		}
+		game.lastHeard = time.Now()
+
+		b.lock.Lock()
+		b.games[game.Addr.String()] = &game
+		b.lock.Unlock()
+	}
+}
+
+// Games returns the games heard from recently, sorted by name.
+func (b *LANBrowser) Games() (games []LANGame) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	t := time.Now()
+	for k, g := range b.games {
+		if t.Sub(g.lastHeard) > lanExpiry {
+			delete(b.games, k)
+			continue
+		}
+		games = append(games, *g)
+	}
+	sort.Slice(games, func(i, j int) bool {
+		if games[i].Name != games[j].Name {
+			return games[i].Name < games[j].Name
+		}
+		return games[i].Addr.String() < games[j].Addr.String()
+	})
+	return games
+}
+
+// Close stops listening for LAN games.
+func (b *LANBrowser) Close() {
+	for _, conn := range b.conns {
+		conn.Close()
+	}
+}
@@ -2,7 +2,7 @@ package net
 
 import (
 	"encoding/json"
-	"time"
+	"reflect"
 )
 
 // HandshakeMessage represents the type for the handshake step of networking.
@@ -16,6 +16,11 @@ const (
 	HelloMessage
 	HandshakerMessage
 	BroadcastMessage
+	RoomMessage        // A host telling the handshaker about its room.
+	ListRoomsMessage   // Asking the handshaker for its rooms.
+	RoomsMessage       // The handshaker's rooms.
+	JoinRoomMessage    // Asking the handshaker to introduce us to a room's host.
+	MissingRoomMessage // The room asked for doesn't exist, or no longer does.
 )
 
 // TypedMessageType represents the contained type within a TypedMessage.
@@ -28,26 +33,22 @@ const (
 	PingMessageType
 	TravelMessageType
 	MoveMessageType
+	ReconnectedMessageType
+	PeerJoinedMessageType
 )
 
 var topType TypedMessageType = 100
 
-type ReliableTypedMessage struct {
-	TypedMessage
-	InboundID  int `json:"i"`
-	OutboundID int `json:"o"`
-}
-
 // TypedMessage wraps a Message.
 type TypedMessage struct {
-	Type     TypedMessageType `json:"t"`
-	Data     json.RawMessage  `json:"d"`
-	lastSent time.Time        // Locally used value to determine re-send time.d time.
+	Type TypedMessageType `json:"t"`
+	Data json.RawMessage  `json:"d"`
 }
 
 // TypedMessageHandler represents a dynamically defined unmarshaller for a message.
 type TypedMessageHandler struct {
 	Unwrap func(data json.RawMessage) Message
+	kind   reflect.Type // The message's type, for codecs that need to make their own.
 }
 
 // TypedMessageMap is a map of all defined types->TypedMessageHandlers
@@ -59,8 +60,14 @@ func AddTypedMessage(typeIndex int, unwrapper func(data json.RawMessage) Message
 		topType++
 		typeIndex = int(topType)
 	}
+	// Unwrapping nothing gets us a zero message, which tells us its type.
+	var kind reflect.Type
+	if m := unwrapper(json.RawMessage("{}")); m != nil {
+		kind = reflect.TypeOf(m)
+	}
 	TypedMessageMap[TypedMessageType(typeIndex)] = TypedMessageHandler{
 		Unwrap: unwrapper,
+		kind:   kind,
 	}
 	return TypedMessageType(typeIndex)
 }
@@ -70,21 +77,25 @@ func (t *TypedMessage) Message() Message {
 	if tt, ok := TypedMessageMap[t.Type]; ok {
 		return tt.Unwrap(t.Data)
 	}
-	switch t.Type {
-	case HenloMessageType:
+	return nil
+}
+
+func init() {
+	AddTypedMessage(int(HenloMessageType), func(data json.RawMessage) Message {
 		var m HenloMessage
-		json.Unmarshal(t.Data, &m)
+		json.Unmarshal(data, &m)
 		return m
-	case PingMessageType:
+	})
+	AddTypedMessage(int(PingMessageType), func(data json.RawMessage) Message {
 		var m PingMessage
-		json.Unmarshal(t.Data, &m)
+		json.Unmarshal(data, &m)
 		return m
-	case TravelMessageType:
+	})
+	AddTypedMessage(int(TravelMessageType), func(data json.RawMessage) Message {
 		var m TravelMessage
-		json.Unmarshal(t.Data, &m)
+		json.Unmarshal(data, &m)
 		return m
-	}
-	return nil
+	})
 }
 
 // Message represents a message that can be sent as a typed message's data.
@@ -94,8 +105,13 @@ type Message interface {
 
 // HenloMessage is our basic greeting message.
 type HenloMessage struct {
-	Greeting string `json:"g"`
-	Name     string `json:"n"`
+	Greeting  string `json:"g"`
+	Name      string `json:"n"`
+	You       string `json:"y,omitempty"` // Sent by the host with the name it knows the peer as, as names must be unique.
+	Spectator bool   `json:"s,omitempty"` // Sent by clients that only want to watch.
+	Codec     int    `json:"c,omitempty"` // The newest codec version the sender supports. The host replies with the one to use.
+	Key       []byte `json:"k,omitempty"` // The sender's half of the session key exchange, if they can do sessions.
+	Proof     []byte `json:"p,omitempty"` // Proves the sender knows the lobby password, if there is one.
 }
 
 // Type returns HenloMessage's corresponding type number.
@@ -112,6 +128,24 @@ func (m PingMessage) Type() TypedMessageType {
 	return PingMessageType
 }
 
+// ReconnectedMessage is never sent, but is queued up locally whenever the connection is re-established after being lost.
+type ReconnectedMessage struct {
+}
+
+// Type returns ReconnectedMessage's corresponding type number.
+func (m ReconnectedMessage) Type() TypedMessageType {
+	return ReconnectedMessageType
+}
+
+// PeerJoinedMessage is never sent, but is queued up locally by the host whenever a new peer has joined.
+type PeerJoinedMessage struct {
+}
+
+// Type returns PeerJoinedMessage's corresponding type number.
+func (m PeerJoinedMessage) Type() TypedMessageType {
+	return PeerJoinedMessageType
+}
+
 // TravelMessage is sent by the host to clients to enforce travel.
 type TravelMessage struct {
 	Destination string `json:"d"`
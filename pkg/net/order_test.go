@@ -0,0 +1,50 @@
+package net
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOrderedChannels(t *testing.T) {
+	var r reliability
+	now := time.Now()
+	id := 0
+	// receive has the message with the given order arrive on the channel, returning the destinations of what was handed over.
+	receive := func(ch Channel, order int, msg Message) (got []string) {
+		id++
+		for _, m := range r.receive(Packet{ID: id, Channel: ch, Order: order, Message: msg}, now) {
+			got = append(got, m.(TravelMessage).Destination)
+		}
+		return got
+	}
+	travel := func(d string) Message {
+		return TravelMessage{Destination: d}
+	}
+	expect := func(what string, got []string, want ...string) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Errorf("%s: got %v, want %v", what, got, want)
+			return
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("%s: got %v, want %v", what, got, want)
+				return
+			}
+		}
+	}
+
+	expect("3 ahead of its turn", receive(1, 3, travel("c")))
+	expect("2 ahead of its turn", receive(1, 2, travel("b")))
+	// Another channel isn't held up by the first.
+	expect("other channel", receive(2, 1, travel("x")), "x")
+	// Nor is the unordered one.
+	expect("unordered", receive(UnorderedChannel, 0, travel("u")), "u")
+	expect("1 releasing 2 and 3", receive(1, 1, travel("a")), "a", "b", "c")
+	// Ones that were already handed over aren't again, even with a new ID.
+	expect("late repeat", receive(1, 2, travel("b")))
+	// One that was dropped for being unencodable still takes its turn, it just has nothing to hand over.
+	expect("5 ahead of its turn", receive(1, 5, travel("e")))
+	expect("empty 4", receive(1, 4, nil), "e")
+	expect("6", receive(1, 6, travel("f")), "f")
+}
@@ -0,0 +1,130 @@
+package net
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// MaxPeers is how many other players a host will accept.
+const MaxPeers = 3
+
+// Peer is another player we're connected to.
+type Peer struct {
+	Name         string
+	address      *net.UDPAddr
+	connected    bool
+	disconnected bool
+	greeted      bool        // If we've exchanged henlos.
+	Spectator    bool        // Spectators get everything a client does, but don't get a player.
+	codec        Codec       // What we send them with, JSON until we've agreed on something better.
+	session      *session    // Set once we've agreed on a session key, after which everything but henlos is sealed.
+	key          *sessionKey // Our half of the session key exchange with them.
+	theirKey     []byte      // Their half, from their henlo.
+	fragmentID   int         // The last fragment set we sent them.
+	fragments    map[int]*fragmentSet
+	lastReceived time.Time
+	//
+	reliability
+}
+
+// PeerMessage is a received message along with the name of the peer that sent it.
+type PeerMessage struct {
+	From    string
+	Message Message
+}
+
+// Peers returns the names of our peers, in the order they joined.
+func (c *Connection) Peers() (names []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, p := range c.peers {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// Spectating returns if the named peer is only watching. This isn't known until they've said henlo.
+func (c *Connection) Spectating(name string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, p := range c.peers {
+		if p.Name == name {
+			return p.Spectator
+		}
+	}
+	return false
+}
+
+// joinedPeer records the peer we just handshaked with. Clients only ever have the host as a peer, so rejoining just updates its address.
+func (c *Connection) joinedPeer(addr *net.UDPAddr, name string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.hosting && len(c.peers) > 0 {
+		c.peers[0].address = addr
+		return
+	}
+	p := &Peer{
+		address: addr,
+		codec:   JSONCodec{},
+	}
+	if name != "" {
+		p.Name = c.uniqueName(name, p)
+	}
+	c.peers = append(c.peers, p)
+}
+
+// removePeer forgets about the peer. The lock must be held.
+func (c *Connection) removePeer(p *Peer) {
+	for i, o := range c.peers {
+		if o == p {
+			c.peers = append(c.peers[:i], c.peers[i+1:]...)
+			return
+		}
+	}
+}
+
+func (c *Connection) peerByAddress(addr *net.UDPAddr) *Peer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.peerByAddressLocked(addr)
+}
+
+func (c *Connection) peerByAddressLocked(addr *net.UDPAddr) *Peer {
+	if addr == nil {
+		return nil
+	}
+	for _, p := range c.peers {
+		if sameAddr(p.address, addr) {
+			return p
+		}
+	}
+	return nil
+}
+
+// uniqueName returns the name, suffixed with a number if ourselves or another peer already has it.
+func (c *Connection) uniqueName(name string, except *Peer) string {
+	taken := func(n string) bool {
+		if !c.hosting {
+			return false
+		}
+		if n == c.Name {
+			return true
+		}
+		for _, p := range c.peers {
+			if p != except && p.Name == n {
+				return true
+			}
+		}
+		return false
+	}
+	if !taken(name) {
+		return name
+	}
+	for i := 2; ; i++ {
+		n := fmt.Sprintf("%s%d", name, i)
+		if !taken(n) {
+			return n
+		}
+	}
+}
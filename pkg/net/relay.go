@@ -0,0 +1,159 @@
+package net
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// When the handshaker introduces two peers it can also give them a relay session. If the client doesn't hear from the host directly soon enough, usually thanks to symmetric NAT, it gives up on hole punching and sends everything through the handshaker instead. The host follows along as soon as something arrives through the relay. Peers keep the address the handshaker saw the other from either way, so nothing past reading and writing datagrams knows the difference.
+
+// RelayMark starts every relayed datagram, followed by the relay session. It can't be mistaken for a seal, a fragment, a codec version, a JSON object, or a handshake.
+const RelayMark = 0xFD
+
+// RelayHeaderSize is the mark plus the session.
+const RelayHeaderSize = 1 + 8
+
+// relayTimeout is how long a client waits to hear from the host directly before going through the relay. It's well under the time it takes to give up on a peer.
+const relayTimeout = 2 * time.Second
+
+// relayExpiry is how long a host hangs on to a relay session nobody has used.
+const relayExpiry = 30 * time.Second
+
+// relayRoute is a way to reach a peer through the handshaker.
+type relayRoute struct {
+	session uint64
+	address *net.UDPAddr // Where the handshaker saw them from, which is how we know them.
+	arrived time.Time
+	heard   bool // We've heard from them directly, so it's not needed.
+	active  bool // Everything to them goes through the handshaker.
+}
+
+// parseRelaySession parses the relay session that an arrival's fields may carry, returning 0 if there isn't one.
+func parseRelaySession(fields []string) uint64 {
+	if len(fields) < 2 {
+		return 0
+	}
+	session, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return session
+}
+
+// appendRelayHeader appends the relay header for the session.
+func appendRelayHeader(b []byte, session uint64) []byte {
+	var h [RelayHeaderSize]byte
+	h[0] = RelayMark
+	binary.BigEndian.PutUint64(h[1:], session)
+	return append(b, h[:]...)
+}
+
+// RelaySession returns the relay session of a relayed datagram.
+func RelaySession(b []byte) (uint64, bool) {
+	if len(b) < RelayHeaderSize || b[0] != RelayMark {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(b[1:]), true
+}
+
+// addRelay remembers the relay session the handshaker gave us for the peer at the address.
+func (c *Connection) addRelay(addr *net.UDPAddr, session uint64) {
+	if session == 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, r := range c.relays {
+		if r.session == session {
+			return
+		}
+	}
+	c.relays = append(c.relays, &relayRoute{
+		session: session,
+		address: addr,
+		arrived: time.Now(),
+	})
+}
+
+// activeRelay returns the active relay route to the address, if there is one. The lock must be held.
+func (c *Connection) activeRelay(addr *net.UDPAddr) *relayRoute {
+	for _, r := range c.relays {
+		if r.active && sameAddr(r.address, addr) {
+			return r
+		}
+	}
+	return nil
+}
+
+// writeRaw sends a datagram to the address, through the relay if that's how we reach them. The lock must be held.
+func (c *Connection) writeRaw(b []byte, addr *net.UDPAddr) error {
+	if r := c.activeRelay(addr); r != nil {
+		b = append(appendRelayHeader(make([]byte, 0, RelayHeaderSize+len(b)), r.session), b...)
+		addr = c.handshakerAddr
+	}
+	_, err := c.conn.WriteTo(b, addr)
+	return err
+}
+
+// heardDirectly notes that something arrived from the address without going through the relay.
+func (c *Connection) heardDirectly(addr *net.UDPAddr) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, r := range c.relays {
+		if !r.heard && sameAddr(r.address, addr) {
+			r.heard = true
+		}
+	}
+}
+
+// unrelay opens up a relayed datagram, returning who it's really from. Hosts switch over to the relay the first time the client uses it.
+func (c *Connection) unrelay(b []byte) ([]byte, *net.UDPAddr) {
+	session, ok := RelaySession(b)
+	if !ok {
+		return nil, nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, r := range c.relays {
+		if r.session != session {
+			continue
+		}
+		if !r.active {
+			fmt.Println("relaying traffic with", r.address.String(), "through the handshaker")
+			r.active = true
+		}
+		return b[RelayHeaderSize:], r.address
+	}
+	return nil, nil
+}
+
+// updateRelays has clients give up on reaching the host directly once it's been too long, and has hosts forget relay sessions nobody used.
+func (c *Connection) updateRelays(t time.Time) {
+	c.lock.Lock()
+	var switched []*relayRoute
+	relays := c.relays[:0]
+	for _, r := range c.relays {
+		if c.hosting {
+			if !r.active && t.Sub(r.arrived) > relayExpiry && c.peerByAddressLocked(r.address) == nil {
+				continue
+			}
+		} else if !r.active && !r.heard && t.Sub(r.arrived) > relayTimeout {
+			fmt.Println("couldn't reach", r.address.String(), "directly, relaying through the handshaker")
+			r.active = true
+			switched = append(switched, r)
+		}
+		relays = append(relays, r)
+	}
+	c.relays = relays
+	for _, r := range switched {
+		// Say hello again, as the first one never made it.
+		c.writeRaw(c.hello(), r.address)
+	}
+	c.lock.Unlock()
+	if len(switched) > 0 {
+		c.Send(c.henlo("hai"))
+	}
+}
@@ -0,0 +1,364 @@
+package net
+
+import (
+	"fmt"
+	"time"
+)
+
+// Packet is a single packet as it goes over the wire, before any fragmenting. Every packet carries our acks for the peer's reliable messages, so acks ride along with whatever we were sending anyway.
+type Packet struct {
+	Seq     int     // Every packet sent to a peer gets the next sequence number, which is how they measure loss.
+	Ack     int     // Every reliable message ID up to and including this one has been received.
+	AckBits uint64  // Bit n means reliable message Ack+1+n has been received too.
+	ID      int     // The reliable message ID, or 0 if the message is unreliable.
+	Channel Channel // Which channel the reliable message is on.
+	Order   int     // For reliable messages on ordered channels, its place in the channel.
+	Message Message // May be nil for packets that only carry acks.
+}
+
+// Channel separates reliable messages so that waiting on one channel's order doesn't hold up any other. Messages on UnorderedChannel are handed over as soon as they arrive, while those on any other channel are held back until everything sent before them on the same channel has been handed over.
+type Channel uint8
+
+// UnorderedChannel is where SendReliable puts messages.
+const UnorderedChannel Channel = 0
+
+const (
+	// ackWindow is how far past the last contiguous ack we can track. We never send reliable messages further ahead than that, as the peer would have to drop them.
+	ackWindow = 64
+	// maxPending is how many reliable messages can wait on a peer, sent or not. Past that, new ones are turned away until the peer catches up, so one that keeps acking just enough to stay connected can't have us hold on to everything forever. The busiest waves send a little over a thousand a second, so a blip doesn't come close, and a peer that's really gone is lost to the timeout well before.
+	maxPending = 64 * ackWindow
+	// ackDelay is how long we wait for something to piggyback our acks on before sending them on their own.
+	ackDelay = 20 * time.Millisecond
+	// Resend timeouts. Before we've measured anything, we resend like we always have.
+	initialRTO = 500 * time.Millisecond
+	minRTO     = 100 * time.Millisecond
+	maxRTO     = 5 * time.Second
+	// statsAlpha is how much each sample moves the loss estimates.
+	statsAlpha = 0.05
+)
+
+// Stats are how the connection to a peer is doing, for showing off in a network HUD.
+type Stats struct {
+	RTT          time.Duration // Smoothed round trip time, from acks of reliable messages.
+	RTO          time.Duration // How long we currently wait before resending a reliable message.
+	Loss         float64       // Estimated fraction of our reliable sends that had to be resent.
+	IncomingLoss float64       // Estimated fraction of their packets that never arrived.
+	Sent         int           // Packets sent, not counting resends.
+	Received     int           // Packets received.
+	Resent       int           // Reliable messages resent.
+	Pending      int           // Reliable messages still waiting on an ack, including those waiting to be sent.
+}
+
+// reliability is the state of a peer's reliable channel, from both directions.
+type reliability struct {
+	seq       int
+	messageID int
+	orders    map[Channel]int // The last order sent on each ordered channel.
+	sending   []*outgoing     // Reliable messages waiting on an ack, by ID.
+
+	remoteSeq  int
+	ack        int
+	ackBits    uint64
+	ackPending time.Time                   // When we first owed the peer an ack, zero if we don't.
+	nextOrders map[Channel]int             // The next order to hand over on each ordered channel.
+	held       map[Channel]map[int]Message // Messages that arrived ahead of their turn.
+
+	srtt, rttvar, rto time.Duration
+	stats             Stats
+}
+
+// outgoing is a reliable message that hasn't been acked yet.
+type outgoing struct {
+	id         int
+	channel    Channel
+	order      int
+	msg        Message
+	packet     []byte // Nil until it fits in the ack window. Resends are the exact same packet, so a big message only needs its missing fragments to make it through.
+	fragmentID int
+	sent       time.Time
+	lastSent   time.Time
+	attempts   int
+}
+
+// header fills in the packet's sequence number and our acks.
+func (r *reliability) header(pk *Packet) {
+	r.seq++
+	pk.Seq = r.seq
+	pk.Ack = r.ack
+	pk.AckBits = r.ackBits
+	r.ackPending = time.Time{}
+	r.stats.Sent++
+}
+
+// nextOrder returns the order for the next reliable message sent on the channel.
+func (r *reliability) nextOrder(ch Channel) int {
+	if ch == UnorderedChannel {
+		return 0
+	}
+	if r.orders == nil {
+		r.orders = make(map[Channel]int)
+	}
+	r.orders[ch]++
+	return r.orders[ch]
+}
+
+// receive takes in a packet from the peer, returning whichever messages are ready to be handed over.
+func (r *reliability) receive(pk Packet, t time.Time) []Message {
+	r.stats.Received++
+	if pk.Seq > r.remoteSeq {
+		// Anything we skipped over was probably lost. If it shows up late it'll count as received, so this errs a little high.
+		for i := r.remoteSeq + 1; i < pk.Seq; i++ {
+			r.stats.IncomingLoss += (1 - r.stats.IncomingLoss) * statsAlpha
+		}
+		r.stats.IncomingLoss -= r.stats.IncomingLoss * statsAlpha
+		r.remoteSeq = pk.Seq
+	}
+
+	r.acked(pk.Ack, pk.AckBits, t)
+
+	if pk.ID == 0 {
+		if pk.Message == nil {
+			return nil
+		}
+		return []Message{pk.Message}
+	}
+
+	// Ack it, even if we've seen it before, as our last ack may have been lost.
+	if r.ackPending.IsZero() {
+		r.ackPending = t
+	}
+	if pk.ID <= r.ack {
+		return nil
+	}
+	bit := pk.ID - r.ack - 1
+	if bit >= ackWindow {
+		return nil
+	}
+	if r.ackBits&(1<<bit) != 0 {
+		return nil
+	}
+	r.ackBits |= 1 << bit
+	for r.ackBits&1 != 0 {
+		r.ack++
+		r.ackBits >>= 1
+	}
+
+	if pk.Channel == UnorderedChannel {
+		if pk.Message == nil {
+			return nil
+		}
+		return []Message{pk.Message}
+	}
+	return r.order(pk.Channel, pk.Order, pk.Message)
+}
+
+// order holds the message back until its turn on the channel, returning it along with any that were waiting on it.
+func (r *reliability) order(ch Channel, order int, msg Message) (ready []Message) {
+	if r.nextOrders == nil {
+		r.nextOrders = make(map[Channel]int)
+		r.held = make(map[Channel]map[int]Message)
+	}
+	next, ok := r.nextOrders[ch]
+	if !ok {
+		next = 1
+	}
+	if order != next {
+		if order > next {
+			if r.held[ch] == nil {
+				r.held[ch] = make(map[int]Message)
+			}
+			r.held[ch][order] = msg
+		}
+		return nil
+	}
+	if msg != nil {
+		ready = append(ready, msg)
+	}
+	next++
+	for {
+		m, ok := r.held[ch][next]
+		if !ok {
+			break
+		}
+		delete(r.held[ch], next)
+		if m != nil {
+			ready = append(ready, m)
+		}
+		next++
+	}
+	r.nextOrders[ch] = next
+	return ready
+}
+
+// acked drops any of our reliable messages that the peer has acked, measuring the round trip on the way.
+func (r *reliability) acked(ack int, bits uint64, t time.Time) {
+	sending := r.sending[:0]
+	for _, o := range r.sending {
+		if o.packet == nil {
+			// Can't have been acked if it was never sent.
+			sending = append(sending, o)
+			continue
+		}
+		got := o.id <= ack
+		if bit := o.id - ack - 1; !got && bit >= 0 && bit < ackWindow {
+			got = bits&(1<<bit) != 0
+		}
+		if !got {
+			sending = append(sending, o)
+			continue
+		}
+		// Only time messages sent once, as there's no telling which send a resent one's ack was for.
+		if o.attempts == 1 {
+			r.sampleRTT(t.Sub(o.sent))
+			r.stats.Loss -= r.stats.Loss * statsAlpha
+		} else {
+			for i := 1; i < o.attempts; i++ {
+				r.stats.Loss += (1 - r.stats.Loss) * statsAlpha
+			}
+		}
+	}
+	for i := len(sending); i < len(r.sending); i++ {
+		r.sending[i] = nil
+	}
+	r.sending = sending
+}
+
+// sampleRTT updates our round trip estimate and resend timeout, the same way TCP does.
+func (r *reliability) sampleRTT(rtt time.Duration) {
+	if r.srtt == 0 {
+		r.srtt = rtt
+		r.rttvar = rtt / 2
+	} else {
+		diff := r.srtt - rtt
+		if diff < 0 {
+			diff = -diff
+		}
+		r.rttvar = (3*r.rttvar + diff) / 4
+		r.srtt = (7*r.srtt + rtt) / 8
+	}
+	r.rto = r.srtt + 4*r.rttvar
+	if r.rto < minRTO {
+		r.rto = minRTO
+	} else if r.rto > maxRTO {
+		r.rto = maxRTO
+	}
+}
+
+// resendTimeout returns how long to wait before resending the message, doubling with each attempt.
+func (r *reliability) resendTimeout(o *outgoing) time.Duration {
+	rto := r.rto
+	if rto == 0 {
+		rto = initialRTO
+	}
+	for i := 1; i < o.attempts && rto < maxRTO; i++ {
+		rto *= 2
+	}
+	if rto > maxRTO {
+		rto = maxRTO
+	}
+	return rto
+}
+
+// queueReliable queues up a reliable message for the peer, sending it right away if it fits in the ack window. The lock must be held.
+func (c *Connection) queueReliable(p *Peer, ch Channel, msg Message) error {
+	if p.disconnected {
+		// They get the whole world again when they come back, so there's no point holding on to it.
+		return nil
+	}
+	if len(p.sending) >= maxPending {
+		// Leave losing them to the timeout. Whatever they miss out on here shows up in the game's checksums, which gets them a fresh snapshot.
+		return fmt.Errorf("too many reliable messages waiting on %s", p.Name)
+	}
+	p.messageID++
+	p.sending = append(p.sending, &outgoing{
+		id:      p.messageID,
+		channel: ch,
+		order:   p.nextOrder(ch),
+		msg:     msg,
+	})
+	return c.flushReliables(p, time.Now())
+}
+
+// dropReliables empties out the reliable messages waiting on the peer, freeing up whatever they held. They still get sent empty, as the peer can't get past their IDs and orders without them. The lock must be held.
+func (r *reliability) dropReliables() {
+	for _, o := range r.sending {
+		o.msg = nil
+		o.packet = nil
+	}
+}
+
+// flushReliables sends any queued reliable messages that now fit in the ack window. The lock must be held.
+func (c *Connection) flushReliables(p *Peer, t time.Time) (err error) {
+	if len(p.sending) == 0 {
+		return nil
+	}
+	oldest := p.sending[0].id
+	for _, o := range p.sending {
+		if o.id >= oldest+ackWindow {
+			break
+		}
+		if o.packet != nil {
+			continue
+		}
+		bytes, serr := c.sendPacket(p, Packet{
+			ID:      o.id,
+			Channel: o.channel,
+			Order:   o.order,
+			Message: o.msg,
+		})
+		if bytes == nil {
+			// It can't be encoded, so send it empty rather than have the peer wait on its ID and order forever.
+			fmt.Println("dropping reliable message", serr)
+			o.msg = nil
+			bytes, _ = c.sendPacket(p, Packet{ID: o.id, Channel: o.channel, Order: o.order})
+		}
+		if serr != nil {
+			// It'll go out with the resends.
+			err = serr
+		}
+		o.packet = bytes
+		o.fragmentID = p.fragmentID
+		o.sent = t
+		o.lastSent = t
+		o.attempts = 1
+	}
+	return err
+}
+
+// updateReliables resends any reliable messages that have gone unacked for too long, sends any that now fit in the ack window, and sends any acks that have been waiting too long for a ride. The lock must be held.
+func (c *Connection) updateReliables(t time.Time) {
+	for _, p := range c.peers {
+		for _, o := range p.sending {
+			if o.packet == nil || t.Sub(o.lastSent) < p.resendTimeout(o) {
+				continue
+			}
+			c.writeFragments(p, o.packet, o.fragmentID)
+			o.lastSent = t
+			o.attempts++
+			p.stats.Resent++
+		}
+		c.flushReliables(p, t)
+		if !p.ackPending.IsZero() && t.Sub(p.ackPending) >= ackDelay {
+			c.sendPacket(p, Packet{})
+		}
+	}
+}
+
+// Stats returns how the connection to the named peer is doing.
+func (c *Connection) Stats(name string) (Stats, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, p := range c.peers {
+		if p.Name == name {
+			s := p.stats
+			s.RTT = p.srtt
+			s.RTO = p.rto
+			if s.RTO == 0 {
+				s.RTO = initialRTO
+			}
+			s.Pending = len(p.sending)
+			return s, true
+		}
+	}
+	return Stats{}, false
+}
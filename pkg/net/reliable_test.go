@@ -0,0 +1,201 @@
+package net
+
+import (
+	"math/rand"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testLink is a host and a client joined over loopback. Rather than running their loops, the test moves packets between them itself, so that it can lose some on the way.
+type testLink struct {
+	t            *testing.T
+	host, client *Connection
+	loss         float64 // The chance of any one datagram going missing.
+	rand         *rand.Rand
+	buf          []byte
+}
+
+func newTestLink(t *testing.T) *testLink {
+	t.Helper()
+	l := &testLink{
+		t:    t,
+		rand: rand.New(rand.NewSource(1)),
+		buf:  make([]byte, maxDatagramSize),
+	}
+	host, client := NewConnection("host"), NewConnection("client")
+	l.host, l.client = &host, &client
+	l.host.hosting = true
+	for _, c := range []*Connection{l.host, l.client} {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		c.conn = conn
+	}
+	// As if they had said henlo.
+	l.host.peers = []*Peer{{Name: "client", address: l.addr(l.client), codec: BinaryCodec{}, connected: true, greeted: true}}
+	l.client.peers = []*Peer{{Name: "host", address: l.addr(l.host), codec: BinaryCodec{}, connected: true, greeted: true}}
+	return l
+}
+
+func (l *testLink) addr(c *Connection) *net.UDPAddr {
+	return c.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// deliver hands whatever has arrived at the connection over to it, minus what the link loses.
+func (l *testLink) deliver(c *Connection) {
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+		n, from, err := c.conn.ReadFromUDP(l.buf)
+		if err != nil {
+			return
+		}
+		if l.rand.Float64() < l.loss {
+			continue
+		}
+		c.handlePacket(append([]byte{}, l.buf[:n]...), from, false)
+	}
+}
+
+// step does what both loops would do once: resend, ack, and receive.
+func (l *testLink) step() {
+	for _, c := range []*Connection{l.host, l.client} {
+		c.lock.Lock()
+		c.updateReliables(time.Now())
+		c.lock.Unlock()
+	}
+	l.deliver(l.client)
+	l.deliver(l.host)
+}
+
+// receive steps the link until the client has received n messages or it's been too long.
+func (l *testLink) receive(n int) (got []Message) {
+	l.t.Helper()
+	deadline := time.Now().Add(20 * time.Second)
+	for len(got) < n && time.Now().Before(deadline) {
+		l.step()
+		got = append(got, l.client.Messages()...)
+	}
+	return got
+}
+
+func TestReliableLoss(t *testing.T) {
+	l := newTestLink(t)
+
+	// The link goes down for a while, during which the host queues up far more than fits in the ack window.
+	l.loss = 1
+	const sent = 300
+	for i := 0; i < sent; i++ {
+		if err := l.host.SendOrdered(1, TravelMessage{Destination: strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+		if i%50 == 0 {
+			l.step()
+		}
+	}
+	// Then it comes back, if a little flaky.
+	l.loss = 0.1
+	got := l.receive(sent)
+	l.step()
+	got = append(got, l.client.Messages()...)
+
+	if len(got) != sent {
+		t.Fatalf("got %d messages, want %d", len(got), sent)
+	}
+	for i, m := range got {
+		if m, ok := m.(TravelMessage); !ok || m.Destination != strconv.Itoa(i) {
+			t.Fatalf("message %d is %+v", i, m)
+		}
+	}
+	if l.host.Disconnected() {
+		t.Error("host gave up on the client")
+	}
+	st, _ := l.host.Stats("client")
+	if st.Resent == 0 {
+		t.Error("nothing was resent, so nothing was lost")
+	}
+}
+
+func TestReliableBackpressure(t *testing.T) {
+	l := newTestLink(t)
+	l.loss = 1
+	for i := 0; i < maxPending; i++ {
+		if err := l.host.SendReliable(PingMessage{}); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+	}
+	if err := l.host.SendReliable(PingMessage{}); err == nil {
+		t.Error("queued a message past maxPending")
+	}
+	if l.host.Disconnected() {
+		t.Error("host gave up on the client instead of waiting on them")
+	}
+}
+
+func TestAckWindow(t *testing.T) {
+	var r reliability
+	now := time.Now()
+	received := func(id int) bool {
+		return len(r.receive(Packet{ID: id, Message: PingMessage{}}, now)) > 0
+	}
+
+	// Everything but the first, so the acks can't move yet. The last one just fits.
+	for id := 2; id <= ackWindow; id++ {
+		if !received(id) {
+			t.Fatalf("message %d wasn't handed over", id)
+		}
+	}
+	if r.ack != 0 || r.ackBits != ^uint64(0)-1 {
+		t.Fatalf("got ack %d and bits %064b", r.ack, r.ackBits)
+	}
+	// Past the window, so it's dropped for now.
+	if received(ackWindow + 1) {
+		t.Error("message past the ack window was handed over")
+	}
+	// The first one rolls everything up.
+	if !received(1) {
+		t.Fatal("message 1 wasn't handed over")
+	}
+	if r.ack != ackWindow || r.ackBits != 0 {
+		t.Fatalf("got ack %d and bits %064b, want %d and none", r.ack, r.ackBits, ackWindow)
+	}
+	// Repeats, old and new, aren't handed over again.
+	if received(1) || received(ackWindow) {
+		t.Error("repeated message was handed over again")
+	}
+	// The one past the window now fits, and a gap after the next shows up in the bits.
+	if !received(ackWindow+1) || !received(ackWindow+3) {
+		t.Fatal("messages in the new window weren't handed over")
+	}
+	if r.ack != ackWindow+1 || r.ackBits != 1<<1 {
+		t.Errorf("got ack %d and bits %064b", r.ack, r.ackBits)
+	}
+}
+
+func TestAcked(t *testing.T) {
+	var r reliability
+	now := time.Now()
+	for id := 1; id <= ackWindow+3; id++ {
+		r.sending = append(r.sending, &outgoing{id: id, packet: []byte{1}, sent: now, attempts: 1})
+	}
+	// Acks up to 2, plus 4 and the very last bit, which is ackWindow+2.
+	r.acked(2, 1<<1|1<<(ackWindow-1), now)
+	var left []int
+	for _, o := range r.sending {
+		left = append(left, o.id)
+	}
+	if len(left) != ackWindow-1 {
+		t.Fatalf("%d left waiting on acks, want %d", len(left), ackWindow-1)
+	}
+	if left[0] != 3 || left[1] != 5 || left[len(left)-1] != ackWindow+3 {
+		t.Errorf("wrong messages left waiting on acks: %v", left)
+	}
+	for _, id := range left {
+		if id == ackWindow+2 {
+			t.Errorf("message %d was acked by the last bit but is still waiting", id)
+		}
+	}
+}
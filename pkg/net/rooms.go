@@ -0,0 +1,125 @@
+package net
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+// Room is a game being hosted through the handshaker, so that others can find it without needing to know the host's name.
+type Room struct {
+	ID       int    `json:"id"` // Given out by the handshaker.
+	Title    string `json:"t"`
+	Map      string `json:"m"`
+	Players  int    `json:"p"`
+	Password bool   `json:"pw"`
+}
+
+// MaxRoomTitle is the longest a room's title can be, so a room always fits in a single datagram.
+const MaxRoomTitle = 32
+
+// ListRoomsSize is how big a request for the room list is. The handshaker answers those it doesn't know with no more than they sent, so it's padded out to the biggest room list there is.
+const ListRoomsSize = 1200
+
+// HostRoom is AwaitHandshake for hosting, also listing our game as a room with the handshaker.
+func (c *Connection) HostRoom(handshaker string, local string, title string, mapName string) error {
+	if len(title) > MaxRoomTitle {
+		title = title[:MaxRoomTitle]
+	}
+	c.lock.Lock()
+	c.room = &Room{
+		Title: title,
+		Map:   mapName,
+	}
+	c.lock.Unlock()
+	return c.AwaitHandshake(handshaker, local, "")
+}
+
+// JoinRoom is AwaitHandshake for joining one of the handshaker's rooms.
+func (c *Connection) JoinRoom(handshaker string, local string, id int) error {
+	c.roomID = id
+	return c.AwaitHandshake(handshaker, local, "")
+}
+
+// SetRoomMap changes the map our room, or LAN broadcast, says we're playing.
+func (c *Connection) SetRoomMap(mapName string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.room != nil {
+		c.room.Map = mapName
+	}
+	if c.lanGame != nil {
+		c.lanGame.Map = mapName
+	}
+}
+
+// sendRoom tells the handshaker about our room, if we have one.
+func (c *Connection) sendRoom() error {
+	c.lock.Lock()
+	if c.room == nil {
+		c.lock.Unlock()
+		return nil
+	}
+	room := *c.room
+	room.Password = c.password != nil
+	room.Players = 1
+	for _, p := range c.peers {
+		if p.connected {
+			room.Players++
+		}
+	}
+	c.lock.Unlock()
+
+	b, err := json.Marshal(room)
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.WriteTo(FormatHandshake(RoomMessage, string(b)), c.handshakerAddr)
+	return err
+}
+
+// ListRooms asks the handshaker for the rooms it knows of.
+func ListRooms(handshaker string) ([]Room, error) {
+	handshakerAddr, err := net.ResolveUDPAddr("udp", handshaker)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Padded out so that we're sent the whole list.
+	request := FormatHandshake(ListRoomsMessage, "")
+	request = append(request, strings.Repeat("-", ListRoomsSize-len(request))...)
+	if _, err := conn.WriteTo(request, handshakerAddr); err != nil {
+		return nil, err
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buffer := make([]byte, maxDatagramSize)
+	for {
+		n, fromAddr, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			return nil, err
+		}
+		if !sameAddr(fromAddr, handshakerAddr) {
+			continue
+		}
+		a, fields, err := ParseHandshake(buffer[:n])
+		if err != nil || a != RoomsMessage {
+			// Most likely the handshaker saying it's alive.
+			continue
+		}
+		if len(fields) == 0 {
+			return nil, errors.New("empty room list")
+		}
+		var rooms []Room
+		if err := json.Unmarshal([]byte(fields[0]), &rooms); err != nil {
+			return nil, err
+		}
+		return rooms, nil
+	}
+}
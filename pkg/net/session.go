@@ -0,0 +1,270 @@
+package net
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Peers that both have a session key seal everything they send each other after saying henlo. Henlos carry an ephemeral public key that's used to agree on the session key, and if there's a lobby password it's mixed into the key and proves the henlo came from someone who knows it. The proof covers both sides' keys, so it has to be made for whoever it's sent to and can't be replayed to anyone else. Once a session has started, only henlos sealed under it can change its key. Without a password this keeps out eavesdroppers and anyone trying to take over a session, while with one nobody without it can get in or spoof anything.
+
+// sealedMark starts every sealed datagram. It can't be mistaken for a fragment, a codec version, a JSON object, or a handshake.
+const sealedMark = 0xFE
+
+// sealedHeaderSize is the mark plus the counter the nonce is made from.
+const sealedHeaderSize = 1 + 8
+
+// sealedOverhead is how much bigger sealing makes a datagram, GCM's tag included.
+const sealedOverhead = sealedHeaderSize + 16
+
+// replayWindow is how far behind the newest counter a sealed datagram can be before we assume it's a replay.
+const replayWindow = 64
+
+// passwordSalt and passwordIterations are for turning the lobby password into a key. Both sides need the same ones, so changing them breaks compatibility.
+var passwordSalt = []byte("magnet lobby password")
+
+const passwordIterations = 4096
+
+// session is an established session with a peer.
+type session struct {
+	theirKey []byte
+	send     cipher.AEAD
+	recv     cipher.AEAD
+	counter  uint64 // The last counter we sealed with.
+	highest  uint64 // The highest counter we've opened.
+	seen     uint64 // Bit n means highest-1-n has been opened too.
+}
+
+// sessionKey is our half of the key exchange. Each peer gets their own.
+type sessionKey struct {
+	private []byte
+	public  []byte
+}
+
+func newSessionKey() (*sessionKey, error) {
+	private := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(private); err != nil {
+		return nil, err
+	}
+	public, err := curve25519.X25519(private, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	return &sessionKey{
+		private: private,
+		public:  public,
+	}, nil
+}
+
+// keyFor returns our half of the key exchange with the peer, making it if need be. It is nil if we couldn't make one, in which case they get no session. The lock must be held.
+func (c *Connection) keyFor(p *Peer) *sessionKey {
+	if p.key == nil && !c.noSessions {
+		key, err := newSessionKey()
+		if err != nil {
+			fmt.Println("failed to make session key, traffic with", p.address.String(), "won't be encrypted", err)
+			c.noSessions = true
+			return nil
+		}
+		p.key = key
+	}
+	return p.key
+}
+
+// newSession works out the session key with a peer from their public key. Each direction gets its own key, so both sides can count their nonces up from zero.
+func newSession(ours *sessionKey, theirs []byte, password []byte) (*session, error) {
+	secret, err := curve25519.X25519(ours.private, theirs)
+	if err != nil {
+		return nil, errors.New("bad session key")
+	}
+
+	// Both sides need to come up with the same info, so go by whose key is lower.
+	first, second := ours.public, theirs
+	if bytes.Compare(first, second) > 0 {
+		first, second = second, first
+	}
+	info := append([]byte("magnet session"), first...)
+	info = append(info, second...)
+	keys := make([]byte, 64)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, password, info), keys); err != nil {
+		return nil, err
+	}
+
+	lower, upper := keys[:32], keys[32:]
+	sendKey, recvKey := lower, upper
+	if bytes.Compare(ours.public, theirs) > 0 {
+		sendKey, recvKey = upper, lower
+	}
+	s := &session{theirKey: append([]byte{}, theirs...)}
+	if s.send, err = newAEAD(sendKey); err != nil {
+		return nil, err
+	}
+	if s.recv, err = newAEAD(recvKey); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// nonce turns a counter into a GCM nonce.
+func nonce(counter uint64) []byte {
+	n := make([]byte, 12)
+	binary.BigEndian.PutUint64(n[4:], counter)
+	return n
+}
+
+// seal seals a datagram.
+func (s *session) seal(b []byte) []byte {
+	s.counter++
+	out := make([]byte, sealedHeaderSize, sealedHeaderSize+len(b)+s.send.Overhead())
+	out[0] = sealedMark
+	binary.BigEndian.PutUint64(out[1:], s.counter)
+	return s.send.Seal(out, nonce(s.counter), b, out[:sealedHeaderSize])
+}
+
+// open opens a sealed datagram, refusing anything that was tampered with or that we've already opened.
+func (s *session) open(b []byte) ([]byte, error) {
+	if len(b) < sealedHeaderSize || b[0] != sealedMark {
+		return nil, errors.New("not a sealed datagram")
+	}
+	counter := binary.BigEndian.Uint64(b[1:])
+	if counter == 0 || counter+replayWindow <= s.highest {
+		return nil, errors.New("sealed datagram too old")
+	}
+	if counter <= s.highest && (counter == s.highest || s.seen&(1<<(s.highest-counter-1)) != 0) {
+		return nil, errors.New("sealed datagram replayed")
+	}
+	plain, err := s.recv.Open(nil, nonce(counter), b[sealedHeaderSize:], b[:sealedHeaderSize])
+	if err != nil {
+		return nil, err
+	}
+	// Only authentic datagrams get to move the window.
+	if counter > s.highest {
+		shift := counter - s.highest
+		if shift >= 64 {
+			s.seen = 0
+		} else {
+			s.seen <<= shift
+		}
+		if s.highest != 0 && shift <= 64 {
+			s.seen |= 1 << (shift - 1)
+		}
+		s.highest = counter
+	} else {
+		s.seen |= 1 << (s.highest - counter - 1)
+	}
+	return plain, nil
+}
+
+// SetPassword sets the lobby password. Peers must have the same one to connect, and everything sent between them is encrypted with it. An empty password lets anyone in, encrypting only with peers that support it.
+func (c *Connection) SetPassword(password string) {
+	if password == "" {
+		c.password = nil
+		return
+	}
+	c.password = pbkdf2.Key([]byte(password), passwordSalt, passwordIterations, 32, sha256.New)
+}
+
+// henlo returns a henlo from us. Our half of the key exchange and the password proof are filled in by sign, as they differ for each peer.
+func (c *Connection) henlo(greeting string) HenloMessage {
+	return HenloMessage{
+		Name:      c.Name,
+		Greeting:  greeting,
+		Spectator: c.Spectator,
+		Codec:     c.codecVersion(),
+	}
+}
+
+// sign adds our half of the key exchange with the peer to a henlo, along with proof that we know the password if there is one. The proof can only be made once we've heard their key. The lock must be held.
+func (c *Connection) sign(p *Peer, m *HenloMessage) {
+	m.Key, m.Proof = nil, nil
+	key := c.keyFor(p)
+	if key == nil {
+		return
+	}
+	m.Key = key.public
+	if c.password != nil && p.theirKey != nil {
+		m.Proof = c.proof(m.Key, p.theirKey, m.Name)
+	}
+}
+
+// proof proves that we know the password, tied to the sender's key, the receiver's key, and the sender's name.
+func (c *Connection) proof(from, to []byte, name string) []byte {
+	mac := hmac.New(sha256.New, c.password)
+	mac.Write([]byte("magnet henlo"))
+	for _, b := range [][]byte{from, to, []byte(name)} {
+		var n [binary.MaxVarintLen64]byte
+		mac.Write(n[:binary.PutUvarint(n[:], uint64(len(b)))])
+		mac.Write(b)
+	}
+	return mac.Sum(nil)
+}
+
+// awaitingProof returns if the henlo was turned away only because it came without a password proof, most likely as they hadn't heard our key yet. Our henlo gives it to them.
+func (c *Connection) awaitingProof(m HenloMessage) bool {
+	return c.password != nil && len(m.Key) != 0 && len(m.Proof) == 0
+}
+
+// startSession sets up or refreshes the session with the peer from their henlo, returning false if they shouldn't be let in. Once there's a session, only a henlo sealed under it may change its key. The lock must be held.
+func (c *Connection) startSession(p *Peer, m HenloMessage, sealed bool) bool {
+	ours := c.keyFor(p)
+	if c.password != nil {
+		if c.awaitingProof(m) {
+			// Don't let anyone swap out the key of a peer we already let in.
+			if !p.greeted {
+				p.theirKey = append([]byte{}, m.Key...)
+			}
+			return false
+		}
+		if len(m.Key) == 0 || ours == nil || !hmac.Equal(m.Proof, c.proof(m.Key, ours.public, m.Name)) {
+			fmt.Println("wrong or missing password from", p.address.String())
+			return false
+		}
+	}
+	if len(m.Key) == 0 || ours == nil {
+		// They can't do sessions, which is fine so long as we never had one with them.
+		return p.session == nil
+	}
+	if p.session != nil {
+		if bytes.Equal(p.session.theirKey, m.Key) {
+			return true
+		}
+		if !sealed {
+			// Anyone can send an unsealed henlo from their address, so only someone already in the session gets to replace it.
+			return false
+		}
+	}
+	p.theirKey = append([]byte{}, m.Key...)
+	s, err := newSession(ours, m.Key, c.password)
+	if err != nil {
+		fmt.Println("failed to start session with", p.address.String(), err)
+		return false
+	}
+	p.session = s
+	return true
+}
+
+// writeDatagram sends a single datagram to the peer, sealed if we have a session with them. The lock must be held.
+func (c *Connection) writeDatagram(p *Peer, b []byte) error {
+	if p.session != nil {
+		b = p.session.seal(b)
+	}
+	return c.writeRaw(b, p.address)
+}
@@ -0,0 +1,153 @@
+package net
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// testSessions returns both ends of a session.
+func testSessions(t *testing.T) (a, b *session) {
+	t.Helper()
+	keyA, err := newSessionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyB, err := newSessionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, err = newSession(keyA, keyB.public, nil); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = newSession(keyB, keyA.public, nil); err != nil {
+		t.Fatal(err)
+	}
+	return a, b
+}
+
+func TestSealedDatagrams(t *testing.T) {
+	a, b := testSessions(t)
+	var sealed [][]byte
+	for i := 0; i < 4; i++ {
+		sealed = append(sealed, a.seal([]byte{byte(i)}))
+	}
+
+	// Out of order is fine, once each.
+	for _, i := range []int{1, 0, 3, 2} {
+		plain, err := b.open(sealed[i])
+		if err != nil {
+			t.Fatalf("datagram %d: %v", i, err)
+		}
+		if !bytes.Equal(plain, []byte{byte(i)}) {
+			t.Errorf("datagram %d opened to %v", i, plain)
+		}
+	}
+	for i := range sealed {
+		if _, err := b.open(sealed[i]); err == nil {
+			t.Errorf("replayed datagram %d was opened", i)
+		}
+	}
+
+	// Nothing opens once it's been touched, header included.
+	fresh := a.seal([]byte("fresh"))
+	for i := range fresh {
+		tampered := append([]byte{}, fresh...)
+		tampered[i] ^= 1
+		if _, err := b.open(tampered); err == nil {
+			t.Errorf("datagram tampered with at byte %d was opened", i)
+		}
+	}
+	// A bad one with a counter far ahead mustn't push the good ones out of the window.
+	ahead := append([]byte{}, fresh...)
+	ahead[1] = 0xFF
+	b.open(ahead)
+	if _, err := b.open(fresh); err != nil {
+		t.Errorf("datagram after a forged one: %v", err)
+	}
+
+	// Far enough behind the newest, it's too old to tell apart from a replay.
+	old := a.seal([]byte("old"))
+	for i := 0; i < replayWindow; i++ {
+		if _, err := b.open(a.seal(nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := b.open(old); err == nil {
+		t.Error("datagram from before the replay window was opened")
+	}
+
+	// Each direction has its own key, and only the session it came from can open it.
+	if _, err := a.open(a.seal([]byte("to myself"))); err == nil {
+		t.Error("opened our own datagram")
+	}
+	_, other := testSessions(t)
+	if _, err := other.open(a.seal([]byte("elsewhere"))); err == nil {
+		t.Error("another session opened our datagram")
+	}
+}
+
+// greetLink has the client say henlo to the host and get one back, as their loops would.
+func greetLink(t *testing.T, l *testLink) {
+	t.Helper()
+	for _, p := range append(l.host.peers, l.client.peers...) {
+		p.greeted = false
+		p.codec = JSONCodec{}
+	}
+	l.client.Send(l.client.henlo("hai"))
+	l.deliver(l.host)
+	l.deliver(l.client)
+	if !l.client.peers[0].greeted || l.host.peers[0].session == nil || l.client.peers[0].session == nil {
+		t.Fatal("client and host didn't start a session")
+	}
+}
+
+func TestSessionHijack(t *testing.T) {
+	l := newTestLink(t)
+	greetLink(t, l)
+	p := l.host.peers[0]
+	session := p.session
+
+	// Someone else says henlo with their own key from the client's address, which is as far as they can get without the session.
+	key, err := newSessionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := l.client.henlo("hai")
+	m.Key = key.public
+	data, _ := json.Marshal(m)
+	forged, _ := json.Marshal(jsonPacket{TypedMessage: TypedMessage{Type: HenloMessageType, Data: data}})
+	l.host.handlePacket(forged, l.addr(l.client), false)
+	if p.session != session || !bytes.Equal(p.theirKey, session.theirKey) {
+		t.Fatal("unsealed henlo replaced the client's session")
+	}
+
+	// A henlo sealed under the session can change it.
+	sealed, _ := json.Marshal(jsonPacket{TypedMessage: TypedMessage{Type: HenloMessageType, Data: data}, Seq: 100})
+	l.host.handlePacket(l.client.peers[0].session.seal(sealed), l.addr(l.client), false)
+	if p.session == session || !bytes.Equal(p.theirKey, key.public) {
+		t.Error("sealed henlo didn't change the client's session")
+	}
+}
+
+func TestSessionTraffic(t *testing.T) {
+	l := newTestLink(t)
+	greetLink(t, l)
+
+	// Everything after the henlos is sealed, so an unsealed message from the client's address is dropped.
+	data, _ := json.Marshal(TravelMessage{Destination: "forged"})
+	forged, _ := json.Marshal(jsonPacket{TypedMessage: TypedMessage{Type: TravelMessageType, Data: data}, Seq: 100})
+	l.host.handlePacket(forged, l.addr(l.client), false)
+	l.client.SendReliable(TravelMessage{Destination: "real"})
+	l.deliver(l.host)
+
+	var got []Message
+	for _, m := range l.host.Messages() {
+		if _, ok := m.(PeerJoinedMessage); !ok {
+			got = append(got, m)
+		}
+	}
+	if len(got) != 1 || got[0].(TravelMessage).Destination != "real" {
+		t.Errorf("host got %+v, want only the real message", got)
+	}
+}
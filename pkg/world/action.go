@@ -24,6 +24,12 @@ type EntityActionMove struct {
 	Y float64 `json:"y"`
 	// distance represents the distance from the target that should be considered valid.
 	Distance float64 `json:"d"`
+	// Sprint moves the entity faster.
+	Sprint bool `json:"s"`
+	// Player is who the host is relaying this for, empty if it's the sender's own.
+	Player string `json:"pl,omitempty"`
+	// Seq numbers the player's inputs, for prediction.
+	Seq int `json:"q,omitempty"`
 	// relative represents if the movement is considered as relative to the entity's current position. Should this even be a thing?
 	relative bool
 	//
@@ -73,6 +79,10 @@ type EntityActionShoot struct {
 	Polarity data.Polarity `json:"p"`
 	complete bool
 	Next     EntityAction `json:"n"`
+	// Player is who the host is relaying this for, empty if it's the sender's own.
+	Player string `json:"pl,omitempty"`
+	// Seq numbers the player's inputs, for prediction.
+	Seq int `json:"q,omitempty"`
 }
 
 func (a *EntityActionShoot) Replaceable() bool {
@@ -111,7 +121,7 @@ func init() {
 		json.Unmarshal(data, &m)
 		return m
 	})
-	net.AddTypedMessage(203, func(data json.RawMessage) net.Message {
+	net.AddTypedMessage(201, func(data json.RawMessage) net.Message {
 		var m EntityActionPlace
 		json.Unmarshal(data, &m)
 		return m
@@ -1,6 +1,6 @@
 package world
 
-import "github.com/hajimehoshi/ebiten/v2"
+import "github.com/kettek/ebijam22/pkg/engine"
 
 // Animation manages updating and iterating through a slice of images.
 type Animation struct {
@@ -10,11 +10,11 @@ type Animation struct {
 	rotation  float64
 	index     int
 	mirror    bool
-	images    []*ebiten.Image
+	images    []*engine.Image
 }
 
 // Image returns the current image frame.
-func (a *Animation) Image() *ebiten.Image {
+func (a *Animation) Image() *engine.Image {
 	return a.images[a.index]
 }
 
@@ -42,8 +42,8 @@ func (a *Animation) Iterate() {
 }
 
 // Draw draws the current animation image to screen.
-func (a *Animation) Draw(screen *ebiten.Image, op *ebiten.DrawImageOptions) {
-	aop := &ebiten.DrawImageOptions{}
+func (a *Animation) Draw(screen *engine.Image, op *engine.DrawImageOptions) {
+	aop := &engine.DrawImageOptions{}
 
 	aop.GeoM.Translate(
 		-float64(a.Image().Bounds().Dx())/2,
@@ -72,7 +72,7 @@ func (a *Animation) Draw(screen *ebiten.Image, op *ebiten.DrawImageOptions) {
 	screen.DrawImage(a.Image(), aop)
 }
 
-func NewAnimation(images []*ebiten.Image, frameTime, speed float64) *Animation {
+func NewAnimation(images []*engine.Image, frameTime, speed float64) *Animation {
 	return &Animation{
 		images:    images,
 		frameTime: frameTime,
@@ -0,0 +1,174 @@
+package world
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+
+	"github.com/kettek/ebijam22/pkg/net"
+)
+
+// How many checksums in a row have to mismatch before we consider ourselves desynced. Positions are only synced every so often and messages take time to arrive, so a single mismatch isn't anything to worry about.
+const desyncThreshold = 3
+
+// How many of our own checksums are kept to compare the host's against, as theirs arrive for ticks we've already passed.
+const checksumHistory = 8
+
+// StateChecksum is a hash of the world's state. The host sends it every ChecksumRate ticks, and the client sends its own back if they've stopped matching.
+type StateChecksum struct {
+	Tick int    `json:"t"` // The sender's world tick. Clients take theirs from the host's snapshot, so both sides count the same ticks.
+	Hash uint32 `json:"h"`
+	Rate int    `json:"r,omitempty"` // The host's ChecksumRate, so clients keep theirs on the same ticks.
+}
+
+func (r StateChecksum) Type() net.TypedMessageType {
+	return 314
+}
+
+func init() {
+	net.AddTypedMessage(314, func(data json.RawMessage) net.Message {
+		var m StateChecksum
+		json.Unmarshal(data, &m)
+		return m
+	})
+}
+
+// ChecksumLines returns the lines of state that make up the checksum, sorted so that both sides produce the same order. Only networked entities are included, minus projecticles as they come and go too quickly. Positions are rounded to the nearest cell so small drift between syncs doesn't count.
+func (w *World) ChecksumLines() []string {
+	var lines []string
+	lines = append(lines, fmt.Sprintf("wave %d", w.CurrentWave))
+	for _, pl := range w.Game.Players() {
+		lines = append(lines, fmt.Sprintf("points %s %d", pl.Name, pl.Points))
+	}
+	for _, e := range w.entities {
+		if e.NetID() == 0 || e.Trashed() || e.IsProjectile() {
+			continue
+		}
+		health := 0
+		if e, ok := e.(*EnemyEntity); ok {
+			health = e.health
+		}
+		x, y := w.GetClosestCellPosition(int(math.Round(e.Physics().X)), int(math.Round(e.Physics().Y)))
+		lines = append(lines, fmt.Sprintf("entity %d %d,%d %d", e.NetID(), x, y, health))
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// Checksum hashes the world's current state. See ChecksumLines.
+func (w *World) Checksum() uint32 {
+	h := fnv.New32a()
+	for _, l := range w.ChecksumLines() {
+		h.Write([]byte(l))
+		h.Write([]byte{'\n'})
+	}
+	return h.Sum32()
+}
+
+// DumpChecksum prints the world's checksum and the state it is made from, for diffing against the other side's.
+func (w *World) DumpChecksum() {
+	fmt.Printf("checksum %08x at tick %d\n", w.Checksum(), w.Tick)
+	for _, l := range w.ChecksumLines() {
+		fmt.Println("\t", l)
+	}
+}
+
+// Desynced returns if our checksums have stopped matching the host's.
+func (w *World) Desynced() bool {
+	return w.desynced
+}
+
+// updateChecksum sends our checksum to the client if it is time to. Clients keep theirs on the same ticks to compare against the host's.
+func (w *World) updateChecksum() {
+	if w.Game.Net().Hosting() {
+		rate := w.Game.GetOptions().ChecksumRate
+		if rate <= 0 || w.Tick%rate != 0 {
+			return
+		}
+		w.Game.Net().Send(StateChecksum{
+			Tick: w.Tick,
+			Hash: w.Checksum(),
+			Rate: rate,
+		})
+		return
+	}
+
+	// Clients only start once the host has told us how often, and while our world is the host's.
+	rate := w.checksumRate
+	if rate <= 0 || w.awaitingSnapshot || w.Tick%rate != 0 {
+		return
+	}
+	c := StateChecksum{
+		Tick: w.Tick,
+		Hash: w.Checksum(),
+	}
+	w.checksums[(w.Tick/rate)%checksumHistory] = c
+	// The host's might have come in before we got here.
+	pending := w.pendingChecksums[:0]
+	for _, r := range w.pendingChecksums {
+		if r.Tick == w.Tick {
+			w.compareChecksum(r, c.Hash)
+		} else if r.Tick > w.Tick {
+			pending = append(pending, r)
+		}
+	}
+	w.pendingChecksums = pending
+}
+
+// CheckChecksum compares the other side's checksum with our own from the same tick.
+func (w *World) CheckChecksum(r StateChecksum) {
+	if w.Game.Net().Hosting() {
+		// The client only sends theirs when they think we've desynced.
+		fmt.Printf("client reported desync, their checksum %08x at their tick %d\n", r.Hash, r.Tick)
+		w.DumpChecksum()
+		return
+	}
+
+	if r.Rate <= 0 {
+		return
+	}
+	w.checksumRate = r.Rate
+	if w.awaitingSnapshot {
+		// Our world is about to be replaced anyway.
+		return
+	}
+	if r.Tick >= w.Tick {
+		// We haven't gotten there yet, so compare once we do. If we're that far behind, the ones we're already holding will do.
+		if len(w.pendingChecksums) < checksumHistory {
+			w.pendingChecksums = append(w.pendingChecksums, r)
+		}
+		return
+	}
+	c := w.checksums[(r.Tick/r.Rate)%checksumHistory]
+	if c.Tick != r.Tick || r.Tick%r.Rate != 0 {
+		// Too old to have kept ours, so there's nothing to compare against.
+		return
+	}
+	w.compareChecksum(r, c.Hash)
+}
+
+// compareChecksum compares the host's checksum with ours from the same tick, requesting a snapshot once we've been desynced for long enough.
+func (w *World) compareChecksum(r StateChecksum, hash uint32) {
+	if r.Hash == hash {
+		w.checksumMismatches = 0
+		w.desynced = false
+		return
+	}
+	w.checksumMismatches++
+	if w.checksumMismatches < desyncThreshold {
+		return
+	}
+	w.checksumMismatches = 0
+	w.desynced = true
+
+	fmt.Printf("desync from host, their checksum %08x and ours %08x at tick %d\n", r.Hash, hash, r.Tick)
+	w.DumpChecksum()
+	// Let the host dump theirs too.
+	w.Game.Net().SendReliable(StateChecksum{
+		Tick: w.Tick,
+		Hash: w.Checksum(),
+	})
+	w.RequestSnapshot()
+}
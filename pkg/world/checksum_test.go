@@ -0,0 +1,79 @@
+package world
+
+import "testing"
+
+// runChecksums runs a host and a client world of level 001 side by side, with the client lag ticks behind the host, or ahead if negative. The host's checksums reach the client as soon as they're made. If desync is set, the client's points drift from the host's partway through. It returns the client's tick when it noticed a desync, or -1 if it never did.
+func runChecksums(t *testing.T, lag int, desync bool) int {
+	t.Helper()
+	host := newTestSimulation(t, "001")
+	host.AutoReady = true
+	client := newTestSimulation(t, "001")
+	client.AutoReady = true
+	// As a joining client would, even though the worlds start out the same.
+	client.World.ApplySnapshot(host.World.Snapshot())
+
+	hostStart, clientStart := 0, lag
+	if lag < 0 {
+		hostStart, clientStart = -lag, 0
+	}
+	for i := 0; i < 1000; i++ {
+		if i >= clientStart {
+			if desync && client.World.Tick == desyncTick {
+				client.Players()[0].Points++
+			}
+			if err := client.Update(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if i >= hostStart {
+			tick := host.World.Tick
+			if err := host.Update(); err != nil {
+				t.Fatal(err)
+			}
+			if tick%testChecksumRate == 0 {
+				// Nothing changes after the checksum is taken but the tick.
+				client.World.CheckChecksum(StateChecksum{Tick: tick, Hash: host.World.Checksum(), Rate: testChecksumRate})
+			}
+		}
+		if client.World.Desynced() {
+			return client.World.Tick
+		}
+	}
+	return -1
+}
+
+const (
+	testChecksumRate = 10
+	desyncTick       = 200 // When the client drifts, if it does.
+)
+
+func TestChecksumDesync(t *testing.T) {
+	// Far enough apart that a few of the host's checksums are in flight at once.
+	for _, lag := range []int{0, 35, -35} {
+		if tick := runChecksums(t, lag, false); tick != -1 {
+			t.Errorf("identical worlds desynced at tick %d with the client %d ticks behind", tick, lag)
+		}
+		// Every checksum after the drift should count towards noticing it, once it arrives.
+		by := desyncTick + desyncThreshold*testChecksumRate + 1
+		if lag < 0 {
+			by -= lag
+		}
+		if tick := runChecksums(t, lag, true); tick == -1 || tick > by {
+			t.Errorf("desync noticed at tick %d with the client %d ticks behind, want by %d", tick, lag, by)
+		}
+	}
+}
+
+func TestSnapshotTick(t *testing.T) {
+	host := newTestSimulation(t, "001")
+	for i := 0; i < 50; i++ {
+		if err := host.Update(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := newTestSimulation(t, "001")
+	client.World.ApplySnapshot(host.World.Snapshot())
+	if client.World.Tick != host.World.Tick {
+		t.Errorf("client is at tick %d after the snapshot, want the host's %d", client.World.Tick, host.World.Tick)
+	}
+}
@@ -3,21 +3,21 @@ package world
 import (
 	"image/color"
 
-	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/kettek/ebijam22/pkg/engine"
 )
 
 // circles is our naughty cache of circle images.
-var circles map[int]*ebiten.Image = make(map[int]*ebiten.Image)
+var circles map[int]*engine.Image = make(map[int]*engine.Image)
 
 // getCircleImage returns an image with the outline of a circle. It generates one and caches it for future use if it does not exist.
-func getCircleImage(radius int) *ebiten.Image {
+func getCircleImage(radius int) *engine.Image {
 	if c, ok := circles[radius]; ok {
 		return c
 	}
 
 	// Bresenham, my old friend.
 	r := radius * 2
-	img := ebiten.NewImage(int(r)+1, int(r)+1)
+	img := engine.NewImage(int(r)+1, int(r)+1)
 	drawEllipsePoints := func(cx, cy, x, y int) {
 		img.Set(cx+x, cy+y, color.RGBA{127, 127, 127, 255})
 		img.Set(cx-x, cy+y, color.RGBA{127, 127, 127, 255})
@@ -56,9 +56,9 @@ func getCircleImage(radius int) *ebiten.Image {
 	return img
 }
 
-func drawCircle(screen *ebiten.Image, screenOp *ebiten.DrawImageOptions, radius int, r, g, b, a float64) {
+func drawCircle(screen *engine.Image, screenOp *engine.DrawImageOptions, radius int, r, g, b, a float64) {
 	c := getCircleImage(radius)
-	cop := &ebiten.DrawImageOptions{}
+	cop := &engine.DrawImageOptions{}
 	cop.ColorM.Scale(r, g, b, a)
 	cop.GeoM.Concat(screenOp.GeoM)
 	cop.GeoM.Translate(-float64(c.Bounds().Dx())/2, -float64(c.Bounds().Dy())/2)
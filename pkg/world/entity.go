@@ -1,7 +1,7 @@
 package world
 
 import (
-	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/kettek/ebijam22/pkg/engine"
 	"github.com/kettek/goro/pathing"
 )
 
@@ -12,7 +12,7 @@ type Entity interface {
 	Trashed() bool
 	Trash()
 	Update(world *World) (Request, error)
-	Draw(screen *ebiten.Image, op *ebiten.DrawImageOptions) // Eh, might as well allow the entities to draw themselves.
+	Draw(screen *engine.Image, op *engine.DrawImageOptions) // Eh, might as well allow the entities to draw themselves.
 	Action() EntityAction
 	SetAction(a EntityAction)
 	IsCollided(t Entity) bool
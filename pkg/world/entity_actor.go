@@ -3,10 +3,13 @@ package world
 import (
 	"math"
 
-	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/engine"
 )
 
+// sprintSpeed is how much faster sprinting moves an actor.
+const sprintSpeed = 1.5
+
 type ActorEntity struct {
 	BaseEntity
 	player          *Player
@@ -71,8 +74,8 @@ func (e *ActorEntity) Update(world *World) (request Request, err error) {
 		}
 
 		sprintMultiplier := 1.0
-		if ebiten.IsKeyPressed(ebiten.KeyShift) {
-			sprintMultiplier = 1.5
+		if a.Sprint {
+			sprintMultiplier = sprintSpeed
 		}
 
 		// FIXME: Make this use actual physics resolution!
@@ -172,9 +175,9 @@ func (e *ActorEntity) Update(world *World) (request Request, err error) {
 	return request, nil
 }
 
-func (e *ActorEntity) Draw(screen *ebiten.Image, screenOp *ebiten.DrawImageOptions) {
+func (e *ActorEntity) Draw(screen *engine.Image, screenOp *engine.DrawImageOptions) {
 	image := e.animation.Image()
-	op := &ebiten.DrawImageOptions{}
+	op := &engine.DrawImageOptions{}
 
 	// Draw from center.
 	// FIXME: We should probably use an explicit "originX" and "originY" variables.
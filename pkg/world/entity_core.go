@@ -4,8 +4,8 @@ import (
 	"fmt"
 	"image/color"
 
-	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/engine"
 )
 
 type CoreEntity struct {
@@ -36,8 +36,8 @@ func (e *CoreEntity) Update(world *World) (request Request, err error) {
 	return request, nil
 }
 
-func (e *CoreEntity) Draw(screen *ebiten.Image, screenOp *ebiten.DrawImageOptions) {
-	op := &ebiten.DrawImageOptions{}
+func (e *CoreEntity) Draw(screen *engine.Image, screenOp *engine.DrawImageOptions) {
+	op := &engine.DrawImageOptions{}
 	op.GeoM.Concat(screenOp.GeoM)
 	op.GeoM.Translate(
 		e.physics.X,
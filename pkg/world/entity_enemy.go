@@ -4,8 +4,8 @@ import (
 	"image/color"
 	"math"
 
-	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/engine"
 	"github.com/kettek/goro/pathing"
 )
 
@@ -22,6 +22,7 @@ type EnemyEntity struct {
 	victoryAnimation Animation
 	locked           bool // locked is used to lock the enemy entity when the mode changes to a loss.
 	flies            bool
+	kind             string // The enemy config this was made from, for snapshots.
 }
 
 func NewEnemyEntity(config data.EntityConfig) *EnemyEntity {
@@ -198,8 +199,8 @@ func (e *EnemyEntity) SetSteps(s []pathing.Step) {
 	e.steps = s
 }
 
-func (e *EnemyEntity) Draw(screen *ebiten.Image, screenOp *ebiten.DrawImageOptions) {
-	op := &ebiten.DrawImageOptions{}
+func (e *EnemyEntity) Draw(screen *engine.Image, screenOp *engine.DrawImageOptions) {
+	op := &engine.DrawImageOptions{}
 	op.GeoM.Concat(screenOp.GeoM)
 	op.GeoM.Translate(
 		e.physics.X,
@@ -3,8 +3,8 @@ package world
 import (
 	"math"
 
-	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/engine"
 )
 
 type OrbEntity struct {
@@ -16,7 +16,7 @@ type OrbEntity struct {
 
 func NewOrbEntity(worth int) *OrbEntity {
 	// Get our animation images.
-	var images []*ebiten.Image
+	var images []*engine.Image
 	if worth <= 3 {
 		images = data.OrbTinyImages
 	} else if worth <= 10 {
@@ -91,8 +91,8 @@ func (e *OrbEntity) Update(world *World) (request Request, err error) {
 	return
 }
 
-func (e *OrbEntity) Draw(screen *ebiten.Image, screenOp *ebiten.DrawImageOptions) {
-	op := &ebiten.DrawImageOptions{}
+func (e *OrbEntity) Draw(screen *engine.Image, screenOp *engine.DrawImageOptions) {
+	op := &engine.DrawImageOptions{}
 	op.GeoM.Concat(screenOp.GeoM)
 
 	op.GeoM.Translate(
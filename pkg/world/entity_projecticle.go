@@ -2,16 +2,15 @@ package world
 
 import (
 	"math"
-	"time"
 
-	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/engine"
 )
 
 type TouchContainer struct {
 	entity    Entity
 	count     int
-	touchTime time.Time
+	touchTick int // The projecticle's elapsed tick at the time of touching.
 }
 
 type ProjecticleEntity struct {
@@ -62,11 +61,12 @@ func (e *ProjecticleEntity) Update(world *World) (request Request, err error) {
 				t := getTouch(entity)
 				if t == nil {
 					e.physics.polarity = entity.physics.polarity
-					e.touchedEntities = append(e.touchedEntities, TouchContainer{entity, 1, time.Now()})
+					e.touchedEntities = append(e.touchedEntities, TouchContainer{entity, 1, e.elapsed})
 				}
 			} else if entity.reflector && e.IsCollided(entity) {
 				t := getTouch(entity)
-				if t == nil || time.Now().Sub(t.touchTime) >= time.Duration(50)*time.Millisecond {
+				// Wait 3 ticks, or ~50ms, before reflecting off the same turret again.
+				if t == nil || e.elapsed-t.touchTick >= 3 {
 					if math.Abs(e.physics.vX) > math.Abs(e.physics.vY) {
 						e.physics.vX = -e.physics.vX
 					} else {
@@ -74,9 +74,9 @@ func (e *ProjecticleEntity) Update(world *World) (request Request, err error) {
 					}
 					if t != nil {
 						t.count++
-						t.touchTime = time.Now()
+						t.touchTick = e.elapsed
 					} else {
-						e.touchedEntities = append(e.touchedEntities, TouchContainer{entity, 1, time.Now()})
+						e.touchedEntities = append(e.touchedEntities, TouchContainer{entity, 1, e.elapsed})
 					}
 				}
 			}
@@ -101,8 +101,8 @@ func (e *ProjecticleEntity) Update(world *World) (request Request, err error) {
 	return request, nil
 }
 
-func (e *ProjecticleEntity) Draw(screen *ebiten.Image, screenOp *ebiten.DrawImageOptions) {
-	op := &ebiten.DrawImageOptions{}
+func (e *ProjecticleEntity) Draw(screen *engine.Image, screenOp *engine.DrawImageOptions) {
+	op := &engine.DrawImageOptions{}
 	op.GeoM.Concat(screenOp.GeoM)
 	op.GeoM.Translate(
 		e.physics.X,
@@ -117,7 +117,7 @@ func (e *ProjecticleEntity) Draw(screen *ebiten.Image, screenOp *ebiten.DrawImag
 
 	length := math.Hypot(x2-x1, y2-y1)
 
-	op2 := &ebiten.DrawImageOptions{}
+	op2 := &engine.DrawImageOptions{}
 	op2.GeoM.Scale(2+length, 2)
 	op2.GeoM.Rotate(math.Atan2(y2-y1, x2-x1))
 	op2.GeoM.Translate(x1, y1)
@@ -2,10 +2,9 @@ package world
 
 import (
 	"math"
-	"math/rand"
 
-	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/engine"
 	"github.com/kettek/goro/pathing"
 )
 
@@ -20,14 +19,15 @@ type SpawnerEntity struct {
 	steps        []pathing.Step
 }
 
-func NewSpawnerEntity(p data.Polarity) *SpawnerEntity {
+// NewSpawnerEntity creates a new spawner. floatTick is used to lightly randomize its floating and should come from the world's random source.
+func NewSpawnerEntity(p data.Polarity, floatTick float64) *SpawnerEntity {
 	return &SpawnerEntity{
 		BaseEntity: BaseEntity{
 			physics: PhysicsObject{
 				polarity: p,
 			},
 		},
-		floatTick:   rand.Float64() * 60.0, // Lightly randomize that start.
+		floatTick:   floatTick,
 		shouldSpawn: true,
 	}
 }
@@ -94,15 +94,15 @@ func (e *SpawnerEntity) Update(world *World) (request Request, err error) {
 	return request, nil
 }
 
-func (e *SpawnerEntity) Draw(screen *ebiten.Image, screenOp *ebiten.DrawImageOptions) {
-	op := &ebiten.DrawImageOptions{}
+func (e *SpawnerEntity) Draw(screen *engine.Image, screenOp *engine.DrawImageOptions) {
+	op := &engine.DrawImageOptions{}
 	op.GeoM.Concat(screenOp.GeoM)
 	op.GeoM.Translate(
 		e.physics.X,
 		e.physics.Y,
 	)
 
-	var img *ebiten.Image
+	var img *engine.Image
 	if e.physics.polarity == data.NegativePolarity {
 		img, _ = data.GetImage("spawner-negative.png")
 	} else if e.physics.polarity == data.PositivePolarity {
@@ -119,7 +119,7 @@ func (e *SpawnerEntity) Draw(screen *ebiten.Image, screenOp *ebiten.DrawImageOpt
 	// Draw shadow
 	{
 		shadowImg, _ := data.GetImage("spawner-shadow.png")
-		sop := &ebiten.DrawImageOptions{}
+		sop := &engine.DrawImageOptions{}
 		sop.GeoM.Concat(op.GeoM)
 		sop.GeoM.Translate(
 			float64(shadowImg.Bounds().Dx())/2,
@@ -5,8 +5,8 @@ import (
 	"sort"
 	"strings"
 
-	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/engine"
 )
 
 type TurretEntity struct {
@@ -14,6 +14,7 @@ type TurretEntity struct {
 	target          Entity
 	colorMultiplier [3]float64 // Color multiplier, passed in when in multiplayer.
 	owner           string
+	kind            string // The turret config this was made from, for snapshots.
 	headAnimation   Animation
 	cost            int
 	showRange       bool
@@ -126,8 +127,8 @@ func (e *TurretEntity) Update(world *World) (request Request, err error) {
 	return request, nil
 }
 
-func (e *TurretEntity) Draw(screen *ebiten.Image, screenOp *ebiten.DrawImageOptions) {
-	op := &ebiten.DrawImageOptions{}
+func (e *TurretEntity) Draw(screen *engine.Image, screenOp *engine.DrawImageOptions) {
+	op := &engine.DrawImageOptions{}
 	op.GeoM.Concat(screenOp.GeoM)
 	op.GeoM.Translate(
 		e.physics.X,
@@ -151,7 +152,7 @@ func (e *TurretEntity) AcquireTarget(world *World) {
 	entities := ObjectsWithinRadius(world.enemies, e.physics.X, e.physics.Y, e.turret.attackRange)
 
 	// Sort from closest to further. This is a bit inefficient but I don't care.
-	sort.Slice(entities, func(i, j int) bool {
+	sort.SliceStable(entities, func(i, j int) bool {
 		a := GetMagnitude(GetDistanceVector(e.physics.X, e.physics.Y, entities[i].Physics().X, entities[i].Physics().Y))
 		b := GetMagnitude(GetDistanceVector(e.physics.X, e.physics.Y, entities[j].Physics().X, entities[j].Physics().Y))
 		return a < b
@@ -165,14 +166,14 @@ func (e *TurretEntity) AcquireTarget(world *World) {
 	}
 }
 
-func DrawTurret(screen *ebiten.Image, screenOp *ebiten.DrawImageOptions, bodyAnimation Animation, headAnimation Animation, polarity data.Polarity) {
-	op := &ebiten.DrawImageOptions{}
+func DrawTurret(screen *engine.Image, screenOp *engine.DrawImageOptions, bodyAnimation Animation, headAnimation Animation, polarity data.Polarity) {
+	op := &engine.DrawImageOptions{}
 	op.GeoM.Concat(screenOp.GeoM)
 	op.ColorM.Concat(screenOp.ColorM)
 
 	bodyAnimation.Draw(screen, op)
 
-	headColor := ebiten.ColorM{}
+	headColor := engine.ColorM{}
 	headColor.Concat(screenOp.ColorM)
 	headColor.Scale(data.GetPolarityColorScale(polarity))
 
@@ -180,7 +181,7 @@ func DrawTurret(screen *ebiten.Image, screenOp *ebiten.DrawImageOptions, bodyAni
 	op.GeoM.Translate(0, -5)
 	for i := float64(0); i < 3; i++ {
 		darken := .25 + i - (i / 3)
-		headOp := &ebiten.DrawImageOptions{}
+		headOp := &engine.DrawImageOptions{}
 		headOp.GeoM.Concat(op.GeoM)
 		headOp.GeoM.Translate(0, -i)
 		headOp.ColorM.Scale(darken, darken, darken, 1)
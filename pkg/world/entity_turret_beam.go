@@ -4,9 +4,8 @@ import (
 	"math"
 	"sort"
 
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/engine"
 )
 
 type TurretBeamEntity struct {
@@ -70,7 +69,7 @@ func (e *TurretBeamEntity) AcquireTarget(world *World) {
 	}
 
 	// Sort from furthest to closest, with a priority for low health targets. This is a bit inefficient but I don't care.
-	sort.Slice(entities, func(i, j int) bool {
+	sort.SliceStable(entities, func(i, j int) bool {
 		a := float64(entities[i].health)
 		b := float64(entities[j].health)
 		return a < b
@@ -84,7 +83,7 @@ func (e *TurretBeamEntity) AcquireTarget(world *World) {
 	}
 }
 
-func (e *TurretBeamEntity) Draw(screen *ebiten.Image, screenOp *ebiten.DrawImageOptions) {
+func (e *TurretBeamEntity) Draw(screen *engine.Image, screenOp *engine.DrawImageOptions) {
 	e.TurretEntity.Draw(screen, screenOp)
 
 	if e.target != nil && !e.target.Trashed() {
@@ -93,7 +92,7 @@ func (e *TurretBeamEntity) Draw(screen *ebiten.Image, screenOp *ebiten.DrawImage
 		c := data.GetPolarityColor(e.physics.polarity)
 		c.A = uint8(100 + math.Sin(float64(e.beamTick))*255)
 		// Draw that beam.
-		ebitenutil.DrawLine(screen, x+e.physics.X, y+e.physics.Y, x+e.target.Physics().X, y+e.target.Physics().Y+4, c)
+		engine.DrawLine(screen, x+e.physics.X, y+e.physics.Y, x+e.target.Physics().X, y+e.target.Physics().Y+4, c)
 	}
 
 }
@@ -1,8 +1,8 @@
 package world
 
 import (
-	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/engine"
 )
 
 type WallEntity struct {
@@ -17,7 +17,7 @@ func NewWallEntity() *WallEntity {
 		BaseEntity: BaseEntity{
 			physics: PhysicsObject{},
 			animation: Animation{
-				images: []*ebiten.Image{wallImg},
+				images: []*engine.Image{wallImg},
 			},
 		},
 		colorMultiplier: [3]float64{1, 1, 1},
@@ -28,8 +28,8 @@ func (e *WallEntity) Update(world *World) (request Request, err error) {
 	return request, nil
 }
 
-func (e *WallEntity) Draw(screen *ebiten.Image, screenOp *ebiten.DrawImageOptions) {
-	op := &ebiten.DrawImageOptions{}
+func (e *WallEntity) Draw(screen *engine.Image, screenOp *engine.DrawImageOptions) {
+	op := &engine.DrawImageOptions{}
 	op.GeoM.Concat(screenOp.GeoM)
 	op.GeoM.Translate(
 		e.physics.X,
@@ -8,6 +8,8 @@ import (
 type Game interface {
 	Players() []*Player
 	GetPlayerByName(p string) *Player
+	AddPlayer(p *Player)
+	RemovePlayer(p *Player)
 	Net() *net.Connection
 	GetOptions() *data.Options
 }
@@ -0,0 +1,44 @@
+package world
+
+import (
+	"github.com/kettek/ebijam22/pkg/engine"
+)
+
+// Input is the set of key queries the world itself makes outside of a Player's update. This lets the world be driven without a window.
+type Input interface {
+	IsKeyPressed(k engine.Key) bool
+	IsKeyJustPressed(k engine.Key) bool
+	IsKeyJustReleased(k engine.Key) bool
+}
+
+// EbitenInput passes key queries straight through to the engine package, which is ebiten outside of headless builds. This is what the world uses if no Input is set.
+type EbitenInput struct {
+}
+
+func (i EbitenInput) IsKeyPressed(k engine.Key) bool {
+	return engine.IsKeyPressed(k)
+}
+
+func (i EbitenInput) IsKeyJustPressed(k engine.Key) bool {
+	return engine.IsKeyJustPressed(k)
+}
+
+func (i EbitenInput) IsKeyJustReleased(k engine.Key) bool {
+	return engine.IsKeyJustReleased(k)
+}
+
+// NoInput never reports any keys. Used for headless simulation.
+type NoInput struct {
+}
+
+func (i NoInput) IsKeyPressed(k engine.Key) bool {
+	return false
+}
+
+func (i NoInput) IsKeyJustPressed(k engine.Key) bool {
+	return false
+}
+
+func (i NoInput) IsKeyJustReleased(k engine.Key) bool {
+	return false
+}
@@ -0,0 +1,314 @@
+package world
+
+import (
+	"encoding/json"
+	"math"
+
+	"github.com/kettek/ebijam22/pkg/net"
+)
+
+// Clients show networked entities a little in the past, at the host's tick minus the interpolation delay. If the host's samples are close enough together we just move between them, otherwise the entity dead reckons on its own and gets nudged over whenever a sample shows how far off it was. Either way, corrections are bled off over a few ticks so nothing visibly jumps.
+//
+// The local player doesn't wait on anyone. We move right away and remember where each of our inputs got us, then when the host tells us where it had us after the same input we move over by however far off we were.
+
+const (
+	// maxTrackSamples caps how many of the host's positions we hang on to for an entity.
+	maxTrackSamples = 32
+	// maxTrackHistory is how many ticks of our own positions we remember, to compare against the host's once they arrive.
+	maxTrackHistory = 120
+	// maxInterpolationGap is the furthest apart, in ticks, two of an enemy's samples can be for us to move straight between them. Any further and dead reckoning does a better job, as enemies like to turn corners. Players are synced often enough that theirs are always interpolated, see actorSyncRate.
+	maxInterpolationGap = 20
+	// trackSmoothing is how much of a correction is left after each tick.
+	trackSmoothing = 0.85
+	// reconcileEpsilon is how far off our own prediction can be before we bother correcting it.
+	reconcileEpsilon = 0.01
+)
+
+// ActorSync is where the host had a player's actor at the end of one of its ticks. Seq and Age say which of the player's inputs it was following and for how long, so that player can check their own prediction.
+type ActorSync struct {
+	Player string  `json:"pl"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Tick   int     `json:"t"`
+	Seq    int     `json:"q"`
+	Age    int     `json:"a"`
+}
+
+func (r ActorSync) Type() net.TypedMessageType {
+	return 315
+}
+
+func init() {
+	net.AddTypedMessage(315, func(data json.RawMessage) net.Message {
+		var m ActorSync
+		json.Unmarshal(data, &m)
+		return m
+	})
+}
+
+// trackSample is where the host had an entity at one of its ticks.
+type trackSample struct {
+	tick    int
+	x, y    float64
+	applied bool // Whether we've already corrected our dead reckoning with it.
+}
+
+// trackPoint is where we had an entity at one of the host's ticks, before smoothing.
+type trackPoint struct {
+	tick int
+	x, y float64
+}
+
+// netTrack is the snapshot buffer for a networked entity.
+type netTrack struct {
+	samples          []trackSample
+	history          []trackPoint
+	offsetX, offsetY float64
+}
+
+// add buffers a position from the host.
+func (t *netTrack) add(tick int, x, y float64) {
+	i := len(t.samples)
+	for i > 0 && t.samples[i-1].tick > tick {
+		i--
+	}
+	if i > 0 && t.samples[i-1].tick == tick {
+		return
+	}
+	t.samples = append(t.samples, trackSample{})
+	copy(t.samples[i+1:], t.samples[i:])
+	t.samples[i] = trackSample{tick: tick, x: x, y: y}
+	if len(t.samples) > maxTrackSamples {
+		t.samples = t.samples[len(t.samples)-maxTrackSamples:]
+	}
+}
+
+// interpolate returns where the host had the entity at the given tick, if there are samples no more than maxGap ticks apart on either side of it.
+func (t *netTrack) interpolate(tick int, maxGap int) (x, y float64, ok bool) {
+	for i := 1; i < len(t.samples); i++ {
+		a, b := t.samples[i-1], t.samples[i]
+		if tick < a.tick || tick > b.tick {
+			continue
+		}
+		if b.tick-a.tick > maxGap {
+			return 0, 0, false
+		}
+		f := float64(tick-a.tick) / float64(b.tick-a.tick)
+		return a.x + (b.x-a.x)*f, a.y + (b.y-a.y)*f, true
+	}
+	return 0, 0, false
+}
+
+// historyAt returns where we had the entity at the given tick, or the closest tick before it.
+func (t *netTrack) historyAt(tick int) (x, y float64, ok bool) {
+	for i := len(t.history) - 1; i >= 0; i-- {
+		if t.history[i].tick <= tick {
+			return t.history[i].x, t.history[i].y, true
+		}
+	}
+	return 0, 0, false
+}
+
+// update moves the entity to where it should be shown at the given tick, interpolating between samples up to maxGap ticks apart. The entity has already dead reckoned its own way along during its update.
+func (t *netTrack) update(p *PhysicsObject, tick int, maxGap int) {
+	x, y, ok := t.interpolate(tick, maxGap)
+	if !ok {
+		// Carry on from where dead reckoning got us.
+		x, y = p.X-t.offsetX, p.Y-t.offsetY
+	}
+	for i := range t.samples {
+		s := &t.samples[i]
+		if s.applied || s.tick > tick {
+			continue
+		}
+		s.applied = true
+		if ok {
+			continue
+		}
+		// See how far off we were back then, and move over by that much.
+		hx, hy, found := t.historyAt(s.tick)
+		if !found {
+			if len(t.history) > 0 {
+				// It's older than anything we remember, so it's no help.
+				continue
+			}
+			// We've only just started tracking, so take it as is.
+			hx, hy = x, y
+		}
+		dx, dy := s.x-hx, s.y-hy
+		x += dx
+		y += dy
+		for j := range t.history {
+			if t.history[j].tick >= s.tick {
+				t.history[j].x += dx
+				t.history[j].y += dy
+			}
+		}
+	}
+
+	// Only the newest sample at or before the tick is still needed to interpolate from.
+	for len(t.samples) > 1 && t.samples[1].tick <= tick {
+		t.samples = t.samples[1:]
+	}
+
+	t.offsetX = (p.X - x) * trackSmoothing
+	t.offsetY = (p.Y - y) * trackSmoothing
+	p.X = x + t.offsetX
+	p.Y = y + t.offsetY
+
+	t.history = append(t.history, trackPoint{tick: tick, x: x, y: y})
+	if len(t.history) > maxTrackHistory {
+		t.history = t.history[len(t.history)-maxTrackHistory:]
+	}
+}
+
+// prediction is where we had our own actor at the end of a tick, and which input it was following.
+type prediction struct {
+	seq, age int
+	x, y     float64
+}
+
+// applySeq notes that one of the player's inputs was just applied.
+func (p *Player) applySeq(seq int) {
+	if seq == 0 {
+		return
+	}
+	p.lastSeq = seq
+	p.seqAge = 0
+}
+
+// predict remembers where our actor ended up this tick.
+func (p *Player) predict() {
+	ph := p.Entity.Physics()
+	p.predictions = append(p.predictions, prediction{
+		seq: p.lastSeq,
+		age: p.seqAge,
+		x:   ph.X,
+		y:   ph.Y,
+	})
+	if len(p.predictions) > maxTrackHistory {
+		p.predictions = p.predictions[len(p.predictions)-maxTrackHistory:]
+	}
+}
+
+// reconcile checks where the host had our actor against where we had it after the same input, and moves it over by however far off we were.
+func (p *Player) reconcile(r ActorSync) {
+	if p.Entity == nil {
+		return
+	}
+	for i, pr := range p.predictions {
+		if pr.seq != r.Seq || pr.age != r.Age {
+			continue
+		}
+		dx, dy := r.X-pr.x, r.Y-pr.y
+		p.predictions = append(p.predictions[:0], p.predictions[i+1:]...)
+		if math.Abs(dx) < reconcileEpsilon && math.Abs(dy) < reconcileEpsilon {
+			return
+		}
+		ph := p.Entity.Physics()
+		ph.X += dx
+		ph.Y += dy
+		for j := range p.predictions {
+			p.predictions[j].x += dx
+			p.predictions[j].y += dy
+		}
+		return
+	}
+}
+
+// interpolationDelay returns how many ticks behind the host networked entities are shown.
+func (w *World) interpolationDelay() int {
+	if o := w.Game.GetOptions(); o != nil && o.InterpDelay > 0 {
+		return o.InterpDelay
+	}
+	return 0
+}
+
+// actorSyncRate returns how often, in ticks, the host sends where players' actors are. Players can change direction at any time, so they're sent often enough that there's a sample past the tick they're shown at to interpolate towards, or as often as SyncRate asks for if that is more.
+func (w *World) actorSyncRate() int {
+	rate := w.interpolationDelay() / 2
+	if sr := w.Game.GetOptions().SyncRate; sr > 0 && sr < rate {
+		rate = sr
+	}
+	if rate < 1 {
+		rate = 1
+	}
+	return rate
+}
+
+// noteHostTick keeps our idea of the host's current tick up to date.
+func (w *World) noteHostTick(tick int) {
+	if tick > w.hostTick {
+		w.hostTick = tick
+	}
+}
+
+// updatePlayerSync has the host send where every player's actor ended up this tick, and has clients remember where their own ended up. Either way, everyone's last input gets a tick older.
+func (w *World) updatePlayerSync() {
+	n := w.Game.Net()
+	rate := w.actorSyncRate()
+	for _, pl := range w.Game.Players() {
+		if pl.Entity != nil {
+			if n.Hosting() && w.Tick%rate == 0 {
+				n.Send(ActorSync{
+					Player: pl.Name,
+					X:      pl.Entity.Physics().X,
+					Y:      pl.Entity.Physics().Y,
+					Tick:   w.Tick,
+					Seq:    pl.lastSeq,
+					Age:    pl.seqAge,
+				})
+			} else if n.Active() && !n.Hosting() && pl.Local {
+				pl.predict()
+			}
+		}
+		pl.seqAge++
+	}
+}
+
+// updateTracks moves networked entities to where they should be shown. Only clients do this, as the host's positions are the real ones.
+func (w *World) updateTracks() {
+	if !w.Game.Net().Active() || w.Game.Net().Hosting() || w.hostTick == 0 {
+		return
+	}
+	w.hostTick++
+	tick := w.hostTick - w.interpolationDelay()
+
+	seen := make(map[int]bool)
+	for _, e := range w.entities {
+		if e.NetID() == 0 || e.Trashed() {
+			continue
+		}
+		if t, ok := w.tracks[e.NetID()]; ok {
+			t.update(e.Physics(), tick, maxInterpolationGap)
+			seen[e.NetID()] = true
+		}
+	}
+	for id := range w.tracks {
+		if !seen[id] {
+			delete(w.tracks, id)
+		}
+	}
+
+	// Allow for a lost sync or two before giving up on interpolating players.
+	playerGap := 3 * w.actorSyncRate()
+	for _, pl := range w.Game.Players() {
+		if !pl.Local && pl.Entity != nil && len(pl.track.samples) > 0 {
+			pl.track.update(pl.Entity.Physics(), tick, playerGap)
+		}
+	}
+}
+
+// SyncActor handles an ActorSync from the host.
+func (w *World) SyncActor(r ActorSync) {
+	pl := w.Game.GetPlayerByName(r.Player)
+	if pl == nil || pl.Entity == nil {
+		return
+	}
+	if pl.Local {
+		pl.reconcile(r)
+		return
+	}
+	w.noteHostTick(r.Tick)
+	pl.track.add(r.Tick, r.X, r.Y)
+}
@@ -0,0 +1,100 @@
+package world
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/kettek/ebijam22/pkg/net"
+)
+
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+// fill sets everything a codec would send to something other than its zero value, counting up from n so no two fields are the same.
+func fill(v reflect.Value, n *int) {
+	*n++
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(*n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(*n))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(float64(*n) + 0.25)
+	case reflect.String:
+		v.SetString(string(rune('a' + *n%26)))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Interface {
+			// Interfaces don't go over the wire.
+			return
+		}
+		if v.Type() == rawMessageType {
+			// Has to be JSON to survive the JSON codec.
+			v.SetBytes([]byte("[1]"))
+			return
+		}
+		s := reflect.MakeSlice(v.Type(), 2, 2)
+		fill(s.Index(0), n)
+		fill(s.Index(1), n)
+		v.Set(s)
+	case reflect.Map:
+		if v.Type().Elem().Kind() == reflect.Interface {
+			return
+		}
+		m := reflect.MakeMap(v.Type())
+		k := reflect.New(v.Type().Key()).Elem()
+		e := reflect.New(v.Type().Elem()).Elem()
+		fill(k, n)
+		fill(e, n)
+		m.SetMapIndex(k, e)
+		v.Set(m)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath == "" && f.Tag.Get("json") != "-" {
+				fill(v.Field(i), n)
+			}
+		}
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		fill(p.Elem(), n)
+		v.Set(p)
+	}
+}
+
+// TestMessagesRoundTrip sends every registered message through every codec. The world registers its messages on top of the net package's, so this is where all of them are.
+func TestMessagesRoundTrip(t *testing.T) {
+	for typ, handler := range net.TypedMessageMap {
+		zero := handler.Unwrap(json.RawMessage("{}"))
+		if zero == nil {
+			t.Errorf("message %d can't be made", typ)
+			continue
+		}
+		v := reflect.New(reflect.TypeOf(zero)).Elem()
+		n := 0
+		fill(v, &n)
+		msg := v.Interface().(net.Message)
+		if msg.Type() != typ {
+			t.Errorf("%T is registered as %d but says it is %d", msg, typ, msg.Type())
+			continue
+		}
+
+		for _, c := range []net.Codec{net.JSONCodec{}, net.BinaryCodec{}} {
+			pk := net.Packet{Seq: 1, ID: 2, Channel: WorldChannel, Order: 3, Message: msg}
+			b, err := c.Encode(pk)
+			if err != nil {
+				t.Errorf("codec %d: encoding %T: %v", c.Version(), msg, err)
+				continue
+			}
+			got, err := c.Decode(b)
+			if err != nil {
+				t.Errorf("codec %d: decoding %T: %v", c.Version(), msg, err)
+				continue
+			}
+			if !reflect.DeepEqual(got.Message, msg) {
+				t.Errorf("codec %d: got %+v, want %+v", c.Version(), got.Message, msg)
+			}
+		}
+	}
+}
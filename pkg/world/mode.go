@@ -4,14 +4,10 @@ import (
 	"encoding/json"
 	"image/color"
 	"math"
-	"math/rand"
 
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
-	"github.com/hajimehoshi/ebiten/v2/text"
 	"github.com/kettek/ebijam22/pkg/data"
 	"github.com/kettek/ebijam22/pkg/data/assets/lang"
+	"github.com/kettek/ebijam22/pkg/engine"
 	"github.com/kettek/ebijam22/pkg/net"
 )
 
@@ -20,7 +16,7 @@ type WorldMode interface {
 	Type() net.TypedMessageType
 	Init(w *World) error
 	Update(w *World) (WorldMode, error)
-	Draw(w *World, screen *ebiten.Image)
+	Draw(w *World, screen *engine.Image)
 	String() string
 	Local() bool
 }
@@ -48,7 +44,7 @@ func (m *PreGameMode) Update(w *World) (next WorldMode, err error) {
 	next = &BuildMode{local: true}
 	return
 }
-func (m *PreGameMode) Draw(w *World, screen *ebiten.Image) {
+func (m *PreGameMode) Draw(w *World, screen *engine.Image) {
 }
 func (m *PreGameMode) Local() bool {
 	return m.local
@@ -71,8 +67,8 @@ func (m *BuildMode) Init(w *World) error {
 	return nil
 }
 func (m *BuildMode) Update(w *World) (next WorldMode, err error) {
-	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
-		w.Game.Players()[0].ReadyForWave = true
+	if w.input().IsKeyJustPressed(engine.KeySpace) && !w.Game.Players()[0].Spectator {
+		w.QueueInput(w.Game.Players()[0], StartModeRequest{})
 		if w.Game.Net().Active() {
 			w.Game.Net().SendReliable(StartModeRequest{})
 		}
@@ -83,7 +79,7 @@ func (m *BuildMode) Update(w *World) (next WorldMode, err error) {
 	}
 	return
 }
-func (m *BuildMode) Draw(w *World, screen *ebiten.Image) {
+func (m *BuildMode) Draw(w *World, screen *engine.Image) {
 	// First draw/get pathing overlay for spawners.
 	for _, e := range w.spawners {
 		lastX, lastY := e.physics.X, e.physics.Y
@@ -92,7 +88,7 @@ func (m *BuildMode) Draw(w *World, screen *ebiten.Image) {
 			y := float64(s.Y()*data.CellHeight + data.CellHeight/2)
 			c := data.GetPolarityColor(e.physics.polarity)
 			c.A = 128
-			ebitenutil.DrawLine(screen, w.CameraX+lastX, w.CameraY+lastY, w.CameraX+float64(x), w.CameraY+float64(y), c)
+			engine.DrawLine(screen, w.CameraX+lastX, w.CameraY+lastY, w.CameraX+float64(x), w.CameraY+float64(y), c)
 			lastX = float64(x)
 			lastY = float64(y)
 		}
@@ -102,7 +98,7 @@ func (m *BuildMode) Draw(w *World, screen *ebiten.Image) {
 	pl := w.Game.Players()[0]
 	if pl.Toolbelt.activeItem != nil {
 		if pl.Toolbelt.activeItem.tool == "turret" {
-			op := &ebiten.DrawImageOptions{}
+			op := &engine.DrawImageOptions{}
 			op.GeoM.Translate(
 				w.CameraX,
 				w.CameraY,
@@ -120,7 +116,7 @@ func (m *BuildMode) Draw(w *World, screen *ebiten.Image) {
 				drawCircle(screen, op, int(cfg.AttackRange), r, g, b, a)
 			}
 		} else if pl.Toolbelt.activeItem.tool == "wall" {
-			op := &ebiten.DrawImageOptions{}
+			op := &engine.DrawImageOptions{}
 			op.GeoM.Translate(
 				w.CameraX,
 				w.CameraY,
@@ -153,7 +149,7 @@ func (m *BuildMode) Draw(w *World, screen *ebiten.Image) {
 	)
 
 	// Draw da waves previewums.
-	spawnerOp := ebiten.DrawImageOptions{}
+	spawnerOp := engine.DrawImageOptions{}
 	spawnerOp.GeoM.Translate(16, 40)
 	DrawWaves(w, screen, &spawnerOp)
 
@@ -212,10 +208,10 @@ func (m *WaveMode) Update(w *World) (next WorldMode, err error) {
 	}
 	return
 }
-func (m *WaveMode) Draw(w *World, screen *ebiten.Image) {
+func (m *WaveMode) Draw(w *World, screen *engine.Image) {
 	// Draw da waves previewums.
 	if !w.AreSpawnersHolding() {
-		spawnerOp := ebiten.DrawImageOptions{}
+		spawnerOp := engine.DrawImageOptions{}
 		spawnerOp.GeoM.Translate(16, 40)
 		DrawWaves(w, screen, &spawnerOp)
 	}
@@ -243,7 +239,7 @@ func (m *LossMode) Init(w *World) error {
 		"Argh... they've overwhelmed us and taken our crystallized embryos... we have to retreatie...",
 		"Grr... we only have a few crystallized embyros left... make the next one countie...",
 	}
-	m.flavorText = flavorTexts[rand.Int()%len(flavorTexts)]
+	m.flavorText = flavorTexts[w.Rand().Intn(len(flavorTexts))]
 
 	// Add darkened overlay to screen
 
@@ -277,11 +273,11 @@ func (m *LossMode) Init(w *World) error {
 func (m *LossMode) Update(w *World) (next WorldMode, err error) {
 	return
 }
-func (m *LossMode) Draw(w *World, screen *ebiten.Image) {
+func (m *LossMode) Draw(w *World, screen *engine.Image) {
 	// Draw the game over messages
 	lossText := "DEFEAT"
 	restartText := "press R to restartie"
-	flavorBounds := text.BoundString(data.NormalFace, m.flavorText)
+	flavorBounds := engine.BoundString(data.NormalFace, m.flavorText)
 
 	x := ScreenWidth / 2
 	y := int(float64(ScreenHeight) / 1.5)
@@ -341,17 +337,17 @@ func (m *VictoryMode) Init(w *World) error {
 		"Good work commandies, that should put them back a few paces. However we still have a bit to go...",
 		"Hah! They'll think twice before comin' round these here parts again. Let's get to the next location...",
 	}
-	m.flavorText = flavorTexts[rand.Int()%len(flavorTexts)]
+	m.flavorText = flavorTexts[w.Rand().Intn(len(flavorTexts))]
 	return nil
 }
 func (m *VictoryMode) Update(w *World) (next WorldMode, err error) {
 	return
 }
-func (m *VictoryMode) Draw(w *World, screen *ebiten.Image) {
+func (m *VictoryMode) Draw(w *World, screen *engine.Image) {
 	// Draw the victory messages
 	victoryText := "Victory"
 	nextText := "press <space bar> to continue to next level"
-	flavorBounds := text.BoundString(data.NormalFace, m.flavorText)
+	flavorBounds := engine.BoundString(data.NormalFace, m.flavorText)
 
 	x := ScreenWidth / 2
 	y := int(float64(ScreenHeight) / 1.5)
@@ -412,18 +408,18 @@ func (m *PostGameMode) Init(w *World) error {
 		"Humanity has been saved, all thanks to you!",
 		"The magnetic robot uprising has been vanquished! You may now rest easy!",
 	}
-	m.flavorText = flavorTexts[rand.Int()%len(flavorTexts)]
+	m.flavorText = flavorTexts[w.Rand().Intn(len(flavorTexts))]
 	return nil
 }
 func (m *PostGameMode) Update(w *World) (next WorldMode, err error) {
 	return
 }
-func (m *PostGameMode) Draw(w *World, screen *ebiten.Image) {
+func (m *PostGameMode) Draw(w *World, screen *engine.Image) {
 	// Draw the victory messages
 	victoryText := "Total Victory"
 	nextText := "press <space bar> to return to main menu"
 
-	flavorBounds := text.BoundString(data.NormalFace, m.flavorText)
+	flavorBounds := engine.BoundString(data.NormalFace, m.flavorText)
 
 	x := ScreenWidth / 2
 	y := int(float64(ScreenHeight) / 6)
@@ -463,6 +459,8 @@ func (m *PostGameMode) Local() bool {
 }
 
 type StartModeRequest struct {
+	// Player is who the host is relaying this for, empty if it's the sender's own.
+	Player string `json:"pl,omitempty"`
 }
 
 func (r StartModeRequest) Type() net.TypedMessageType {
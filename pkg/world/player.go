@@ -1,11 +1,11 @@
 package world
 
 import (
+	"math"
 	"sort"
 
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/engine"
 )
 
 // Player represents a player that controls an entity. It handles input and makes the entity dance.
@@ -20,18 +20,37 @@ type Player struct {
 	ReadyForWave bool
 	// Name is acquired from the initial connection name.
 	Name string
+	// ID is the player's slot, 0 being the host. It picks the player's config and color.
+	ID int
+	// Spectator players only watch. They have no entity and are never waited on.
+	Spectator bool
 	//
 	HoveringPlacement     bool
 	HoveringPlace         EntityActionPlace
 	HoverColumn, HoverRow int // X and Y hover coordinate in terms of columns/rows
 	// Current points the player has.
 	Points int
+	// Prediction and interpolation of networked movement, see interpolation.go.
+	inputSeq    int          // The last input we made.
+	lastSeq     int          // The last of the player's inputs applied to the world.
+	seqAge      int          // How many ticks ago lastSeq was applied.
+	predictions []prediction // Where we had our own actor, to check against the host.
+	track       netTrack     // Where the host had a remote player's actor.
+	// Where the host last accepted a move from the player, see ValidateMove.
+	moveEntity   Entity
+	moveX, moveY float64
+	moveTick     int
+}
+
+// PlayerConfig returns the entity config for the given player ID.
+func PlayerConfig(id int) data.EntityConfig {
+	return data.PlayerConfigs[id%len(data.PlayerConfigs)]
 }
 
 func NewPlayer() *Player {
 	// Hehehe
 	items := []*ToolbeltItem{
-		{tool: ToolGun, key: ebiten.Key1},
+		{tool: ToolGun, key: engine.Key1},
 	}
 
 	// Collect our toolbelt items.
@@ -48,14 +67,14 @@ func NewPlayer() *Player {
 	i := 2
 	for _, v := range toolbeltItems {
 		items = append(items, &ToolbeltItem{
-			tool: ToolTurret, key: ebiten.Key0 + ebiten.Key(i), polarity: data.NegativePolarity, kind: v, description: v.Description,
+			tool: ToolTurret, key: engine.Key0 + engine.Key(i), polarity: data.NegativePolarity, kind: v, description: v.Description,
 		})
 		i++
 	}
 
-	items = append(items, &ToolbeltItem{tool: ToolWall, key: ebiten.Key0 + ebiten.Key(i)})
+	items = append(items, &ToolbeltItem{tool: ToolWall, key: engine.Key0 + engine.Key(i)})
 	i++
-	items = append(items, &ToolbeltItem{tool: ToolDestroy, key: ebiten.Key0 + ebiten.Key(i)})
+	items = append(items, &ToolbeltItem{tool: ToolDestroy, key: engine.Key0 + engine.Key(i)})
 
 	return &Player{
 		Toolbelt: Toolbelt{
@@ -76,11 +95,19 @@ func (p *Player) Update(w *World) (EntityAction, error) {
 		return nil, nil
 	}
 
+	// Spectators only get to move the camera.
+	if p.Spectator {
+		if engine.IsFocused() {
+			p.updateSpectatorCamera(w)
+		}
+		return nil, nil
+	}
+
 	// FIXME: This should be only be called when the window is changed.
 	p.Toolbelt.Position()
 
 	// Do _not_ handle inputs if the window is not focused.
-	if !ebiten.IsFocused() {
+	if !engine.IsFocused() {
 		return nil, nil
 	}
 
@@ -96,7 +123,7 @@ func (p *Player) Update(w *World) (EntityAction, error) {
 
 	if p.Entity != nil {
 		var action EntityAction
-		if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		if engine.IsMouseButtonPressed(engine.MouseButtonLeft) {
 			// TODO: Show placement preview
 			p.HoveringPlacement = true
 			p.HoveringPlace = EntityActionPlace{
@@ -109,7 +136,7 @@ func (p *Player) Update(w *World) (EntityAction, error) {
 		} else if p.HoveringPlacement {
 			p.HoveringPlacement = false
 		}
-		if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonRight) {
+		if engine.IsMouseButtonJustReleased(engine.MouseButtonRight) {
 			// Right-click to delete.
 			cx, cy := w.GetCursorPosition()
 			tx, ty := w.GetClosestCellPosition(cx, cy)
@@ -124,12 +151,8 @@ func (p *Player) Update(w *World) (EntityAction, error) {
 					Tool: ToolDestroy,
 				},
 			}
-		} else if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) && p.Toolbelt.activeItem.tool == ToolGun {
-			if p.Toolbelt.activeItem.polarity == data.NeutralPolarity {
-				p.Entity.Turret().rate = p.Entity.Turret().defaultRate * 2
-			} else {
-				p.Entity.Turret().rate = p.Entity.Turret().defaultRate
-			}
+		} else if engine.IsMouseButtonPressed(engine.MouseButtonLeft) && p.Toolbelt.activeItem.tool == ToolGun {
+			p.Entity.Turret().rate = p.Entity.Turret().RateFor(p.Toolbelt.activeItem.polarity)
 			// Check if we can fire
 			cx, cy := w.GetCursorPosition()
 			if p.Entity.Turret().CanFire(w.Speed) {
@@ -140,7 +163,7 @@ func (p *Player) Update(w *World) (EntityAction, error) {
 				}
 			}
 
-		} else if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) {
+		} else if engine.IsMouseButtonJustReleased(engine.MouseButtonLeft) {
 			// Send turret placement request at the cell closest to the mouse.
 			cx, cy := w.GetCursorPosition()
 			tx, ty := w.GetClosestCellPosition(cx, cy)
@@ -165,20 +188,20 @@ func (p *Player) Update(w *World) (EntityAction, error) {
 					},
 				}
 			}
-		} else if ebiten.IsKeyPressed(ebiten.KeyA) || ebiten.IsKeyPressed(ebiten.KeyW) || ebiten.IsKeyPressed(ebiten.KeyS) || ebiten.IsKeyPressed(ebiten.KeyD) {
+		} else if engine.IsKeyPressed(engine.KeyA) || engine.IsKeyPressed(engine.KeyW) || engine.IsKeyPressed(engine.KeyS) || engine.IsKeyPressed(engine.KeyD) {
 			// Sloppy/lazy keyboard movement. FIXME: We should probably abstract this out to a keybinds system where a slice of keys can be matched to make a "command". This command would automatically be added to some sort of current commands queue that would then be used to generate the appropriate player->entity action.
 			x := 0.0
 			y := 0.0
-			if ebiten.IsKeyPressed(ebiten.KeyA) {
+			if engine.IsKeyPressed(engine.KeyA) {
 				x--
 			}
-			if ebiten.IsKeyPressed(ebiten.KeyD) {
+			if engine.IsKeyPressed(engine.KeyD) {
 				x++
 			}
-			if ebiten.IsKeyPressed(ebiten.KeyW) {
+			if engine.IsKeyPressed(engine.KeyW) {
 				y--
 			}
-			if ebiten.IsKeyPressed(ebiten.KeyS) {
+			if engine.IsKeyPressed(engine.KeyS) {
 				y++
 			}
 			action = &EntityActionMove{
@@ -187,6 +210,19 @@ func (p *Player) Update(w *World) (EntityAction, error) {
 				Distance: 0.5,
 			}
 		}
+		// Sprinting is carried with the move itself, so it is the same for remote players and replays.
+		if a, ok := action.(*EntityActionMove); ok {
+			a.Sprint = engine.IsKeyPressed(engine.KeyShift)
+		}
+		// Number our inputs so the host can tell us where each one got us.
+		switch a := action.(type) {
+		case *EntityActionMove:
+			p.inputSeq++
+			a.Seq = p.inputSeq
+		case *EntityActionShoot:
+			p.inputSeq++
+			a.Seq = p.inputSeq
+		}
 		if action != nil && (p.Entity.Action() == nil || p.Entity.Action().Replaceable()) {
 			// TODO: Add a "chainable" action field that will instead add a new action as the next action in the deepest nested next action.
 			//p.Entity.SetAction(action)
@@ -196,3 +232,26 @@ func (p *Player) Update(w *World) (EntityAction, error) {
 
 	return nil, nil
 }
+
+// updateSpectatorCamera lets spectators fly the camera around with WASD or the arrow keys, faster with shift.
+func (p *Player) updateSpectatorCamera(w *World) {
+	speed := 4.0
+	if engine.IsKeyPressed(engine.KeyShift) {
+		speed *= 2
+	}
+	if engine.IsKeyPressed(engine.KeyA) || engine.IsKeyPressed(engine.KeyLeft) {
+		w.spectateX -= speed
+	}
+	if engine.IsKeyPressed(engine.KeyD) || engine.IsKeyPressed(engine.KeyRight) {
+		w.spectateX += speed
+	}
+	if engine.IsKeyPressed(engine.KeyW) || engine.IsKeyPressed(engine.KeyUp) {
+		w.spectateY -= speed
+	}
+	if engine.IsKeyPressed(engine.KeyS) || engine.IsKeyPressed(engine.KeyDown) {
+		w.spectateY += speed
+	}
+	// Don't wander off into the void.
+	w.spectateX = math.Max(0, math.Min(w.spectateX, float64(w.width*data.CellWidth)))
+	w.spectateY = math.Max(0, math.Min(w.spectateY, float64(w.height*data.CellHeight)))
+}
@@ -3,16 +3,16 @@ package world
 import (
 	"image/color"
 
-	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/kettek/ebijam22/pkg/engine"
 )
 
 type ProgressBar struct {
-	image    *ebiten.Image
+	image    *engine.Image
 	progress float64
 }
 
 func NewProgressBar(width, height int, barColor color.RGBA) *ProgressBar {
-	image := ebiten.NewImage(width, height)
+	image := engine.NewImage(width, height)
 	image.Fill(barColor)
 	return &ProgressBar{
 		image: image,
@@ -21,8 +21,8 @@ func NewProgressBar(width, height int, barColor color.RGBA) *ProgressBar {
 func (pb *ProgressBar) Update() {
 }
 
-func (pb *ProgressBar) Draw(screen *ebiten.Image, screenOp *ebiten.DrawImageOptions) {
-	op := &ebiten.DrawImageOptions{}
+func (pb *ProgressBar) Draw(screen *engine.Image, screenOp *engine.DrawImageOptions) {
+	op := &engine.DrawImageOptions{}
 	op.GeoM.Scale(1/pb.progress, 1)
 	// Center the bar and move it up a bit.
 	op.GeoM.Translate(float64(pb.image.Bounds().Dx())/2, -float64(pb.image.Bounds().Dy()*4))
@@ -0,0 +1,145 @@
+package world
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/kettek/ebijam22/pkg/net"
+)
+
+// Replay is a recording of every player input that went into a world, stamped with the tick it was applied on. Since the world is deterministic, a replay with the same level, speed, and seed always plays out the same way.
+type Replay struct {
+	Level   string        `json:"level"`
+	Speed   float64       `json:"speed"`
+	Seed    int64         `json:"seed"`
+	Players []string      `json:"players"` // Player names, in the order of Game.Players() when recorded.
+	Events  []ReplayEvent `json:"events"`
+	index   int           // Next event to play back.
+}
+
+// ReplayEvent is a single recorded input. The input itself is stored the same way it is sent over the network.
+type ReplayEvent struct {
+	net.TypedMessage
+	Tick   int `json:"tick"`
+	Player int `json:"player"` // Index into Replay.Players.
+}
+
+// LoadReplay reads a replay from the given file.
+func LoadReplay(p string) (*Replay, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var r Replay
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	return &r, nil
+}
+
+// Save writes the replay to the given file.
+func (r *Replay) Save(p string) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p, b, 0644)
+}
+
+// Done returns if all events have been played back.
+func (r *Replay) Done() bool {
+	return r.index >= len(r.Events)
+}
+
+// playerInput is a player input waiting to be applied at the start of the next update.
+type playerInput struct {
+	player *Player
+	msg    net.Message
+}
+
+// QueueInput queues up a player's input to be applied at the start of the next world update. All player inputs go through here so that they are applied at the same point of a tick, which is what lets replays reproduce a session.
+func (w *World) QueueInput(pl *Player, msg net.Message) {
+	w.inputs = append(w.inputs, playerInput{player: pl, msg: msg})
+}
+
+// applyInputs applies our queued inputs, or the replay's inputs for this tick if we are playing one back.
+func (w *World) applyInputs() {
+	inputs := w.inputs
+	w.inputs = nil
+
+	if w.Playback != nil {
+		// Live inputs are ignored during playback.
+		inputs = nil
+		for !w.Playback.Done() && w.Playback.Events[w.Playback.index].Tick <= w.Tick {
+			ev := w.Playback.Events[w.Playback.index]
+			w.Playback.index++
+			if ev.Player < 0 || ev.Player >= len(w.Game.Players()) {
+				fmt.Println("replay event for missing player", ev.Player)
+				continue
+			}
+			inputs = append(inputs, playerInput{player: w.Game.Players()[ev.Player], msg: ev.Message()})
+		}
+	}
+
+	for _, in := range inputs {
+		if w.Recording != nil {
+			w.recordInput(in)
+		}
+		w.applyInput(in)
+	}
+}
+
+func (w *World) recordInput(in playerInput) {
+	index := -1
+	for i, pl := range w.Game.Players() {
+		if pl == in.player {
+			index = i
+			break
+		}
+	}
+	b, err := json.Marshal(in.msg)
+	if err != nil {
+		fmt.Println("failed to record input", err)
+		return
+	}
+	w.Recording.Events = append(w.Recording.Events, ReplayEvent{
+		TypedMessage: net.TypedMessage{
+			Type: in.msg.Type(),
+			Data: b,
+		},
+		Tick:   w.Tick,
+		Player: index,
+	})
+}
+
+func (w *World) applyInput(in playerInput) {
+	switch msg := in.msg.(type) {
+	case EntityActionMove:
+		w.applyInput(playerInput{player: in.player, msg: &msg})
+	case EntityActionShoot:
+		w.applyInput(playerInput{player: in.player, msg: &msg})
+	case *EntityActionMove:
+		// NOTE: A recorded move loses any nested place action when unmarshaled, same as over the net, so placements are only ever played back from their own UseToolRequest.
+		in.player.applySeq(msg.Seq)
+		if in.player.Entity != nil {
+			in.player.Entity.SetAction(msg)
+		}
+	case *EntityActionShoot:
+		in.player.applySeq(msg.Seq)
+		if in.player.Entity != nil {
+			if !in.player.Local {
+				// Other players' turrets only hear of their shots here, while ours started counting when we took it.
+				t := in.player.Entity.Turret()
+				t.Fire(t.RateFor(msg.Polarity), w.Speed)
+			}
+			in.player.Entity.SetAction(msg)
+		}
+	case UseToolRequest:
+		w.UseTool(in.player, msg)
+	case StartModeRequest:
+		in.player.ReadyForWave = true
+	default:
+		fmt.Printf("unhandled input %+v\n", msg)
+	}
+}
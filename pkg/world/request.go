@@ -59,6 +59,7 @@ type EntityPropertySync struct {
 	X, Y   float64
 	Health int `json:"h"`
 	NetID  int `json:"i"`
+	Tick   int `json:"t,omitempty"` // The host's tick, for interpolation.
 }
 
 type PointsSync struct {
@@ -0,0 +1,261 @@
+package world
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/net"
+)
+
+// SimulationStep is a single scripted input to apply at a given tick.
+type SimulationStep struct {
+	Tick  int
+	Ready bool            // Marks all players as ready for the next wave.
+	Tool  *UseToolRequest // Tool to use as the local player. Placement happens immediately, there is no walking.
+}
+
+// SimulationResult is the state of a simulation when it was stopped.
+type SimulationResult struct {
+	Seed       int64
+	Mode       string
+	CoreHealth int
+	Points     int
+	Wave       int
+	MaxWave    int
+	Ticks      int
+}
+
+// Simulation runs a World without a window, drawing, or audio. It acts as the world's Game, much like game.Game does for normal play.
+type Simulation struct {
+	World World
+	Steps []SimulationStep
+	// AutoReady readies the players whenever build mode is entered. Useful for scripts that only place things at the start.
+	AutoReady bool
+	Tick      int
+	options   data.Options
+	players   []*Player
+	net       net.Connection
+}
+
+// NewSimulation builds a new simulation from the given level. Configurations and data must already be loaded.
+func NewSimulation(level data.Level, options data.Options) (*Simulation, error) {
+	s := &Simulation{
+		options: options,
+	}
+	if s.options.Speed == 0 {
+		s.options.Speed = 1
+	}
+	if s.options.SyncRate == 0 {
+		s.options.SyncRate = 100
+	}
+
+	// Just the one player, as there is no network.
+	pl := NewPlayer()
+	pl.Name = options.Name
+	s.players = append(s.players, pl)
+
+	s.World.Game = s
+	s.World.Input = NoInput{}
+	s.World.Speed = s.options.Speed
+	if err := s.World.BuildFromLevel(level); err != nil {
+		return nil, err
+	}
+	s.World.Mode = &PreGameMode{}
+
+	return s, nil
+}
+
+func (s *Simulation) Players() []*Player {
+	return s.players
+}
+
+func (s *Simulation) GetPlayerByName(p string) *Player {
+	for _, pl := range s.players {
+		if pl.Name == p {
+			return pl
+		}
+	}
+	return nil
+}
+
+func (s *Simulation) AddPlayer(p *Player) {
+	s.players = append(s.players, p)
+}
+
+func (s *Simulation) RemovePlayer(p *Player) {
+	for i, pl := range s.players {
+		if pl == p {
+			s.players = append(s.players[:i], s.players[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *Simulation) Net() *net.Connection {
+	return &s.net
+}
+
+func (s *Simulation) GetOptions() *data.Options {
+	return &s.options
+}
+
+// Update applies any steps for the current tick and advances the world by one tick.
+func (s *Simulation) Update() error {
+	for _, step := range s.Steps {
+		if step.Tick != s.Tick {
+			continue
+		}
+		if step.Ready {
+			for _, pl := range s.players {
+				pl.ReadyForWave = true
+			}
+		}
+		if step.Tool != nil {
+			r := *step.Tool
+			r.local = true
+			s.World.ProcessRequest(r)
+		}
+	}
+
+	if _, ok := s.World.Mode.(*BuildMode); ok && s.AutoReady {
+		for _, pl := range s.players {
+			pl.ReadyForWave = true
+		}
+	}
+
+	// Players only tick their turrets, as none of them are local.
+	for _, pl := range s.players {
+		if _, err := pl.Update(&s.World); err != nil {
+			return err
+		}
+	}
+
+	if err := s.World.Update(); err != nil {
+		return err
+	}
+	s.Tick++
+	return nil
+}
+
+// Done returns if the world has reached a mode that will not progress on its own.
+func (s *Simulation) Done() bool {
+	switch s.World.Mode.(type) {
+	case *LossMode, *VictoryMode, *PostGameMode:
+		return true
+	}
+	return false
+}
+
+// Run updates the simulation until it is done or maxTicks have passed.
+func (s *Simulation) Run(maxTicks int) (SimulationResult, error) {
+	for s.Tick < maxTicks && !s.Done() {
+		if err := s.Update(); err != nil {
+			return s.Result(), err
+		}
+	}
+	return s.Result(), nil
+}
+
+// Result returns the current state of the simulation.
+func (s *Simulation) Result() SimulationResult {
+	r := SimulationResult{
+		Seed:    s.World.Seed,
+		Mode:    s.World.Mode.String(),
+		Wave:    s.World.CurrentWave,
+		MaxWave: s.World.MaxWave,
+		Ticks:   s.Tick,
+	}
+	for _, c := range s.World.cores {
+		if c.health > 0 {
+			r.CoreHealth += c.health
+		}
+	}
+	for _, pl := range s.players {
+		r.Points += pl.Points
+	}
+	return r
+}
+
+// ParseSimulationScript reads simulation steps from the given reader. Each line is a tick followed by a command:
+//
+//	<tick> ready
+//	<tick> turret <kind> <x> <y> [positive|negative|neutral]
+//	<tick> wall <x> <y>
+//	<tick> destroy <x> <y>
+//
+// Empty lines and lines starting with '#' are ignored.
+func ParseSimulationScript(r io.Reader) (steps []SimulationStep, err error) {
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		t := strings.TrimSpace(scanner.Text())
+		if len(t) == 0 || t[0] == '#' {
+			continue
+		}
+		parts := strings.Fields(t)
+		if len(parts) < 2 {
+			return steps, fmt.Errorf("%d: missing command", line)
+		}
+		var step SimulationStep
+		if step.Tick, err = strconv.Atoi(parts[0]); err != nil {
+			return steps, fmt.Errorf("%d: bad tick: %w", line, err)
+		}
+		args := parts[2:]
+		switch parts[1] {
+		case "ready":
+			step.Ready = true
+		case "turret":
+			if len(args) < 3 {
+				return steps, fmt.Errorf("%d: turret needs a kind, x, and y", line)
+			}
+			step.Tool = &UseToolRequest{
+				Tool: ToolTurret,
+				Kind: args[0],
+			}
+			if step.Tool.X, step.Tool.Y, err = parseSimulationCell(args[1:]); err != nil {
+				return steps, fmt.Errorf("%d: %w", line, err)
+			}
+			if len(args) > 3 {
+				switch args[3] {
+				case "positive":
+					step.Tool.Polarity = data.PositivePolarity
+				case "negative":
+					step.Tool.Polarity = data.NegativePolarity
+				case "neutral":
+					step.Tool.Polarity = data.NeutralPolarity
+				default:
+					return steps, fmt.Errorf("%d: unknown polarity %s", line, args[3])
+				}
+			}
+		case "wall", "destroy":
+			step.Tool = &UseToolRequest{
+				Tool: ToolKind(parts[1]),
+			}
+			if step.Tool.X, step.Tool.Y, err = parseSimulationCell(args); err != nil {
+				return steps, fmt.Errorf("%d: %w", line, err)
+			}
+		default:
+			return steps, fmt.Errorf("%d: unknown command %s", line, parts[1])
+		}
+		steps = append(steps, step)
+	}
+	return steps, scanner.Err()
+}
+
+func parseSimulationCell(args []string) (x, y int, err error) {
+	if len(args) < 2 {
+		return 0, 0, fmt.Errorf("missing cell position")
+	}
+	if x, err = strconv.Atoi(args[0]); err != nil {
+		return 0, 0, err
+	}
+	if y, err = strconv.Atoi(args[1]); err != nil {
+		return 0, 0, err
+	}
+	return x, y, nil
+}
@@ -0,0 +1,96 @@
+package world
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/net"
+)
+
+// testInputs are the local player's inputs during the first build mode of level 001, by tick.
+var testInputs = map[int]net.Message{
+	1: UseToolRequest{X: 4, Y: 10, Tool: ToolTurret, Kind: "basic", Polarity: data.NegativePolarity},
+	2: UseToolRequest{X: 8, Y: 10, Tool: ToolTurret, Kind: "basic", Polarity: data.PositivePolarity},
+	3: UseToolRequest{X: 4, Y: 12, Tool: ToolTurret, Kind: "basic", Polarity: data.NegativePolarity},
+	4: UseToolRequest{X: 8, Y: 12, Tool: ToolTurret, Kind: "basic", Polarity: data.PositivePolarity},
+	5: UseToolRequest{X: 2, Y: 14, Tool: ToolWall},
+	6: StartModeRequest{},
+}
+
+// runRecorded runs level 001 until it ends or ticks pass, returning the checksum of every tick. Inputs are queued the same way the game queues them, unless the simulation is playing back a replay.
+func runRecorded(t *testing.T, replay *Replay, ticks int) ([]uint32, *Simulation) {
+	t.Helper()
+	sim := newTestSimulation(t, "001")
+	sim.World.Playback = replay
+	sim.World.Recording = &Replay{Level: "001", Seed: sim.World.Seed, Speed: sim.World.Speed}
+	var checksums []uint32
+	for sim.Tick < ticks && !sim.Done() {
+		if msg, ok := testInputs[sim.Tick]; ok {
+			sim.World.QueueInput(sim.Players()[0], msg)
+		}
+		if err := sim.Update(); err != nil {
+			t.Fatal(err)
+		}
+		checksums = append(checksums, sim.World.Checksum())
+	}
+	return checksums, sim
+}
+
+func TestSimulationDeterministic(t *testing.T) {
+	const ticks = 6000
+	first, sim := runRecorded(t, nil, ticks)
+	firstResult := sim.Result()
+	recorded := sim.World.Recording
+	if len(recorded.Events) != len(testInputs) {
+		t.Fatalf("recorded %d inputs, want %d", len(recorded.Events), len(testInputs))
+	}
+	// Make sure the inputs did something worth checking.
+	placed := 0
+	for _, e := range sim.World.entities {
+		switch e.(type) {
+		case *TurretEntity, *WallEntity:
+			placed++
+		}
+	}
+	if placed != 5 {
+		t.Fatalf("placed %d turrets and walls, want 5", placed)
+	}
+	if firstResult.Wave < 2 {
+		t.Fatalf("ended on wave %d, so the first wave never started", firstResult.Wave)
+	}
+
+	// Replays go through a file, so do the same here.
+	b, err := json.Marshal(recorded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, sim := runRecorded(t, nil, ticks)
+	secondResult := sim.Result()
+	var replay Replay
+	if err := json.Unmarshal(b, &replay); err != nil {
+		t.Fatal(err)
+	}
+	played, sim := runRecorded(t, &replay, ticks)
+	playedResult := sim.Result()
+
+	for name, run := range map[string][]uint32{"second run": second, "replay": played} {
+		if len(run) != len(first) {
+			t.Errorf("%s lasted %d ticks, want %d", name, len(run), len(first))
+			continue
+		}
+		for i := range first {
+			if run[i] != first[i] {
+				t.Errorf("%s diverged at tick %d", name, i)
+				break
+			}
+		}
+	}
+	if secondResult != firstResult {
+		t.Errorf("second run ended with %+v, want %+v", secondResult, firstResult)
+	}
+	if playedResult != firstResult {
+		t.Errorf("replay ended with %+v, want %+v", playedResult, firstResult)
+	}
+}
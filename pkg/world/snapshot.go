@@ -0,0 +1,361 @@
+package world
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/net"
+)
+
+// WorldSnapshot is the host's full view of the world. Clients rebuild their world from it whenever they may have drifted from the host, such as on join.
+type WorldSnapshot struct {
+	Tick        int              `json:"t"` // The host's tick when it was taken.
+	Mode        net.TypedMessage `json:"m"`
+	CurrentWave int              `json:"w"`
+	MaxWave     int              `json:"W"`
+	Points      map[string]int   `json:"p"`
+	Players     []string         `json:"P"` // Player names, by player ID.
+	Cores       []int            `json:"c"` // Core health, by core ID.
+	Cells       []CellSnapshot   `json:"l"`
+	Entities    []EntitySnapshot `json:"e"`
+}
+
+// CellSnapshot is an occupied cell. Cells are otherwise the same as the level's.
+type CellSnapshot struct {
+	X     int `json:"x"`
+	Y     int `json:"y"`
+	NetID int `json:"i"` // The occupying entity.
+}
+
+// EntitySnapshot is a single entity within a WorldSnapshot. Only fields relevant to the entity's Kind are set.
+type EntitySnapshot struct {
+	Kind     string        `json:"k"` // One of "actor", "enemy", "turret", "wall", "orb", or "projecticle".
+	Config   string        `json:"c,omitempty"`
+	NetID    int           `json:"i,omitempty"`
+	Polarity data.Polarity `json:"p"`
+	X        float64       `json:"x"`
+	Y        float64       `json:"y"`
+	VX       float64       `json:"vx,omitempty"`
+	VY       float64       `json:"vy,omitempty"`
+	Health   int           `json:"h,omitempty"`
+	Owner    string        `json:"o,omitempty"` // For actors this is the player's name.
+	Worth    int           `json:"w,omitempty"`
+	Damage   int           `json:"d,omitempty"`
+}
+
+// WorldSnapshotRequest is sent by the client to ask the host for a WorldSnapshot.
+type WorldSnapshotRequest struct {
+}
+
+func (r WorldSnapshot) Type() net.TypedMessageType {
+	return 312
+}
+
+func (r WorldSnapshotRequest) Type() net.TypedMessageType {
+	return 313
+}
+
+func init() {
+	net.AddTypedMessage(312, func(data json.RawMessage) net.Message {
+		var m WorldSnapshot
+		json.Unmarshal(data, &m)
+		return m
+	})
+	net.AddTypedMessage(313, func(data json.RawMessage) net.Message {
+		var m WorldSnapshotRequest
+		json.Unmarshal(data, &m)
+		return m
+	})
+}
+
+// RequestSnapshot asks the host for a WorldSnapshot. The request is repeated until one arrives.
+func (w *World) RequestSnapshot() {
+	if !w.Game.Net().Active() || w.Game.Net().Hosting() {
+		return
+	}
+	w.awaitingSnapshot = true
+	w.snapshotTimer = 0
+	w.Game.Net().SendReliable(WorldSnapshotRequest{})
+}
+
+// updateSnapshotRequest re-requests our snapshot if it has been a while, as the host may not have been in the level yet.
+func (w *World) updateSnapshotRequest() {
+	if !w.awaitingSnapshot {
+		return
+	}
+	w.snapshotTimer++
+	if w.snapshotTimer >= 120 {
+		w.snapshotTimer = 0
+		w.Game.Net().SendReliable(WorldSnapshotRequest{})
+	}
+}
+
+// Snapshot returns the current state of the world.
+func (w *World) Snapshot() WorldSnapshot {
+	s := WorldSnapshot{
+		Tick:        w.Tick,
+		CurrentWave: w.CurrentWave,
+		MaxWave:     w.MaxWave,
+		Points:      make(map[string]int),
+	}
+
+	if w.Mode != nil {
+		s.Mode.Type = w.Mode.Type()
+		s.Mode.Data, _ = json.Marshal(w.Mode)
+	}
+
+	for _, pl := range w.Game.Players() {
+		s.Points[pl.Name] = pl.Points
+		for len(s.Players) <= pl.ID {
+			s.Players = append(s.Players, "")
+		}
+		s.Players[pl.ID] = pl.Name
+	}
+
+	for _, c := range w.cores {
+		for len(s.Cores) <= c.id {
+			s.Cores = append(s.Cores, 0)
+		}
+		s.Cores[c.id] = c.health
+	}
+
+	for y, r := range w.cells {
+		for x, c := range r {
+			if c.entity != nil && !c.entity.Trashed() {
+				s.Cells = append(s.Cells, CellSnapshot{X: x, Y: y, NetID: c.entity.NetID()})
+			}
+		}
+	}
+
+	for _, e := range w.entities {
+		if e.Trashed() {
+			continue
+		}
+		es := EntitySnapshot{
+			NetID:    e.NetID(),
+			Polarity: e.Physics().polarity,
+			X:        e.Physics().X,
+			Y:        e.Physics().Y,
+		}
+		switch e := e.(type) {
+		case *ActorEntity:
+			es.Kind = "actor"
+			es.Owner = e.player.Name
+		case *EnemyEntity:
+			es.Kind = "enemy"
+			es.Config = e.kind
+			es.Health = e.health
+		case *TurretEntity:
+			es.Kind = "turret"
+			es.Config = e.kind
+			es.Owner = e.owner
+		case *TurretBeamEntity:
+			es.Kind = "turret"
+			es.Config = e.kind
+			es.Owner = e.owner
+		case *WallEntity:
+			es.Kind = "wall"
+			es.Owner = e.owner
+		case *OrbEntity:
+			es.Kind = "orb"
+			es.Worth = e.worth
+		case *ProjecticleEntity:
+			es.Kind = "projecticle"
+			es.VX = e.physics.vX
+			es.VY = e.physics.vY
+			es.Damage = e.damage
+		default:
+			// Cores and spawners are built from the level, so the client already has them.
+			continue
+		}
+		s.Entities = append(s.Entities, es)
+	}
+
+	return s
+}
+
+// syncPlayers adds any players we don't know about yet and makes sure everyone has the host's player IDs. Our own player is whichever the host named after us.
+func (w *World) syncPlayers(names []string) {
+	// Drop anyone the host doesn't have anymore.
+	others := append([]*Player{}, w.Game.Players()[1:]...)
+	for _, pl := range others {
+		found := false
+		for _, name := range names {
+			if pl.Name == name {
+				found = true
+			}
+		}
+		if !found {
+			w.RemovePlayerEntity(pl)
+			w.Game.RemovePlayer(pl)
+		}
+	}
+	for id, name := range names {
+		if name == "" {
+			continue
+		}
+		pl := w.Game.GetPlayerByName(name)
+		if name == w.Game.Net().Name {
+			pl = w.Game.Players()[0]
+			pl.Name = name
+		}
+		if pl == nil {
+			pl = NewPlayer()
+			pl.Name = name
+			pl.ID = id
+			w.Game.AddPlayer(pl)
+		}
+		if pl.ID != id {
+			// Wrong look, so out with the old.
+			w.RemovePlayerEntity(pl)
+			pl.ID = id
+		}
+		w.AddPlayerEntity(pl)
+	}
+}
+
+// ApplySnapshot rebuilds the world's networked entities, cells, points, wave, and mode from the host's snapshot.
+func (w *World) ApplySnapshot(s WorldSnapshot) {
+	w.awaitingSnapshot = false
+	// Count ticks the same as the host from here on, so that our checksums line up with theirs.
+	w.Tick = s.Tick
+	w.noteHostTick(s.Tick)
+	// Our old checksums were of the world we're throwing away, but the host's for later ticks can still be compared.
+	w.checksums = [checksumHistory]StateChecksum{}
+	pending := w.pendingChecksums[:0]
+	for _, r := range w.pendingChecksums {
+		if r.Tick >= s.Tick {
+			pending = append(pending, r)
+		}
+	}
+	w.pendingChecksums = pending
+
+	// Set the mode first, as mode inits like to poke at the wave and entities.
+	if s.Mode.Type != net.MissingMessageType && (w.Mode == nil || w.Mode.Type() != s.Mode.Type) {
+		var m WorldMode
+		switch msg := s.Mode.Message().(type) {
+		case PreGameMode:
+			m = &msg
+		case BuildMode:
+			m = &msg
+		case WaveMode:
+			m = &msg
+		case LossMode:
+			m = &msg
+		case VictoryMode:
+			m = &msg
+		case PostGameMode:
+			m = &msg
+		}
+		if m != nil {
+			w.SetMode(m)
+		}
+	}
+	w.CurrentWave = s.CurrentWave
+	w.MaxWave = s.MaxWave
+
+	w.syncPlayers(s.Players)
+	w.SyncPoints(PointsSync{Points: s.Points})
+
+	for _, c := range w.cores {
+		if c.id < len(s.Cores) {
+			c.health = s.Cores[c.id]
+			c.destroyed = c.health <= 0
+		}
+	}
+
+	// Throw out everything we'd otherwise get from the host.
+	t := w.entities[:0]
+	for _, e := range w.entities {
+		switch e.(type) {
+		case *EnemyEntity, *TurretEntity, *TurretBeamEntity, *WallEntity, *OrbEntity, *ProjecticleEntity:
+			e.Trash()
+		default:
+			t = append(t, e)
+		}
+	}
+	w.entities = t
+	w.enemies = nil
+	w.tracks = nil
+	for y := range w.cells {
+		for x := range w.cells[y] {
+			w.cells[y][x].entity = nil
+		}
+	}
+
+	// And rebuild it all.
+	byNetID := make(map[int]Entity)
+	for _, es := range s.Entities {
+		var e Entity
+		switch es.Kind {
+		case "actor":
+			if pl := w.Game.GetPlayerByName(es.Owner); pl != nil && pl.Entity != nil {
+				pl.Entity.Physics().X = es.X
+				pl.Entity.Physics().Y = es.Y
+			}
+			continue
+		case "enemy":
+			config, ok := data.EnemyConfigs[es.Config]
+			if !ok {
+				fmt.Println("snapshot has unknown enemy", es.Config)
+				continue
+			}
+			ee := NewEnemyEntity(config)
+			ee.kind = es.Config
+			ee.health = es.Health
+			w.enemies = append(w.enemies, ee)
+			e = ee
+		case "turret":
+			config, ok := data.TurretConfigs[es.Config]
+			if !ok {
+				fmt.Println("snapshot has unknown turret", es.Config)
+				continue
+			}
+			var te *TurretEntity
+			if config.AttackType == "beam" {
+				be := NewTurretBeamEntity(config)
+				te = &be.TurretEntity
+				e = be
+			} else {
+				te = NewTurretEntity(config)
+				e = te
+			}
+			te.kind = es.Config
+			te.owner = es.Owner
+			if pl := w.Game.GetPlayerByName(es.Owner); pl != nil && pl.Entity != nil {
+				te.colorMultiplier = pl.Entity.(*ActorEntity).colorMultiplier
+			}
+		case "wall":
+			we := NewWallEntity()
+			we.owner = es.Owner
+			if pl := w.Game.GetPlayerByName(es.Owner); pl != nil && pl.Entity != nil {
+				we.colorMultiplier = pl.Entity.(*ActorEntity).colorMultiplier
+			}
+			e = we
+		case "orb":
+			e = NewOrbEntity(es.Worth)
+		case "projecticle":
+			pe := NewProjecticleEntity()
+			pe.physics.vX = es.VX
+			pe.physics.vY = es.VY
+			pe.damage = es.Damage
+			e = pe
+		default:
+			fmt.Println("snapshot has unknown entity kind", es.Kind)
+			continue
+		}
+		e.SetNetID(es.NetID)
+		e.Physics().polarity = es.Polarity
+		w.PlaceEntityAt(e, es.X, es.Y)
+		byNetID[es.NetID] = e
+	}
+
+	for _, c := range s.Cells {
+		if cell := w.GetCell(c.X, c.Y); cell != nil {
+			cell.entity = byNetID[c.NetID]
+		}
+	}
+
+	w.UpdatePathing()
+}
@@ -4,10 +4,8 @@ import (
 	"fmt"
 	"image/color"
 
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
-	"github.com/hajimehoshi/ebiten/v2/text"
 	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/engine"
 )
 
 // Toolbelt is the interface for containing user actions for placing turrets and similar.
@@ -25,7 +23,7 @@ func (t *Toolbelt) Update() (request Request) {
 	}
 
 	// Might as well allow mousewheel for the plebs.
-	wheelX, wheelY := ebiten.Wheel()
+	wheelX, wheelY := engine.Wheel()
 	if wheelX < 0 || wheelY < 0 {
 		t.ScrollItem(-1)
 	} else if wheelX > 0 || wheelY > 0 {
@@ -93,7 +91,7 @@ func (t *Toolbelt) ScrollItem(dir int) {
 	}
 }
 
-func (t *Toolbelt) Draw(screen *ebiten.Image) {
+func (t *Toolbelt) Draw(screen *engine.Image) {
 	// Draw the belt slots.
 	for _, ti := range t.items {
 		ti.DrawSlot(screen)
@@ -120,7 +118,7 @@ type ToolbeltItem struct {
 	kind        data.EntityConfig
 	polarity    data.Polarity
 	x, y        int
-	key         ebiten.Key // Key to check against for activation.
+	key         engine.Key // Key to check against for activation.
 	active      bool
 	description string
 }
@@ -128,17 +126,17 @@ type ToolbeltItem struct {
 func (t *ToolbeltItem) Update() (request Request) {
 	toolSlotImage, _ := data.GetImage("toolslot.png")
 	// Does the cursor intersect us?
-	if t.active && inpututil.IsKeyJustPressed(ebiten.KeyTab) {
+	if t.active && engine.IsKeyJustPressed(engine.KeyTab) {
 		return SelectToolbeltItemRequest{t.tool}
-	} else if inpututil.IsKeyJustPressed(t.key) {
+	} else if engine.IsKeyJustPressed(t.key) {
 		return SelectToolbeltItemRequest{t.tool}
 	} else {
-		x, y := ebiten.CursorPosition()
+		x, y := engine.CursorPosition()
 		x1, x2 := t.x-toolSlotImage.Bounds().Dx()/2, t.x+toolSlotImage.Bounds().Dx()/2
 		y1, y2 := t.y-toolSlotImage.Bounds().Dy()/2, t.y+toolSlotImage.Bounds().Dy()/2
 
 		if x >= x1 && x <= x2 && y >= y1 && y <= y2 {
-			if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+			if engine.IsMouseButtonJustPressed(engine.MouseButtonLeft) {
 				return SelectToolbeltItemRequest{t.tool}
 			}
 			// Do a dummy return to prevent click through.
@@ -159,11 +157,11 @@ func (t *ToolbeltItem) Position(sx, sy *int) {
 	*sx += toolSlotImage.Bounds().Dx() + 1
 }
 
-func (t *ToolbeltItem) DrawSlot(screen *ebiten.Image) {
+func (t *ToolbeltItem) DrawSlot(screen *engine.Image) {
 	orbImage, _ := data.GetImage("orb-large.png")
 	toolSlotImage, _ := data.GetImage("toolslot.png")
 	toolSlotActiveImage, _ := data.GetImage("toolslot-active.png")
-	op := ebiten.DrawImageOptions{}
+	op := engine.DrawImageOptions{}
 	if t.active {
 		op.GeoM.Translate(float64(t.x-toolSlotActiveImage.Bounds().Dx()/2), float64(t.y-toolSlotActiveImage.Bounds().Dy()/2))
 		screen.DrawImage(toolSlotActiveImage, &op)
@@ -199,7 +197,7 @@ func (t *ToolbeltItem) DrawSlot(screen *ebiten.Image) {
 
 			// Combine labels
 			label = fmt.Sprintf("%s %s%s", label, polarity, cost)
-			textBounds := text.BoundString(data.NormalFace, label)
+			textBounds := engine.BoundString(data.NormalFace, label)
 			x := t.x - toolSlotActiveImage.Bounds().Dx()/2
 			y := t.y - toolSlotActiveImage.Bounds().Dy() + 5
 			data.DrawStaticText(
@@ -213,7 +211,7 @@ func (t *ToolbeltItem) DrawSlot(screen *ebiten.Image) {
 			)
 			x += textBounds.Dx() + 12
 			if cost != "" {
-				imageOp := ebiten.DrawImageOptions{}
+				imageOp := engine.DrawImageOptions{}
 				imageOp.GeoM.Translate(float64(t.x+textBounds.Dx()-5), float64(y-orbImage.Bounds().Dy()))
 				screen.DrawImage(orbImage, &imageOp)
 				x += orbImage.Bounds().Dx()
@@ -231,8 +229,8 @@ func (t *ToolbeltItem) DrawSlot(screen *ebiten.Image) {
 	}
 }
 
-func (t *ToolbeltItem) Draw(screen *ebiten.Image) {
-	op := ebiten.DrawImageOptions{}
+func (t *ToolbeltItem) Draw(screen *engine.Image) {
+	op := engine.DrawImageOptions{}
 
 	// Move to the center of our item.
 	op.GeoM.Translate(float64(t.x), float64(t.y))
@@ -262,8 +260,8 @@ func (t *ToolbeltItem) Cycle() {
 
 // Retrieves the image for a toolkind
 // TODO: perhaps intialize toolbelt items with these instead?
-func GetToolImage(t ToolKind, k string) *ebiten.Image {
-	var image *ebiten.Image
+func GetToolImage(t ToolKind, k string) *engine.Image {
+	var image *engine.Image
 	switch t {
 	case ToolTurret:
 		image = data.TurretConfigs[k].HeadImages[0]
@@ -1,5 +1,7 @@
 package world
 
+import "github.com/kettek/ebijam22/pkg/data"
+
 type Turret struct {
 	damage         int     // the damage of each projecticle
 	projecticleNum int     // the number of projecticles per attack
@@ -30,3 +32,22 @@ func (t *Turret) CanFire(speed float64) bool {
 	}
 	return false
 }
+
+// CanFireWithin checks if a shot at the given rate would be let through, allowing it if the counter is within slack ticks of resetting. The host uses this for other players' shots, as they arrive unevenly. It doesn't start the counter, that's left to Fire once the shot is actually taken.
+func (t *Turret) CanFireWithin(rate, slack float64) bool {
+	return t.tick == 0 || t.tick+slack >= rate*60
+}
+
+// Fire starts the counter for a shot at the given rate that was already checked.
+func (t *Turret) Fire(rate, speed float64) {
+	t.rate = rate
+	t.tick = speed
+}
+
+// RateFor returns the fire rate for shots of the given polarity. Neutral shots are slower.
+func (t *Turret) RateFor(polarity data.Polarity) float64 {
+	if polarity == data.NeutralPolarity {
+		return t.defaultRate * 2
+	}
+	return t.defaultRate
+}
@@ -0,0 +1,171 @@
+package world
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/kettek/ebijam22/pkg/data"
+	"github.com/kettek/ebijam22/pkg/data/assets/lang"
+	"github.com/kettek/ebijam22/pkg/net"
+)
+
+// The host has the final say over what other players get to do, as anyone could be on the other end of the handshaker.
+
+const (
+	// toolReach is how far, in cells, a player's actor can be from the cell it uses a tool on. Actors walk to the cell first, but can be held up by walls, so this has some leeway.
+	toolReach = 3
+	// shootSlack is how many ticks early a shot can arrive and still be let through, as network jitter bunches them up.
+	shootSlack = 6
+	// maxMoveDistance is the furthest from its target a move can be considered done. Legit moves use far less.
+	maxMoveDistance = 16
+	// moveSlack is how many ticks of walking a move can be ahead of the last one by, as jitter bunches them up and each one aims a little past the actor.
+	moveSlack = 6
+)
+
+// DenyReason is why the host turned down a player's request.
+type DenyReason int
+
+const (
+	DenyInvalid    DenyReason = iota // Nonsense, such as a turret that doesn't exist.
+	DenyWrongMode                    // Not allowed in the current mode.
+	DenyOutOfReach                   // Too far from their actor.
+	DenyPoints                       // Not enough points.
+	DenyPlacement                    // Can't be placed there.
+	DenyNotYours                     // Belongs to someone else.
+	DenyTooFast                      // Faster than the player's turret can fire or their actor can walk.
+)
+
+// RequestDenied tells a player that the host turned down one of their requests.
+type RequestDenied struct {
+	Reason  DenyReason           `json:"r"`
+	Request net.TypedMessageType `json:"t"` // The type of the request that was denied.
+}
+
+func (r RequestDenied) Type() net.TypedMessageType {
+	return 316
+}
+
+func init() {
+	net.AddTypedMessage(316, func(data json.RawMessage) net.Message {
+		var m RequestDenied
+		json.Unmarshal(data, &m)
+		return m
+	})
+}
+
+// LangCode returns the string code to show the player for the denial.
+func (r RequestDenied) LangCode() string {
+	switch r.Reason {
+	case DenyWrongMode:
+		return lang.MessageDeniedMode
+	case DenyOutOfReach:
+		return lang.MessageDeniedReach
+	case DenyPoints:
+		return lang.MessageDeniedPoints
+	case DenyPlacement:
+		return lang.MessageDeniedPlacement
+	case DenyNotYours:
+		return lang.MessageDeniedNotYours
+	case DenyTooFast:
+		return lang.MessageDeniedTooFast
+	}
+	return lang.MessageDeniedInvalid
+}
+
+// Deny lets the player know their request was turned down. Remote players get a RequestDenied, while we just get the sound.
+func (w *World) Deny(pl *Player, r net.Message, reason DenyReason) {
+	if pl == w.Game.Players()[0] {
+		data.SFX.Play("denied.ogg")
+		return
+	}
+	fmt.Printf("denied %s's %T: %d\n", pl.Name, r, reason)
+	w.Game.Net().SendReliableTo(pl.Name, RequestDenied{
+		Reason:  reason,
+		Request: r.Type(),
+	})
+}
+
+// ValidateMove checks that a player's move makes sense, and that it isn't further from their last one than their actor could have walked since. Moves that walk to a cell to use a tool there are only carried out at the actor's own speed in our world, so they aren't held to this, but later moves are measured from where the actor was when they were given.
+func (w *World) ValidateMove(pl *Player, a *EntityActionMove) (DenyReason, bool) {
+	actor, ok := pl.Entity.(*ActorEntity)
+	if !ok {
+		return DenyInvalid, false
+	}
+	if !finite(a.X) || !finite(a.Y) || !finite(a.Distance) {
+		return DenyInvalid, false
+	}
+	if a.Distance < 0 || a.Distance > maxMoveDistance {
+		return DenyInvalid, false
+	}
+	if a.X < 0 || a.Y < 0 || a.X > float64(w.width*data.CellWidth) || a.Y > float64((w.height+1)*data.CellHeight) {
+		return DenyOutOfReach, false
+	}
+	if a.Next != nil || pl.moveEntity != pl.Entity {
+		pl.moveEntity = pl.Entity
+		pl.moveX, pl.moveY, pl.moveTick = actor.physics.X, actor.physics.Y, w.Tick
+		if a.Next != nil {
+			return 0, true
+		}
+	}
+	limit := actor.speed * float64(w.Tick-pl.moveTick+moveSlack)
+	if a.Sprint {
+		limit *= sprintSpeed
+	}
+	if math.Hypot(a.X-pl.moveX, a.Y-pl.moveY) > limit {
+		return DenyTooFast, false
+	}
+	pl.moveX, pl.moveY, pl.moveTick = a.X, a.Y, w.Tick
+	return 0, true
+}
+
+// ValidateShoot checks that a player's shot makes sense and that their turret is ready to fire it. It only looks, as their turret starts counting when the shot is applied.
+func (w *World) ValidateShoot(pl *Player, a *EntityActionShoot) (DenyReason, bool) {
+	if pl.Entity == nil {
+		return DenyInvalid, false
+	}
+	if !finite(a.TargetX) || !finite(a.TargetY) {
+		return DenyInvalid, false
+	}
+	t := pl.Entity.Turret()
+	if !t.CanFireWithin(t.RateFor(a.Polarity), shootSlack) {
+		return DenyTooFast, false
+	}
+	return 0, true
+}
+
+// ValidateToolReach checks that the player's actor is close enough to the cell to use a tool on it.
+func (w *World) ValidateToolReach(pl *Player, r UseToolRequest) (DenyReason, bool) {
+	if pl.Entity == nil || w.GetCell(r.X, r.Y) == nil {
+		return DenyInvalid, false
+	}
+	x := float64(r.X)*float64(data.CellWidth) + float64(data.CellWidth)/2
+	y := float64(r.Y)*float64(data.CellHeight) + float64(data.CellHeight)/2
+	dx := (pl.Entity.Physics().X - x) / float64(data.CellWidth)
+	dy := (pl.Entity.Physics().Y - y) / float64(data.CellHeight)
+	if math.Hypot(dx, dy) > toolReach {
+		return DenyOutOfReach, false
+	}
+	return 0, true
+}
+
+// ownerAt returns the name of whoever owns the tool entity in the cell, if there is one.
+func (w *World) ownerAt(x, y int) (string, bool) {
+	c := w.GetCell(x, y)
+	if c == nil || c.entity == nil {
+		return "", false
+	}
+	switch e := c.entity.(type) {
+	case *TurretEntity:
+		return e.owner, true
+	case *TurretBeamEntity:
+		return e.owner, true
+	case *WallEntity:
+		return e.owner, true
+	}
+	return "", false
+}
+
+func finite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
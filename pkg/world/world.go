@@ -4,14 +4,13 @@ import (
 	"fmt"
 	"image/color"
 	"math"
+	"math/rand"
 	"sort"
+	"time"
 
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
-	"github.com/hajimehoshi/ebiten/v2/text"
 	"github.com/kettek/ebijam22/pkg/data"
 	"github.com/kettek/ebijam22/pkg/data/ui"
+	"github.com/kettek/ebijam22/pkg/engine"
 	"github.com/kettek/ebijam22/pkg/net"
 	"github.com/kettek/goro/pathing"
 )
@@ -20,6 +19,9 @@ import (
 var ScreenWidth int = 640
 var ScreenHeight int = 360
 
+// WorldChannel is the ordered channel the host sends everything that changes the world on, so clients apply it all in the same order the host did.
+const WorldChannel net.Channel = 1
+
 // World is a struct for our cells and entities.
 type World struct {
 	Game             Game // Ewwww x2
@@ -28,7 +30,8 @@ type World struct {
 	cells            [][]LiveCell
 	entities         []Entity
 	netIDs           int
-	trashedIDs       []int // This is a slice of trashed IDs for the current wave. This is used to ensure entities are not created if they're marked as trashed. This can happen due to out of order arrival of packets.
+	tracks           map[int]*netTrack // Snapshot buffers for networked entities, by NetID. Clients only.
+	hostTick         int               // Our best guess at the host's current tick. Clients only.
 	spawners         []*SpawnerEntity
 	enemies          []*EnemyEntity
 	actors           []*ActorEntity
@@ -47,13 +50,47 @@ type World struct {
 	Speed float64
 	//
 	backgroundTimer int
-	backgroundImage *ebiten.Image
+	backgroundImage *engine.Image
 	backgroundIndex int
 	hasNextLevel    bool
+	// Input is used for any key checks the world makes. If nil, ebiten is queried directly.
+	Input Input
+	// Our random source. Everything in the world must use this rather than the global rand, so that the same seed and inputs always produce the same ticks.
+	rand *rand.Rand
+	Seed int64
+	// Tick is the number of updates the world has gone through.
+	Tick int
+	// Player inputs waiting for the next update.
+	inputs []playerInput
+	// Recording, if set, has every applied player input appended to it.
+	Recording *Replay
+	// Playback, if set, is played back instead of any live player inputs.
+	Playback *Replay
+	// Whether we're a client waiting on a WorldSnapshot from the host, and how long we've waited.
+	awaitingSnapshot bool
+	snapshotTimer    int
+	// Client-side desync tracking, see CheckChecksum.
+	checksumMismatches int
+	desynced           bool
+	checksumRate       int                            // The host's ChecksumRate.
+	checksums          [checksumHistory]StateChecksum // Ours, by tick, for comparing the host's against.
+	pendingChecksums   []StateChecksum                // The host's, for ticks we haven't reached yet.
+	// Where players spawn.
+	playerX, playerY int
+	// Where the camera is looking if we're spectating.
+	spectateX, spectateY float64
 }
 
 // BuildFromLevel builds the world's cells and entities from a given base level.
 func (w *World) BuildFromLevel(level data.Level) error {
+	// Seed from our options if we haven't been explicitly seeded.
+	if w.rand == nil {
+		seed := w.Game.GetOptions().Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		w.SetSeed(seed)
+	}
 	w.hasNextLevel = level.Next != ""
 	tileset := level.Tileset
 	if tileset == "" {
@@ -73,51 +110,28 @@ func (w *World) BuildFromLevel(level data.Level) error {
 		for x, c := range r {
 			// Create any entities that should be there.
 			if c.Kind == data.PlayerCell {
-				// Add all players to the same spot. We _could_ adjust level parsing to have "n" and "s" for players.
-				// Only add it if we actually need to add a player.
-				for i, p := range w.Game.Players() {
-					if i > 0 && !w.Game.Net().Active() {
-						// Ignore players beyond 0 if we have no net.
-						continue
-					}
-					if p.Entity == nil {
-						c := data.PlayerInit
-						xoffset := 0
-						if i == 0 {
-							if w.Game.Net().Active() && !w.Game.Net().Hosting() {
-								c = data.Player2Init
-								xoffset = 1
-							}
-						} else if i == 1 {
-							if w.Game.Net().Active() && w.Game.Net().Hosting() {
-								c = data.Player2Init
-								xoffset = 1
-							}
-						}
-
-						fmt.Println("Adding player entity", i, c.Title)
-						e := NewActorEntity(p, c)
-						// Tie 'em together.
-						e.player = p
-						p.Entity = e
-						w.actors = append(w.actors, e)
-						// And place.
-						w.PlaceEntityInCell(e, x+xoffset, y)
-					}
+				// Add all players around the same spot. We _could_ adjust level parsing to have "n" and "s" for players.
+				w.playerX, w.playerY = x, y
+				w.spectateX = float64(x*data.CellWidth + data.CellWidth/2)
+				w.spectateY = float64(y*data.CellHeight + data.CellHeight/2)
+				for _, p := range w.Game.Players() {
+					w.AddPlayerEntity(p)
 				}
 			} else if c.Kind == data.SouthSpawnCell {
-				e := NewSpawnerEntity(data.NegativePolarity)
+				e := NewSpawnerEntity(data.NegativePolarity, w.Rand().Float64()*60.0)
 				w.PlaceEntityInCell(e, x, y)
 				w.spawners = append(w.spawners, e)
 			} else if c.Kind == data.NorthSpawnCell {
-				e := NewSpawnerEntity(data.PositivePolarity)
+				e := NewSpawnerEntity(data.PositivePolarity, w.Rand().Float64()*60.0)
 				w.PlaceEntityInCell(e, x, y)
 				w.spawners = append(w.spawners, e)
 			} else if c.Kind == data.EnemyPositiveCell {
 				e := NewEnemyEntity(data.EnemyConfigs["walker-positive"])
+				e.kind = "walker-positive"
 				w.PlaceEntityInCell(e, x, y)
 			} else if c.Kind == data.EnemyNegativeCell {
 				e := NewEnemyEntity(data.EnemyConfigs["walker-negative"])
+				e.kind = "walker-negative"
 				w.PlaceEntityInCell(e, x, y)
 			} else if c.Kind == data.CoreCell {
 				e := NewCoreEntity(data.CoreConfig)
@@ -133,7 +147,7 @@ func (w *World) BuildFromLevel(level data.Level) error {
 				kind:     c.Kind,
 				polarity: c.Polarity,
 			}
-			if c.Kind == data.BlockedCell || c.Kind == data.EmptyCell {
+			if !c.Pathable() {
 				cell.blocked = true
 			}
 			w.cells[y] = append(w.cells[y], cell)
@@ -163,16 +177,39 @@ func (w *World) BuildFromLevel(level data.Level) error {
 	return nil
 }
 
-func (w *World) ProcessNetMessage(msg net.Message) error {
+// ProcessNetMessage processes a message from the named peer. Everything the host sends on the WorldChannel arrives in the order it was sent, so an entity is always spawned before anything trashes or syncs it.
+func (w *World) ProcessNetMessage(from string, msg net.Message) error {
 	if w.Game.Net().Hosting() {
 		switch msg := msg.(type) {
 		case EntityActionMove:
-			w.Game.Players()[1].Entity.SetAction(&msg)
+			if pl := w.Game.GetPlayerByName(from); pl != nil {
+				if reason, ok := w.ValidateMove(pl, &msg); !ok {
+					w.Deny(pl, msg, reason)
+					break
+				}
+				w.QueueInput(pl, &msg)
+				msg.Player = from
+				w.Relay(from, msg)
+			}
 		case EntityActionShoot:
 			// let th' boy shoot
-			w.Game.Players()[1].Entity.SetAction(&msg)
+			if pl := w.Game.GetPlayerByName(from); pl != nil {
+				if reason, ok := w.ValidateShoot(pl, &msg); !ok {
+					w.Deny(pl, msg, reason)
+					break
+				}
+				w.QueueInput(pl, &msg)
+				msg.Player = from
+				w.Relay(from, msg)
+			}
 		case UseToolRequest:
+			// No pretending to be someone else.
+			msg.Owner = from
 			w.ProcessRequest(msg)
+		case WorldSnapshotRequest:
+			w.Game.Net().SendOrderedTo(from, WorldChannel, w.Snapshot())
+		case StateChecksum:
+			w.CheckChecksum(msg)
 		}
 	} else {
 		switch msg := msg.(type) {
@@ -184,8 +221,25 @@ func (w *World) ProcessNetMessage(msg net.Message) error {
 			w.SyncPoints(msg)
 		case EntityPropertySync:
 			w.SyncEntity(msg)
+		case ActorSync:
+			w.SyncActor(msg)
 		case EntityActionMove:
-			w.Game.Players()[1].Entity.SetAction(&msg)
+			// The host relays everyone else's, marked with who it's from.
+			name := from
+			if msg.Player != "" {
+				name = msg.Player
+			}
+			if pl := w.Game.GetPlayerByName(name); pl != nil {
+				w.QueueInput(pl, &msg)
+			}
+		case EntityActionShoot:
+			name := from
+			if msg.Player != "" {
+				name = msg.Player
+			}
+			if pl := w.Game.GetPlayerByName(name); pl != nil {
+				w.QueueInput(pl, &msg)
+			}
 		case SpawnEnemyRequest:
 			w.SpawnEnemyEntity(msg)
 		case SpawnOrbRequest:
@@ -198,6 +252,13 @@ func (w *World) ProcessNetMessage(msg net.Message) error {
 			w.ProcessRequest(msg)
 		case UseToolRequest:
 			w.HandleToolRequest(msg)
+		case WorldSnapshot:
+			w.ApplySnapshot(msg)
+		case StateChecksum:
+			w.CheckChecksum(msg)
+		case net.ReconnectedMessage:
+			// Who knows what we missed.
+			w.RequestSnapshot()
 		case BuildMode:
 			w.SetMode(&msg)
 		case WaveMode:
@@ -223,17 +284,18 @@ func (w *World) ProcessRequest(r Request) {
 		}
 	case EntityPropertySync:
 		if w.Game.Net().Hosting() {
+			r.Tick = w.Tick
 			w.Game.Net().Send(r)
 		}
 	case DamageCoreRequest:
 		if !w.Game.Net().Active() || w.Game.Net().Hosting() {
 			w.DamageCore(r)
 			if w.Game.Net().Hosting() {
-				w.Game.Net().SendReliable(r)
+				w.Game.Net().SendOrdered(WorldChannel, r)
 			}
 		}
 	case UseToolRequest:
-		// NOTE: Technically a client could just send this request and we won't do any distance checking.
+		// Clients' requests get checked over in UseTool once they're applied.
 		// Disallow tool use during wave mode.
 		if _, ok := w.Mode.(*WaveMode); ok {
 			return
@@ -244,104 +306,19 @@ func (w *World) ProcessRequest(r Request) {
 			w.Game.Net().SendReliable(r)
 			return
 		}
-		if r.Tool == ToolTurret {
-			if c := w.GetCell(r.X, r.Y); c != nil {
-				if w.IsPlacementValid(r.X, r.Y) && c.IsOpen() {
-					if r.local {
-						r.Owner = w.Game.Players()[0].Name
-					} else {
-						r.Owner = w.Game.Players()[1].Name
-					}
-
-					pl := w.Game.GetPlayerByName(r.Owner)
-					config := data.TurretConfigs[r.Kind]
-					if pl.Points >= config.Points {
-						e := w.HandleToolRequest(r)
-						if e != nil {
-							pl.Points -= config.Points
-							w.SendPlayerPoints()
-							// Let the client know to make our turret.
-							if w.Game.Net().Hosting() {
-								r.NetID = e.NetID()
-								w.Game.Net().SendReliable(r)
-							}
-						}
-					} else {
-						if !r.local {
-							w.Game.Net().SendReliable(PlaySoundRequest{
-								Sound: "denied.ogg",
-							})
-						} else {
-							data.SFX.Play("denied.ogg")
-						}
-					}
-				} else {
-					if !r.local {
-						w.Game.Net().SendReliable(PlaySoundRequest{
-							Sound: "denied.ogg",
-						})
-					} else {
-						data.SFX.Play("denied.ogg")
-					}
-				}
-			}
-		} else if r.Tool == ToolDestroy {
-			if r.local {
-				r.Owner = w.Game.Players()[0].Name
-			} else {
-				r.Owner = w.Game.Players()[1].Name
-			}
-			w.HandleToolRequest(r)
-			if w.Game.Net().Hosting() {
-				w.Game.Net().SendReliable(r)
-			}
-		} else if r.Tool == ToolWall {
-			if r.local {
-				r.Owner = w.Game.Players()[0].Name
-			} else {
-				r.Owner = w.Game.Players()[1].Name
-			}
-			c := w.GetCell(r.X, r.Y)
-			if c != nil {
-				if w.IsPlacementValid(r.X, r.Y) && c.IsOpen() {
-					pl := w.Game.GetPlayerByName(r.Owner)
-					if pl.Points >= 3 {
-						e := w.HandleToolRequest(r)
-						if e != nil {
-
-							pl.Points -= 3
-							w.SendPlayerPoints()
-
-							if w.Game.Net().Hosting() {
-								r.NetID = e.NetID()
-								w.Game.Net().SendReliable(r)
-							}
-						}
-					} else {
-						if !r.local {
-							w.Game.Net().SendReliable(PlaySoundRequest{
-								Sound: "denied.ogg",
-							})
-						} else {
-							data.SFX.Play("denied.ogg")
-						}
-					}
-				} else {
-					if !r.local {
-						w.Game.Net().SendReliable(PlaySoundRequest{
-							Sound: "denied.ogg",
-						})
-					} else {
-						data.SFX.Play("denied.ogg")
-					}
-				}
-			}
+		// Queue it up with the rest of the player inputs.
+		pl := w.Game.Players()[0]
+		if !r.local {
+			pl = w.Game.GetPlayerByName(r.Owner)
+		}
+		if pl != nil {
+			w.QueueInput(pl, r)
 		}
 	case SpawnProjecticleRequest:
 		if !w.Game.Net().Active() || w.Game.Net().Hosting() {
 			e := w.SpawnProjecticleEntity(r)
 			if w.Game.Net().Active() && w.Game.Net().Hosting() {
-				w.Game.Net().SendReliable(SpawnProjecticleRequest{
+				w.Game.Net().SendOrdered(WorldChannel, SpawnProjecticleRequest{
 					X:        r.X,
 					Y:        r.Y,
 					VX:       r.VX,
@@ -357,7 +334,7 @@ func (w *World) ProcessRequest(r Request) {
 			e := w.SpawnEnemyEntity(r)
 			// Hmm.
 			if w.Game.Net().Active() && w.Game.Net().Hosting() {
-				w.Game.Net().SendReliable(SpawnEnemyRequest{
+				w.Game.Net().SendOrdered(WorldChannel, SpawnEnemyRequest{
 					X:        r.X,
 					Y:        r.Y,
 					Polarity: r.Polarity,
@@ -372,7 +349,7 @@ func (w *World) ProcessRequest(r Request) {
 			// Hmm.
 			if w.Game.Net().Active() && w.Game.Net().Hosting() {
 				r.NetID = e.NetID()
-				w.Game.Net().SendReliable(r)
+				w.Game.Net().SendOrdered(WorldChannel, r)
 			}
 		}
 	case CollectOrbRequest:
@@ -395,12 +372,10 @@ func (w *World) ProcessRequest(r Request) {
 				}
 			}
 			if w.Game.Net().Hosting() {
-				w.Game.Net().SendReliable(r)
+				w.Game.Net().SendOrdered(WorldChannel, r)
 			}
 		} else if w.Game.Net().Active() {
 			if !r.local {
-				// Mark it as trashed.
-				w.trashedIDs = append(w.trashedIDs, r.NetID)
 				for _, e := range w.entities {
 					if e.NetID() == r.NetID {
 						e.Trash()
@@ -418,6 +393,147 @@ func (w *World) ProcessRequest(r Request) {
 	}
 }
 
+// UseTool uses a tool as the given player, if they can afford it and the placement is valid. This is only done by the host or solo. Other players are held to a few more checks, and told why if they fail them.
+func (w *World) UseTool(pl *Player, r UseToolRequest) {
+	local := pl == w.Game.Players()[0]
+	// The mode may have changed since this was queued.
+	if _, ok := w.Mode.(*WaveMode); ok {
+		if !local {
+			w.Deny(pl, r, DenyWrongMode)
+		}
+		return
+	}
+	r.Owner = pl.Name
+	if !local {
+		if reason, ok := w.ValidateToolReach(pl, r); !ok {
+			w.Deny(pl, r, reason)
+			return
+		}
+	}
+	if r.Tool == ToolTurret {
+		config, ok := data.TurretConfigs[r.Kind]
+		if !ok {
+			w.Deny(pl, r, DenyInvalid)
+			return
+		}
+		if c := w.GetCell(r.X, r.Y); c != nil {
+			if w.IsPlacementValid(r.X, r.Y) && c.IsOpen() {
+				if pl.Points >= config.Points {
+					e := w.HandleToolRequest(r)
+					if e != nil {
+						pl.Points -= config.Points
+						w.SendPlayerPoints()
+						// Let the client know to make our turret.
+						if w.Game.Net().Hosting() {
+							r.NetID = e.NetID()
+							w.Game.Net().SendOrdered(WorldChannel, r)
+						}
+					}
+				} else {
+					w.Deny(pl, r, DenyPoints)
+				}
+			} else {
+				w.Deny(pl, r, DenyPlacement)
+			}
+		}
+	} else if r.Tool == ToolDestroy {
+		if owner, ok := w.ownerAt(r.X, r.Y); ok && owner != r.Owner && !local {
+			w.Deny(pl, r, DenyNotYours)
+			return
+		}
+		w.HandleToolRequest(r)
+		if w.Game.Net().Hosting() {
+			w.Game.Net().SendOrdered(WorldChannel, r)
+		}
+	} else if r.Tool == ToolWall {
+		c := w.GetCell(r.X, r.Y)
+		if c != nil {
+			if w.IsPlacementValid(r.X, r.Y) && c.IsOpen() {
+				if pl.Points >= 3 {
+					e := w.HandleToolRequest(r)
+					if e != nil {
+
+						pl.Points -= 3
+						w.SendPlayerPoints()
+
+						if w.Game.Net().Hosting() {
+							r.NetID = e.NetID()
+							w.Game.Net().SendOrdered(WorldChannel, r)
+						}
+					}
+				} else {
+					w.Deny(pl, r, DenyPoints)
+				}
+			} else {
+				w.Deny(pl, r, DenyPlacement)
+			}
+		}
+	} else {
+		w.Deny(pl, r, DenyInvalid)
+	}
+}
+
+// AddPlayerEntity creates the player's actor at the player spawn, if it doesn't already have one. Each player ID gets its own config and is offset a little so they don't all stack up.
+func (w *World) AddPlayerEntity(p *Player) {
+	if p.Entity != nil || p.Spectator {
+		return
+	}
+	c := PlayerConfig(p.ID)
+	offsets := []int{0, 1, -1, 2}
+	xoffset := offsets[p.ID%len(offsets)]
+
+	fmt.Println("Adding player entity", p.ID, c.Title)
+	e := NewActorEntity(p, c)
+	// Tie 'em together.
+	e.player = p
+	p.Entity = e
+	w.actors = append(w.actors, e)
+	// And place.
+	w.PlaceEntityInCell(e, w.playerX+xoffset, w.playerY)
+}
+
+// RemovePlayerEntity removes the player's actor.
+func (w *World) RemovePlayerEntity(p *Player) {
+	if p.Entity == nil {
+		return
+	}
+	p.Entity.Trash()
+	for i, a := range w.actors {
+		if a == p.Entity {
+			w.actors = append(w.actors[:i], w.actors[i+1:]...)
+			break
+		}
+	}
+	p.Entity = nil
+}
+
+// Relay sends a message from one peer on to all of the others. Only the host does this.
+func (w *World) Relay(from string, msg net.Message) {
+	for _, name := range w.Game.Net().Peers() {
+		if name != from {
+			w.Game.Net().SendTo(name, msg)
+		}
+	}
+}
+
+// RelayReliable is Relay, but reliable.
+func (w *World) RelayReliable(from string, msg net.Message) {
+	for _, name := range w.Game.Net().Peers() {
+		if name != from {
+			w.Game.Net().SendReliableTo(name, msg)
+		}
+	}
+}
+
+// RelayOrdered is Relay, but on the WorldChannel.
+func (w *World) RelayOrdered(from string, msg net.Message) {
+	for _, name := range w.Game.Net().Peers() {
+		if name != from {
+			w.Game.Net().SendOrderedTo(name, WorldChannel, msg)
+		}
+	}
+}
+
 // ???
 func (w *World) HandleToolRequest(r UseToolRequest) Entity {
 	pl := w.Game.GetPlayerByName(r.Owner)
@@ -432,6 +548,7 @@ func (w *World) HandleToolRequest(r UseToolRequest) Entity {
 		if config.AttackType == "beam" {
 			te := NewTurretBeamEntity(config)
 			te.owner = r.Owner
+			te.kind = r.Kind
 
 			// Hmm... this feels kind of gross.
 			if r.Owner != "" {
@@ -444,6 +561,7 @@ func (w *World) HandleToolRequest(r UseToolRequest) Entity {
 		} else {
 			te := NewTurretEntity(config)
 			te.owner = r.Owner
+			te.kind = r.Kind
 
 			// Hmm... this feels kind of gross.
 			if r.Owner != "" {
@@ -459,12 +577,6 @@ func (w *World) HandleToolRequest(r UseToolRequest) Entity {
 			e.SetNetID(w.GetNextNetID())
 		} else {
 			if r.NetID != 0 {
-				for _, trashedID := range w.trashedIDs {
-					if trashedID == r.NetID {
-						// Oh! we don't want to construct this, as it has already been trashed.
-						return nil
-					}
-				}
 				e.SetNetID(r.NetID)
 			}
 		}
@@ -547,16 +659,11 @@ func (w *World) HandleToolRequest(r UseToolRequest) Entity {
 func (w *World) SpawnEnemyEntity(r SpawnEnemyRequest) *EnemyEntity {
 	enemyConfig := data.EnemyConfigs[r.Kind]
 	e := NewEnemyEntity(enemyConfig)
+	e.kind = r.Kind
 	if w.Game.Net().Hosting() {
 		e.netID = w.GetNextNetID()
 	} else {
 		if r.NetID != 0 {
-			for _, trashedID := range w.trashedIDs {
-				if trashedID == r.NetID {
-					// Oh! we don't want to construct this, as it has already been trashed.
-					return nil
-				}
-			}
 			e.netID = r.NetID
 		}
 	}
@@ -574,12 +681,6 @@ func (w *World) SpawnOrbEntity(r SpawnOrbRequest) *OrbEntity {
 		e.netID = w.GetNextNetID()
 	} else {
 		if r.NetID != 0 {
-			for _, trashedID := range w.trashedIDs {
-				if trashedID == r.NetID {
-					// Oh! we don't want to construct this, as it has already been trashed.
-					return nil
-				}
-			}
 			e.netID = r.NetID
 		}
 	}
@@ -595,7 +696,7 @@ func (w *World) CollectOrb(r CollectOrbRequest) {
 	}
 	if r.Collector == w.Game.Players()[0].Name {
 		s := data.SFX.Play("pop.ogg")
-		if !data.SFX.Muted {
+		if s != nil && !data.SFX.Muted {
 			if r.Worth <= 10 {
 				s.SetVolume(0.5)
 			} else if r.Worth <= 15 {
@@ -630,12 +731,6 @@ func (w *World) SpawnProjecticleEntity(r SpawnProjecticleRequest) *ProjecticleEn
 		e.netID = w.GetNextNetID()
 	} else {
 		if r.NetID != 0 {
-			for _, trashedID := range w.trashedIDs {
-				if trashedID == r.NetID {
-					// Oh! we don't want to construct this, as it has already been trashed.
-					return nil
-				}
-			}
 			e.netID = r.NetID
 		}
 	}
@@ -649,11 +744,25 @@ func (w *World) SpawnProjecticleEntity(r SpawnProjecticleRequest) *ProjecticleEn
 	return e
 }
 
+// SyncEntity takes in an entity's properties from the host. Its position goes into its snapshot buffer, unless the host didn't say when it was from.
 func (w *World) SyncEntity(r EntityPropertySync) {
 	for _, e := range w.entities {
 		if e.NetID() == r.NetID {
-			e.Physics().X = r.X
-			e.Physics().Y = r.Y
+			if r.Tick == 0 {
+				e.Physics().X = r.X
+				e.Physics().Y = r.Y
+			} else {
+				w.noteHostTick(r.Tick)
+				if w.tracks == nil {
+					w.tracks = make(map[int]*netTrack)
+				}
+				t, ok := w.tracks[r.NetID]
+				if !ok {
+					t = &netTrack{}
+					w.tracks[r.NetID] = t
+				}
+				t.add(r.Tick, r.X, r.Y)
+			}
 			switch e := e.(type) {
 			case *EnemyEntity:
 				e.health = r.Health
@@ -664,9 +773,19 @@ func (w *World) SyncEntity(r EntityPropertySync) {
 }
 
 func (w *World) SplitPoints(value int) {
-	// Get it's split value.
-	worth := math.Max(1, math.Floor(float64(value)/float64(len(w.Game.Players()))))
+	// Spectators don't get a cut.
+	var players []*Player
 	for _, pl := range w.Game.Players() {
+		if !pl.Spectator {
+			players = append(players, pl)
+		}
+	}
+	if len(players) == 0 {
+		return
+	}
+	// Get it's split value.
+	worth := math.Max(1, math.Floor(float64(value)/float64(len(players))))
+	for _, pl := range players {
 		pl.Points += int(worth)
 	}
 }
@@ -680,7 +799,7 @@ func (w *World) SendPlayerPoints() {
 		for _, pl := range w.Game.Players() {
 			m.Points[pl.Name] = pl.Points
 		}
-		w.Game.Net().SendReliable(m)
+		w.Game.Net().SendOrdered(WorldChannel, m)
 	}
 }
 
@@ -704,19 +823,33 @@ func (w *World) SetMode(m WorldMode) {
 
 	m.Init(w)
 
-	if _, ok := w.Mode.(*WaveMode); ok {
-		// Clear out our trashed IDs history on wave start.
-		w.trashedIDs = make([]int, 0)
-	}
-
 	// Also send the network message if we're the host.
 	if w.Game.Net().Active() && w.Game.Net().Hosting() && m.Local() {
-		w.Game.Net().SendReliable(m)
+		w.Game.Net().SendOrdered(WorldChannel, m)
 	}
 }
 
-// Update updates the world.
+// SetSeed seeds the world's random source.
+func (w *World) SetSeed(seed int64) {
+	w.Seed = seed
+	w.rand = rand.New(rand.NewSource(seed))
+}
+
+// Rand returns the world's random source, seeding it from the current time if it has not been seeded.
+func (w *World) Rand() *rand.Rand {
+	if w.rand == nil {
+		w.SetSeed(time.Now().UnixNano())
+	}
+	return w.rand
+}
+
+// Update updates the world. Entities are updated and their requests are processed in the order the entities were added, so a given seed and input will always produce the same state.
 func (w *World) Update() error {
+	// Apply any player inputs first.
+	w.applyInputs()
+
+	w.updateSnapshotRequest()
+
 	if w.cameraShakeTimer > 0 {
 		w.cameraShakeTimer--
 	}
@@ -734,13 +867,13 @@ func (w *World) Update() error {
 	}
 
 	// TODO: Move this elsewhere
-	if inpututil.IsKeyJustPressed(ebiten.KeyAlt) {
+	if w.input().IsKeyJustPressed(engine.KeyAlt) {
 		for _, e := range w.entities {
 			if e, ok := e.(*TurretEntity); ok {
 				e.showRange = true
 			}
 		}
-	} else if inpututil.IsKeyJustReleased(ebiten.KeyAlt) {
+	} else if w.input().IsKeyJustReleased(engine.KeyAlt) {
 		for _, e := range w.entities {
 			if e, ok := e.(*TurretEntity); ok {
 				e.showRange = false
@@ -768,6 +901,10 @@ func (w *World) Update() error {
 		w.ProcessRequest(r)
 	}
 
+	// Smooth out anything the host is moving around.
+	w.updateTracks()
+	w.updatePlayerSync()
+
 	// Clean up any destroyed entities.
 	t := w.entities[:0]
 	for _, e := range w.entities {
@@ -777,18 +914,25 @@ func (w *World) Update() error {
 	}
 	w.entities = t
 
+	w.updateChecksum()
+
+	w.Tick++
 	return nil
 }
 
 // Draw draws the world, wow.
-func (w *World) Draw(screen *ebiten.Image) {
+func (w *World) Draw(screen *engine.Image) {
 	// Get our camera position.
-	screenOp := &ebiten.DrawImageOptions{}
+	screenOp := &engine.DrawImageOptions{}
 
 	// FIXME: Base this on some sort of player lookup or a global self reference.
 	if w.Game.Players()[0].Entity != nil {
 		w.CameraX = -w.Game.Players()[0].Entity.Physics().X + float64(ScreenWidth)/2
 		w.CameraY = -w.Game.Players()[0].Entity.Physics().Y + float64(ScreenHeight)/2
+	} else if w.Game.Players()[0].Spectator {
+		// Spectators get to look wherever they like.
+		w.CameraX = -w.spectateX + float64(ScreenWidth)/2
+		w.CameraY = -w.spectateY + float64(ScreenHeight)/2
 	}
 
 	// Shake the camera if the timer is set.
@@ -807,7 +951,7 @@ func (w *World) Draw(screen *ebiten.Image) {
 
 	// Draw da background.
 	if w.backgroundImage != nil {
-		bgOp := &ebiten.DrawImageOptions{}
+		bgOp := &engine.DrawImageOptions{}
 		width := ScreenWidth * 2
 		height := ScreenHeight * 2
 		bgOp.GeoM.Translate(-w.CameraX/float64(width/32), -w.CameraY/float64(height/32))
@@ -817,7 +961,7 @@ func (w *World) Draw(screen *ebiten.Image) {
 	// Draw the map.
 	for y, r := range w.cells {
 		for x, c := range r {
-			op := &ebiten.DrawImageOptions{}
+			op := &engine.DrawImageOptions{}
 			op.GeoM.Concat(screenOp.GeoM)
 			op.GeoM.Translate(float64(x*data.CellWidth), float64(y*data.CellHeight))
 			if c.kind == data.BlockedCell {
@@ -844,7 +988,7 @@ func (w *World) Draw(screen *ebiten.Image) {
 			if p.HoveringPlacement {
 				if p.HoveringPlace.Tool == ToolTurret || p.HoveringPlace.Tool == ToolWall {
 					image := GetToolImage(p.HoveringPlace.Tool, p.HoveringPlace.Kind)
-					op := &ebiten.DrawImageOptions{}
+					op := &engine.DrawImageOptions{}
 					op.ColorM.Scale(data.GetPolarityColorScale(p.HoveringPlace.Polarity))
 					op.ColorM.Scale(1, 1, 1, 0.5)
 					op.GeoM.Concat(screenOp.GeoM)
@@ -863,7 +1007,7 @@ func (w *World) Draw(screen *ebiten.Image) {
 					// Draw transparent version of tool for placement
 					if a.Tool == ToolTurret || a.Tool == ToolWall {
 						image := GetToolImage(a.Tool, a.Kind)
-						op := &ebiten.DrawImageOptions{}
+						op := &engine.DrawImageOptions{}
 						op.ColorM.Scale(data.GetPolarityColorScale(a.Polarity))
 						op.ColorM.Scale(1, 1, 1, 0.5)
 						op.GeoM.Concat(screenOp.GeoM)
@@ -902,7 +1046,7 @@ func (w *World) Draw(screen *ebiten.Image) {
 	/*for y, r := range w.cells {
 		for x, c := range r {
 			if c.IsOpen() {
-				ebitenutil.DrawRect(screen, w.cameraX+float64(x*cellWidth+cellWidth/2), w.cameraY+float64(y*cellHeight+cellHeight/2), 2, 2, color.White)
+				engine.DrawRect(screen, w.cameraX+float64(x*cellWidth+cellWidth/2), w.cameraY+float64(y*cellHeight+cellHeight/2), 2, 2, color.White)
 			}
 		}
 	}*/
@@ -912,7 +1056,7 @@ func (w *World) Draw(screen *ebiten.Image) {
 func (w *World) ArePlayersReady() bool {
 	playersCount := len(w.Game.Players())
 	for _, p := range w.Game.Players() {
-		if p.ReadyForWave {
+		if p.ReadyForWave || p.Spectator {
 			playersCount--
 		}
 	}
@@ -1089,7 +1233,7 @@ func ObjectsNearest[K interface{ Physics() *PhysicsObject }](l []K, x, y float64
 	results = make([]K, len(l))
 	copy(results, l)
 
-	sort.Slice(results, func(i, j int) bool {
+	sort.SliceStable(results, func(i, j int) bool {
 		a := GetMagnitude(GetDistanceVector(x, y, results[i].Physics().X, results[i].Physics().Y))
 		b := GetMagnitude(GetDistanceVector(x, y, results[j].Physics().X, results[j].Physics().Y))
 		return a < b
@@ -1112,9 +1256,17 @@ func (w *World) GetClosestCellPosition(x, y int) (int, int) {
 	return int(tx), int(ty)
 }
 
+// input returns the world's Input, falling back to ebiten if none is set.
+func (w *World) input() Input {
+	if w.Input == nil {
+		return EbitenInput{}
+	}
+	return w.Input
+}
+
 // GetCursorPosition returns the cursor position relative to the map.
 func (w *World) GetCursorPosition() (x, y int) {
-	x, y = ebiten.CursorPosition()
+	x, y = engine.CursorPosition()
 	x -= int(w.CameraX)
 	y -= int(w.CameraY)
 	return x, y
@@ -1139,24 +1291,24 @@ func (c *LiveCell) IsOpen() bool {
 }
 
 // This is _not_ the place for this.
-func DrawWaves(w *World, screen *ebiten.Image, spawnerOp *ebiten.DrawImageOptions) {
+func DrawWaves(w *World, screen *engine.Image, spawnerOp *engine.DrawImageOptions) {
 	for _, spawner := range w.spawners {
 		yAdjust := 0
 		if spawner.wave != nil {
 			x := 0
 			for spawnList := spawner.wave.Spawns; spawnList != nil; spawnList = spawnList.Next {
 				t := fmt.Sprintf("%d", spawnList.Count)
-				bounds := text.BoundString(data.NormalFace, t)
-				text.Draw(screen, t, data.NormalFace, x+int(spawnerOp.GeoM.Element(0, 2)), int(spawnerOp.GeoM.Element(1, 2))+bounds.Dy(), color.White)
+				bounds := engine.BoundString(data.NormalFace, t)
+				engine.DrawText(screen, t, data.NormalFace, x+int(spawnerOp.GeoM.Element(0, 2)), int(spawnerOp.GeoM.Element(1, 2))+bounds.Dy(), color.White)
 				x += bounds.Dx() + 3
 				// Draw th' dude(s).
-				eop := ebiten.DrawImageOptions{}
+				eop := engine.DrawImageOptions{}
 				eop.ColorM.Scale(data.GetPolarityColorScale(spawner.physics.polarity))
 				eop.GeoM.Concat(spawnerOp.GeoM)
 				eop.GeoM.Translate(float64(x), 0)
 				for _, kind := range spawnList.Kinds {
 					if enemy, ok := data.EnemyConfigs[kind]; ok {
-						ebitenutil.DrawRect(screen, eop.GeoM.Element(0, 2)-1, eop.GeoM.Element(1, 2)-1, float64(enemy.WalkImages[0].Bounds().Dx())+1, float64(enemy.WalkImages[0].Bounds().Dy())+1, color.RGBA{128, 128, 128, 64})
+						engine.DrawRect(screen, eop.GeoM.Element(0, 2)-1, eop.GeoM.Element(1, 2)-1, float64(enemy.WalkImages[0].Bounds().Dx())+1, float64(enemy.WalkImages[0].Bounds().Dy())+1, color.RGBA{128, 128, 128, 64})
 
 						if enemy.WalkImages[0].Bounds().Dy() >= yAdjust {
 							yAdjust = enemy.WalkImages[0].Bounds().Dy()
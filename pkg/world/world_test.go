@@ -0,0 +1,42 @@
+package world
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/kettek/ebijam22/pkg/data"
+)
+
+func TestMain(m *testing.M) {
+	// Same as the game's.
+	data.CellWidth = 16
+	data.CellHeight = 11
+
+	if err := data.LoadConfigurations(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := data.LoadData(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// newTestSimulation starts a simulation of the named level with a fixed seed. Nobody readies up on their own.
+func newTestSimulation(t *testing.T, name string) *Simulation {
+	t.Helper()
+	level, err := data.NewLevel(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim, err := NewSimulation(level, data.Options{
+		Map:  name,
+		Seed: 1234,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sim
+}